@@ -24,7 +24,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/jenkins-x/lighthouse/pkg/clients"
 	"github.com/jenkins-x/lighthouse/pkg/io"
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
 	"github.com/jenkins-x/lighthouse/pkg/prow/config"
 	"github.com/jenkins-x/lighthouse/pkg/prow/interrupts"
 	"github.com/jenkins-x/lighthouse/pkg/prow/logrusutil"
@@ -32,6 +34,7 @@ import (
 	"github.com/jenkins-x/lighthouse/pkg/prow/pjutil"
 	"github.com/jenkins-x/lighthouse/pkg/tide"
 	"github.com/jenkins-x/lighthouse/pkg/tide/githubapp"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
@@ -50,6 +53,8 @@ type options struct {
 	dryRun  bool
 	runOnce bool
 
+	syncDebounce time.Duration
+
 	maxRecordsPerPool int
 	// The following are used for reading/writing to GCS.
 	gcsCredentialsFile string
@@ -80,8 +85,9 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	fs.StringVar(&o.botName, "bot-name", "jenkins-x-bot", "The bot name")
 	fs.StringVar(&o.gitServerURL, "git-url", "", "The git provider URL")
 	fs.StringVar(&o.gitKind, "git-kind", "", "The git provider kind (e.g. github, gitlab, bitbucketserver")
-	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether to mutate any real-world state.")
+	fs.BoolVar(&o.dryRun, "dry-run", true, "Whether to mutate any real-world state. In dry-run mode the merges, triggers and status updates Tide would make are logged and recorded in the history with a DRY- prefix instead.")
 	fs.BoolVar(&o.runOnce, "run-once", false, "If true, run only once then quit.")
+	fs.DurationVar(&o.syncDebounce, "sync-debounce", tide.DefaultSyncDebounce, "How long to wait for more events on a pool notified to the /sync endpoint before syncing it.")
 	fs.IntVar(&o.syncThrottle, "sync-hourly-tokens", 800, "The maximum number of tokens per hour to be used by the sync controller.")
 	fs.IntVar(&o.statusThrottle, "status-hourly-tokens", 400, "The maximum number of tokens per hour to be used by the status controller.")
 
@@ -147,14 +153,35 @@ func main() {
 	}
 	gitToken := os.Getenv("GIT_TOKEN")
 
+	_, jxClient, _, ns, err := clients.GetClientsAndNamespace()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating kubernetes resource clients.")
+	}
+	activityCache, err := plumber.NewActivityCache(jxClient, ns)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating PipelineActivity cache.")
+	}
+	if err := activityCache.Start(interrupts.Context().Done()); err != nil {
+		logrus.WithError(err).Fatal("Error starting PipelineActivity cache.")
+	}
+
 	cfg := configAgent.Config
-	c, err := githubapp.NewTideController(configAgent, botName, gitKind, gitToken, serverURL, o.maxRecordsPerPool, opener, o.historyURI, o.statusURI)
+	c, err := githubapp.NewTideController(configAgent, botName, gitKind, gitToken, serverURL, o.maxRecordsPerPool, opener, o.historyURI, o.statusURI, o.dryRun, activityCache)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error creating Tide controller.")
 	}
 	defer c.Shutdown()
 	http.Handle("/", c)
-	http.Handle("/history", c.GetHistory())
+	http.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
+		c.GetHistory().ServeHTTP(w, r)
+	})
+	http.Handle("/pr-status", tide.NewPRStatusHandler(c, logrus.WithField("handler", "pr-status")))
+	hmacToken := os.Getenv("HMAC_TOKEN")
+	if hmacToken == "" {
+		logrus.Warn("HMAC_TOKEN is not set, the sync requests of lighthouse are refused.")
+	}
+	http.Handle(tide.SyncPath, tide.NewSyncHandler(c, []byte(hmacToken), o.syncDebounce, logrus.WithField("handler", "sync")))
+	http.Handle("/metrics", promhttp.Handler())
 	server := &http.Server{Addr: ":" + strconv.Itoa(o.port)}
 
 	start := time.Now()
@@ -171,7 +198,8 @@ func main() {
 		return cfg().Tide.SyncPeriod
 	})
 
-	// Push metrics to the configured prometheus pushgateway endpoint or serve them
+	// Push metrics to the configured prometheus pushgateway endpoint, they are
+	// also served on /metrics
 	gateway := cfg().PushGateway
 	if gateway.Endpoint != "" {
 		logrus.WithField("gateway", gateway.Endpoint).Infof("using push gateway")
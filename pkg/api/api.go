@@ -0,0 +1,446 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins/trigger"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+const (
+	// Prefix is the URL path prefix of the REST API
+	Prefix = "/api/v1/"
+	// PipelinesPath is the URL path for listing pipelines and, with a /<name>/abort suffix, aborting them
+	PipelinesPath = Prefix + "pipelines"
+	// TriggerPath is the URL path for triggering jobs
+	TriggerPath = Prefix + "trigger"
+	// ConfigPath is the URL path for fetching the job config of a repository
+	ConfigPath = Prefix + "config"
+
+	// defaultPerPage is the number of pipelines listed per page by default
+	defaultPerPage = 100
+	// maxPerPage is the maximum number of pipelines listed per page
+	maxPerPage = 500
+)
+
+// TriggerRequest is the body of a request to trigger a job
+type TriggerRequest struct {
+	// Type is the type of the job, one of presubmit, postsubmit or periodic
+	Type plumber.PipelineKind `json:"type"`
+	Org  string               `json:"org"`
+	Repo string               `json:"repo"`
+	Job  string               `json:"job"`
+	// PR is the number of the pull request to run a presubmit against
+	PR int `json:"pr,omitempty"`
+	// Ref is the branch to run a postsubmit or periodic against
+	Ref string `json:"ref,omitempty"`
+	// SHA is the commit to run a postsubmit against. Defaults to the head of Ref.
+	SHA string `json:"sha,omitempty"`
+}
+
+// JobConfig is the job config resolved for a repository
+type JobConfig struct {
+	Presubmits  []config.Presubmit  `json:"presubmits"`
+	Postsubmits []config.Postsubmit `json:"postsubmits"`
+	Periodics   []config.Periodic   `json:"periodics"`
+}
+
+type lister interface {
+	List(opts metav1.ListOptions) (*plumber.PipelineOptionsList, error)
+}
+
+type aborter interface {
+	Abort(name string) error
+}
+
+// Server serves a JSON REST API for listing, triggering and aborting pipelines. Requests are
+// authenticated with bearer tokens, and triggering or aborting the pipelines of a repository
+// requires the user of the token to have write permission on it.
+type Server struct {
+	// Tokens maps the bearer tokens to the users they authenticate
+	Tokens       map[string]string
+	ConfigGetter config.Getter
+	// Trigger creates the pipelines, its config is refreshed from ConfigGetter on each request
+	Trigger trigger.Client
+	Plumber lister
+	Aborter aborter
+	Logger  *logrus.Entry
+}
+
+// LoadTokens loads a YAML file mapping user names to their bearer token, returning a map
+// of the tokens to the users
+func LoadTokens(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read tokens file %s", path)
+	}
+	users := map[string]string{}
+	if err := yaml.Unmarshal(data, &users); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse tokens file %s", path)
+	}
+	tokens := map[string]string{}
+	for user, token := range users {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			return nil, errors.Errorf("empty token for user %s in %s", user, path)
+		}
+		if _, ok := tokens[token]; ok {
+			return nil, errors.Errorf("duplicate token for user %s in %s", user, path)
+		}
+		tokens[token] = user
+	}
+	return tokens, nil
+}
+
+// Handler returns the handler serving the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(PipelinesPath, s.authenticated(http.MethodGet, s.listPipelines))
+	mux.Handle(PipelinesPath+"/", s.authenticated(http.MethodPost, s.abortPipeline))
+	mux.Handle(TriggerPath, s.authenticated(http.MethodPost, s.triggerJob))
+	mux.Handle(ConfigPath, s.authenticated(http.MethodGet, s.getConfig))
+	return mux
+}
+
+type userHandlerFunc func(w http.ResponseWriter, r *http.Request, user string)
+
+// authenticated only calls the handler for requests with the given method and a known bearer token
+func (s *Server) authenticated(method string, handler userHandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed", r.Method))
+			return
+		}
+		user, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		handler(w, r, user)
+	})
+}
+
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	given := []byte(strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")))
+	for token, user := range s.Tokens {
+		if subtle.ConstantTimeCompare(given, []byte(token)) == 1 {
+			return user, true
+		}
+	}
+	return "", false
+}
+
+// listPipelines lists the pipelines sorted by name, optionally filtered by the repo, pr, job and state
+// query parameters. The list is paginated by the page and per_page query parameters, the Link header
+// giving the URL of the next page if there is one.
+func (s *Server) listPipelines(w http.ResponseWriter, r *http.Request, user string) {
+	query := r.URL.Query()
+	repo := query.Get("repo")
+	job := query.Get("job")
+	state := plumber.PipelineState(query.Get("state"))
+	pr, ok := intParam(w, query, "pr", 0)
+	if !ok {
+		return
+	}
+	page, ok := intParam(w, query, "page", 1)
+	if !ok {
+		return
+	}
+	perPage, ok := intParam(w, query, "per_page", defaultPerPage)
+	if !ok {
+		return
+	}
+	if page < 1 || perPage < 1 || perPage > maxPerPage {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("page must be positive and per_page between 1 and %d", maxPerPage))
+		return
+	}
+
+	opts := metav1.ListOptions{}
+	if repo != "" {
+		parts := strings.Split(repo, "/")
+		if len(parts) != 2 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("repo %q is not of the form org/repo", repo))
+			return
+		}
+		selector, err := plumber.RepoSelector(parts[0], parts[1])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		opts.LabelSelector = selector
+	}
+	list, err := s.Plumber.List(opts)
+	if err != nil {
+		s.Logger.WithError(err).Error("Failed to list pipelines.")
+		writeError(w, http.StatusInternalServerError, "failed to list pipelines")
+		return
+	}
+	pipelines := []plumber.PipelineOptions{}
+	if list != nil {
+		for _, p := range list.Items {
+			if matches(p, repo, pr, job, state) {
+				pipelines = append(pipelines, p)
+			}
+		}
+	}
+	sort.Slice(pipelines, func(i, j int) bool {
+		return pipelines[i].Name < pipelines[j].Name
+	})
+
+	start := (page - 1) * perPage
+	if start > len(pipelines) {
+		start = len(pipelines)
+	}
+	end := start + perPage
+	if end < len(pipelines) {
+		next := *r.URL
+		query.Set("page", strconv.Itoa(page+1))
+		query.Set("per_page", strconv.Itoa(perPage))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	} else {
+		end = len(pipelines)
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(pipelines)))
+	writeJSON(w, http.StatusOK, pipelines[start:end])
+}
+
+// intParam returns the integer value of a query parameter or the default if it is missing, otherwise
+// it writes an error response
+func intParam(w http.ResponseWriter, query url.Values, name string, defaultValue int) (int, bool) {
+	value := query.Get(name)
+	if value == "" {
+		return defaultValue, true
+	}
+	answer, err := strconv.Atoi(value)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s %q", name, value))
+		return 0, false
+	}
+	return answer, true
+}
+
+func matches(p plumber.PipelineOptions, repo string, pr int, job string, state plumber.PipelineState) bool {
+	if job != "" && p.Spec.Job != job {
+		return false
+	}
+	if state != "" && p.Status.State != state {
+		return false
+	}
+	refs := p.Spec.Refs
+	if repo != "" && (refs == nil || refs.Org+"/"+refs.Repo != repo) {
+		return false
+	}
+	if pr != 0 {
+		if refs == nil {
+			return false
+		}
+		for _, pull := range refs.Pulls {
+			if pull.Number == pr {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// abortPipeline aborts the pipeline named by a /pipelines/<name>/abort path
+func (s *Server) abortPipeline(w http.ResponseWriter, r *http.Request, user string) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, PipelinesPath+"/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "abort" {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown path %s", r.URL.Path))
+		return
+	}
+	name := parts[0]
+
+	list, err := s.Plumber.List(metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String()})
+	if err != nil {
+		s.Logger.WithError(err).Error("Failed to list pipelines.")
+		writeError(w, http.StatusInternalServerError, "failed to list pipelines")
+		return
+	}
+	var pipeline *plumber.PipelineOptions
+	if list != nil {
+		for i := range list.Items {
+			if list.Items[i].Name == name {
+				pipeline = &list.Items[i]
+				break
+			}
+		}
+	}
+	if pipeline == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no pipeline named %s", name))
+		return
+	}
+	if pipeline.Spec.Refs == nil {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("pipeline %s has no repository", name))
+		return
+	}
+	if !s.authorized(w, user, pipeline.Spec.Refs.Org, pipeline.Spec.Refs.Repo) {
+		return
+	}
+
+	if err := s.Aborter.Abort(name); err != nil {
+		s.Logger.WithError(err).Errorf("Failed to abort pipeline %s.", name)
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to abort pipeline %s", name))
+		return
+	}
+	s.Logger.Infof("Pipeline %s aborted by %s.", name, user)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// triggerJob triggers a presubmit for a pull request or a postsubmit or periodic for a ref
+func (s *Server) triggerJob(w http.ResponseWriter, r *http.Request, user string) {
+	req := TriggerRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if req.Org == "" || req.Repo == "" || req.Job == "" {
+		writeError(w, http.StatusBadRequest, "org, repo and job are required")
+		return
+	}
+	if !s.authorized(w, user, req.Org, req.Repo) {
+		return
+	}
+
+	c := s.Trigger
+	c.Config = s.ConfigGetter()
+	c.Logger = s.Logger.WithFields(logrus.Fields{"org": req.Org, "repo": req.Repo, "job": req.Job, "user": user})
+	id, _ := uuid.NewV1()
+	eventGUID := "api-" + id.String()
+	repository := scm.Repository{Namespace: req.Org, Name: req.Repo, FullName: req.Org + "/" + req.Repo}
+
+	var err error
+	switch req.Type {
+	case plumber.PresubmitJob:
+		err = s.triggerPresubmit(c, req, eventGUID)
+	case plumber.PostsubmitJob:
+		if req.Ref == "" {
+			writeError(w, http.StatusBadRequest, "ref is required for postsubmits")
+			return
+		}
+		sha := req.SHA
+		if sha == "" {
+			if sha, err = c.GitHubClient.GetRef(req.Org, req.Repo, "heads/"+req.Ref); err != nil {
+				break
+			}
+		}
+		err = trigger.RerunPostsubmit(c, repository, req.Ref, sha, req.Job, eventGUID)
+	case plumber.PeriodicJob:
+		if req.Ref == "" {
+			writeError(w, http.StatusBadRequest, "ref is required for periodics")
+			return
+		}
+		err = trigger.RerunPeriodic(c, repository, req.Ref, req.Job, eventGUID)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported job type %q", req.Type))
+		return
+	}
+	if err != nil {
+		c.Logger.WithError(err).Error("Failed to trigger job.")
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	c.Logger.Info("Job triggered.")
+	writeJSON(w, http.StatusAccepted, map[string]string{"event_guid": eventGUID})
+}
+
+func (s *Server) triggerPresubmit(c trigger.Client, req TriggerRequest, eventGUID string) error {
+	if req.PR == 0 {
+		return errors.New("pr is required for presubmits")
+	}
+	pr, err := c.GitHubClient.GetPullRequest(req.Org, req.Repo, req.PR)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pull request %d", req.PR)
+	}
+	for _, job := range c.Config.GetPresubmits(pr.Repository()) {
+		if job.Name == req.Job && job.CouldRun(pr.Base.Ref) {
+			return trigger.RunAndSkipJobs(c, pr, []config.Presubmit{job}, nil, eventGUID, true)
+		}
+	}
+	return errors.Errorf("no presubmit named %q for %s/%s branch %s", req.Job, req.Org, req.Repo, pr.Base.Ref)
+}
+
+// getConfig returns the jobs of the repository given by the repo query parameter: its
+// presubmits and postsubmits, and the periodics which clone it
+func (s *Server) getConfig(w http.ResponseWriter, r *http.Request, user string) {
+	repo := r.URL.Query().Get("repo")
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("repo %q is not of the form org/repo", repo))
+		return
+	}
+	if !s.authorized(w, user, parts[0], parts[1]) {
+		return
+	}
+	cfg := s.ConfigGetter()
+	repository := scm.Repository{Namespace: parts[0], Name: parts[1], FullName: repo}
+	var periodics []config.Periodic
+	for _, p := range cfg.AllPeriodics() {
+		if trigger.PeriodicClones(p, repository) {
+			periodics = append(periodics, p)
+		}
+	}
+	writeJSON(w, http.StatusOK, JobConfig{
+		Presubmits:  cfg.GetPresubmits(repository),
+		Postsubmits: cfg.GetPostsubmits(repository),
+		Periodics:   periodics,
+	})
+}
+
+// authorized returns true if the user has write permission on the repository, otherwise
+// it writes an error response
+func (s *Server) authorized(w http.ResponseWriter, user, org, repo string) bool {
+	ok, err := trigger.CanRerun(s.Trigger.GitHubClient, org, repo, user)
+	if err != nil {
+		s.Logger.WithError(err).Errorf("Failed to check the permission of %s on %s/%s.", user, org, repo)
+		writeError(w, http.StatusInternalServerError, "failed to check permissions")
+		return false
+	}
+	if !ok {
+		writeError(w, http.StatusForbidden, fmt.Sprintf("%s does not have write permission on %s/%s", user, org, repo))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal the API response.")
+		http.Error(w, "failed to marshal the response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		logrus.WithError(err).Debug("Failed to write the API response.")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
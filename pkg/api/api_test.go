@@ -0,0 +1,294 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/jenkins-x/lighthouse/pkg/plumber/fake"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/fakegitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins/trigger"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeAborter struct {
+	aborted []string
+}
+
+func (f *fakeAborter) Abort(name string) error {
+	f.aborted = append(f.aborted, name)
+	return nil
+}
+
+func newTestServer(t *testing.T) (*Server, *fake.Plumber, *fakeAborter) {
+	repository := scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo"}
+	fc := &fakegitprovider.FakeClient{
+		UserPermissions: map[string]string{"writer": "write", "reader": "read"},
+		PullRequests: map[int]*scm.PullRequest{
+			1: {Number: 1, Base: scm.PullRequestBranch{Ref: "master", Repo: repository}, Head: scm.PullRequestBranch{Sha: "headsha"}},
+		},
+	}
+	cfg := &config.Config{}
+	require.NoError(t, cfg.SetPresubmits(map[string][]config.Presubmit{
+		"org/repo": {{JobBase: config.JobBase{Name: "unit"}, Reporter: config.Reporter{Context: "unit"}}},
+	}))
+	require.NoError(t, cfg.SetPostsubmits(map[string][]config.Postsubmit{
+		"org/repo": {{JobBase: config.JobBase{Name: "release"}}},
+	}))
+	cfg.Periodics = []config.Periodic{
+		{JobBase: config.JobBase{Name: "nightly", UtilityConfig: config.UtilityConfig{ExtraRefs: []plumber.Refs{{Org: "org", Repo: "repo"}}}}},
+		{JobBase: config.JobBase{Name: "other-nightly", UtilityConfig: config.UtilityConfig{ExtraRefs: []plumber.Refs{{Org: "org", Repo: "other"}}}}},
+	}
+
+	fakePlumber := fake.NewPlumber()
+	fakePlumber.Pipelines = []*plumber.PipelineOptions{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "org-repo-pr-1-1"},
+			Spec: plumber.PipelineOptionsSpec{
+				Type: plumber.PresubmitJob,
+				Job:  "unit",
+				Refs: &plumber.Refs{Org: "org", Repo: "repo", Pulls: []plumber.Pull{{Number: 1}}},
+			},
+			Status: plumber.PipelineStatus{State: plumber.RunningState},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "org-other-master-1"},
+			Spec: plumber.PipelineOptionsSpec{
+				Type: plumber.PostsubmitJob,
+				Job:  "release",
+				Refs: &plumber.Refs{Org: "org", Repo: "other"},
+			},
+			Status: plumber.PipelineStatus{State: plumber.SuccessState},
+		},
+	}
+	aborter := &fakeAborter{}
+	s := &Server{
+		Tokens:       map[string]string{"writer-token": "writer", "reader-token": "reader"},
+		ConfigGetter: func() *config.Config { return cfg },
+		Trigger: trigger.Client{
+			GitHubClient:  fc,
+			PlumberClient: fakePlumber,
+		},
+		Plumber: fakePlumber,
+		Aborter: aborter,
+		Logger:  logrus.WithField("component", "api"),
+	}
+	return s, fakePlumber, aborter
+}
+
+func doRequest(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthentication(t *testing.T) {
+	s, _, _ := newTestServer(t)
+
+	assert.Equal(t, http.StatusUnauthorized, doRequest(s, http.MethodGet, PipelinesPath, "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(s, http.MethodGet, PipelinesPath, "wrong", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, doRequest(s, http.MethodPost, PipelinesPath, "reader-token", "").Code)
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, PipelinesPath, "reader-token", "").Code)
+}
+
+func TestListPipelines(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{
+			query:    "",
+			expected: []string{"org-other-master-1", "org-repo-pr-1-1"},
+		},
+		{
+			query:    "?repo=org/repo",
+			expected: []string{"org-repo-pr-1-1"},
+		},
+		{
+			query:    "?pr=1",
+			expected: []string{"org-repo-pr-1-1"},
+		},
+		{
+			query:    "?job=release&state=success",
+			expected: []string{"org-other-master-1"},
+		},
+		{
+			query: "?state=failure",
+		},
+		{
+			query:    "?per_page=1",
+			expected: []string{"org-other-master-1"},
+		},
+		{
+			query:    "?page=2&per_page=1",
+			expected: []string{"org-repo-pr-1-1"},
+		},
+		{
+			query: "?page=3&per_page=1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			s, _, _ := newTestServer(t)
+			w := doRequest(s, http.MethodGet, PipelinesPath+tc.query, "reader-token", "")
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var pipelines []plumber.PipelineOptions
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pipelines))
+			var names []string
+			for _, p := range pipelines {
+				names = append(names, p.Name)
+			}
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+}
+
+func TestListPipelinesPages(t *testing.T) {
+	s, _, _ := newTestServer(t)
+
+	w := doRequest(s, http.MethodGet, PipelinesPath+"?per_page=1", "reader-token", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Total-Count"))
+	assert.Equal(t, `<`+PipelinesPath+`?page=2&per_page=1>; rel="next"`, w.Header().Get("Link"))
+
+	w = doRequest(s, http.MethodGet, PipelinesPath+"?page=2&per_page=1", "reader-token", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Link"))
+
+	for _, query := range []string{"?page=0", "?per_page=1000", "?page=x", "?repo=org", "?repo=org/re%20po"} {
+		assert.Equal(t, http.StatusBadRequest, doRequest(s, http.MethodGet, PipelinesPath+query, "reader-token", "").Code, query)
+	}
+}
+
+func TestAbortPipeline(t *testing.T) {
+	s, _, aborter := newTestServer(t)
+
+	assert.Equal(t, http.StatusForbidden, doRequest(s, http.MethodPost, PipelinesPath+"/org-repo-pr-1-1/abort", "reader-token", "").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodPost, PipelinesPath+"/missing/abort", "writer-token", "").Code)
+	assert.Empty(t, aborter.aborted)
+
+	assert.Equal(t, http.StatusNoContent, doRequest(s, http.MethodPost, PipelinesPath+"/org-repo-pr-1-1/abort", "writer-token", "").Code)
+	assert.Equal(t, []string{"org-repo-pr-1-1"}, aborter.aborted)
+}
+
+func TestTriggerJob(t *testing.T) {
+	testCases := []struct {
+		name         string
+		token        string
+		body         string
+		expectedCode int
+		expectedJob  string
+		expectedType plumber.PipelineKind
+	}{
+		{
+			name:         "presubmit",
+			token:        "writer-token",
+			body:         `{"type":"presubmit","org":"org","repo":"repo","job":"unit","pr":1}`,
+			expectedCode: http.StatusAccepted,
+			expectedJob:  "unit",
+			expectedType: plumber.PresubmitJob,
+		},
+		{
+			name:         "postsubmit",
+			token:        "writer-token",
+			body:         `{"type":"postsubmit","org":"org","repo":"repo","job":"release","ref":"master","sha":"abc"}`,
+			expectedCode: http.StatusAccepted,
+			expectedJob:  "release",
+			expectedType: plumber.PostsubmitJob,
+		},
+		{
+			name:         "periodic",
+			token:        "writer-token",
+			body:         `{"type":"periodic","org":"org","repo":"repo","job":"nightly","ref":"master"}`,
+			expectedCode: http.StatusAccepted,
+			expectedJob:  "nightly",
+			expectedType: plumber.PeriodicJob,
+		},
+		{
+			name:         "unknown job",
+			token:        "writer-token",
+			body:         `{"type":"presubmit","org":"org","repo":"repo","job":"nope","pr":1}`,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "postsubmit without ref",
+			token:        "writer-token",
+			body:         `{"type":"postsubmit","org":"org","repo":"repo","job":"release"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "without write permission",
+			token:        "reader-token",
+			body:         `{"type":"postsubmit","org":"org","repo":"repo","job":"release","ref":"master"}`,
+			expectedCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, fakePlumber, _ := newTestServer(t)
+			existing := len(fakePlumber.Pipelines)
+
+			w := doRequest(s, http.MethodPost, TriggerPath, tc.token, tc.body)
+			require.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+
+			created := fakePlumber.Pipelines[existing:]
+			if tc.expectedJob == "" {
+				assert.Empty(t, created)
+				return
+			}
+			require.Len(t, created, 1)
+			assert.Equal(t, tc.expectedJob, created[0].Spec.Job)
+			assert.Equal(t, tc.expectedType, created[0].Spec.Type)
+		})
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	s, _, _ := newTestServer(t)
+
+	assert.Equal(t, http.StatusBadRequest, doRequest(s, http.MethodGet, ConfigPath+"?repo=org", "writer-token", "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(s, http.MethodGet, ConfigPath+"?repo=org/repo", "reader-token", "").Code)
+
+	w := doRequest(s, http.MethodGet, ConfigPath+"?repo=org/repo", "writer-token", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	jobs := JobConfig{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &jobs))
+	require.Len(t, jobs.Presubmits, 1)
+	assert.Equal(t, "unit", jobs.Presubmits[0].Name)
+	require.Len(t, jobs.Postsubmits, 1)
+	assert.Equal(t, "release", jobs.Postsubmits[0].Name)
+	require.Len(t, jobs.Periodics, 1)
+	assert.Equal(t, "nightly", jobs.Periodics[0].Name)
+}
+
+func TestLoadTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api-tokens")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "tokens.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("release-bot: abc\nchat-bot: def\n"), 0600))
+	tokens, err := LoadTokens(file)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"abc": "release-bot", "def": "chat-bot"}, tokens)
+
+	require.NoError(t, ioutil.WriteFile(file, []byte("release-bot: abc\nchat-bot: abc\n"), 0600))
+	_, err = LoadTokens(file)
+	assert.Error(t, err)
+}
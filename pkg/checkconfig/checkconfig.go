@@ -0,0 +1,216 @@
+package checkconfig
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	// Link in all the plugins so that unknown plugin names can be detected.
+	_ "github.com/jenkins-x/lighthouse/pkg/prow/hook"
+)
+
+// Options holds the options for the checkconfig command
+type Options struct {
+	ConfigPath    string
+	JobConfigPath string
+	PluginConfig  string
+	Strict        bool
+
+	Out io.Writer
+}
+
+// NewCmdCheckConfig creates the command which validates the lighthouse configuration
+func NewCmdCheckConfig() *cobra.Command {
+	options := Options{}
+
+	cmd := &cobra.Command{
+		Use:   "checkconfig",
+		Short: "Validates the lighthouse config.yaml, plugins.yaml and job configuration",
+		Long: `Validates the lighthouse config.yaml, plugins.yaml and job configuration.
+
+Errors which would cause the configuration to be rejected are always fatal. Warnings such as
+unknown plugins, colliding presubmit contexts, trigger regexes which do not match their
+rerun command, and tide queries for repositories without any jobs only fail the command
+when --strict is used.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := options.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().StringVar(&options.ConfigPath, "config-path", "", "Path to the config.yaml file.")
+	cmd.Flags().StringVar(&options.JobConfigPath, "job-config-path", "", "Path to a job config file or a directory of job config files.")
+	cmd.Flags().StringVar(&options.PluginConfig, "plugin-config", "", "Path to the plugins.yaml file.")
+	cmd.Flags().BoolVar(&options.Strict, "strict", false, "Fail if any warnings are found.")
+
+	return cmd
+}
+
+// Run validates the configuration, returning an error if it is invalid
+func (o *Options) Run() error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.ConfigPath == "" {
+		return errors.New("required flag --config-path was unset")
+	}
+
+	l := newLocator()
+	cfg, err := config.Load(o.ConfigPath, o.JobConfigPath)
+	if err != nil {
+		where := l.locate(err, configFiles(o.ConfigPath, o.JobConfigPath))
+		if where == "" {
+			where = describePaths(o.ConfigPath, o.JobConfigPath)
+		}
+		return errors.Wrapf(err, "failed to load config %s", where)
+	}
+
+	var pcfg *plugins.Configuration
+	if o.PluginConfig != "" {
+		pa := &plugins.ConfigAgent{}
+		if err := pa.Load(o.PluginConfig); err != nil {
+			where := l.locate(err, []string{o.PluginConfig})
+			if where == "" {
+				where = o.PluginConfig
+			}
+			return errors.Wrapf(err, "failed to load plugin config %s", where)
+		}
+		pcfg = pa.Config()
+	}
+
+	warnings := Warnings(cfg, pcfg)
+	for _, w := range warnings {
+		fmt.Fprintf(o.Out, "WARNING: %s\n", w)
+	}
+	if o.Strict && len(warnings) > 0 {
+		return errors.Errorf("found %d warning(s) in strict mode", len(warnings))
+	}
+	fmt.Fprintln(o.Out, "config is valid")
+	return nil
+}
+
+func describePaths(configPath, jobConfigPath string) string {
+	if jobConfigPath == "" {
+		return configPath
+	}
+	return fmt.Sprintf("%s and job config %s", configPath, jobConfigPath)
+}
+
+// Warnings returns the problems found in a configuration which loaded successfully
+// but which are most likely mistakes. The plugin configuration is optional.
+func Warnings(cfg *config.Config, pcfg *plugins.Configuration) []string {
+	var warnings []string
+	if pcfg != nil {
+		warnings = append(warnings, unknownPlugins(pcfg)...)
+	}
+	l := newLocator()
+	warnings = append(warnings, collidingContexts(cfg, l)...)
+	warnings = append(warnings, mismatchedRerunCommands(cfg, l)...)
+	warnings = append(warnings, tideReposWithoutJobs(cfg)...)
+	return warnings
+}
+
+func unknownPlugins(pcfg *plugins.Configuration) []string {
+	known := plugins.HelpProviders()
+	var warnings []string
+	var repos []string
+	for repo := range pcfg.Plugins {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		for _, plugin := range pcfg.Plugins[repo] {
+			if _, ok := known[plugin]; !ok {
+				warnings = append(warnings, fmt.Sprintf("unknown plugin %q enabled for %s", plugin, repo))
+			}
+		}
+	}
+	return warnings
+}
+
+func collidingContexts(cfg *config.Config, l *locator) []string {
+	var warnings []string
+	for _, repo := range sortedRepos(cfg.Presubmits) {
+		jobs := cfg.Presubmits[repo]
+		for i := range jobs {
+			for j := i + 1; j < len(jobs); j++ {
+				a, b := jobs[i], jobs[j]
+				if a.SkipReport || b.SkipReport || a.Context != b.Context || !a.Brancher.Intersects(b.Brancher) {
+					continue
+				}
+				warnings = append(warnings, fmt.Sprintf("presubmits %s (%s) and %s (%s) in %s both report the context %q", a.Name, l.jobPosition(a.SourcePath, a.Name), b.Name, l.jobPosition(b.SourcePath, b.Name), repo, a.Context))
+			}
+		}
+	}
+	return warnings
+}
+
+func mismatchedRerunCommands(cfg *config.Config, l *locator) []string {
+	var warnings []string
+	for _, repo := range sortedRepos(cfg.Presubmits) {
+		for _, job := range cfg.Presubmits[repo] {
+			if job.Trigger == "" || job.RerunCommand == "" {
+				continue
+			}
+			re, err := regexp.Compile(job.Trigger)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("presubmit %s (%s) in %s has an invalid trigger %q: %v", job.Name, l.jobPosition(job.SourcePath, job.Name), repo, job.Trigger, err))
+				continue
+			}
+			if !re.MatchString(job.RerunCommand) {
+				warnings = append(warnings, fmt.Sprintf("presubmit %s (%s) in %s has a rerun_command %q which does not match its trigger %q", job.Name, l.jobPosition(job.SourcePath, job.Name), repo, job.RerunCommand, job.Trigger))
+			}
+		}
+	}
+	return warnings
+}
+
+func tideReposWithoutJobs(cfg *config.Config) []string {
+	hasJobs := map[string]bool{}
+	for repo, jobs := range cfg.Presubmits {
+		hasJobs[repo] = hasJobs[repo] || len(jobs) > 0
+	}
+	for repo, jobs := range cfg.Postsubmits {
+		hasJobs[repo] = hasJobs[repo] || len(jobs) > 0
+	}
+
+	var warnings []string
+	for i, q := range cfg.Tide.Queries {
+		for _, repo := range q.Repos {
+			if !hasJobs[repo] {
+				warnings = append(warnings, fmt.Sprintf("tide query %d references repo %s which has no presubmits or postsubmits", i, repo))
+			}
+		}
+		for _, org := range q.Orgs {
+			found := false
+			for repo, ok := range hasJobs {
+				if ok && strings.HasPrefix(repo, org+"/") {
+					found = true
+					break
+				}
+			}
+			if !found {
+				warnings = append(warnings, fmt.Sprintf("tide query %d references org %s which has no repos with presubmits or postsubmits", i, org))
+			}
+		}
+	}
+	return warnings
+}
+
+func sortedRepos(presubmits map[string][]config.Presubmit) []string {
+	var repos []string
+	for repo := range presubmits {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	return repos
+}
@@ -0,0 +1,138 @@
+package checkconfig
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarnings(t *testing.T) {
+	cfg := &config.Config{
+		JobConfig: config.JobConfig{
+			Presubmits: map[string][]config.Presubmit{
+				"org/repo": {
+					{
+						JobBase:  config.JobBase{Name: "lint"},
+						Reporter: config.Reporter{Context: "ci"},
+					},
+					{
+						JobBase:      config.JobBase{Name: "unit"},
+						Reporter:     config.Reporter{Context: "ci"},
+						Trigger:      `(?m)^/test unit`,
+						RerunCommand: "/retest unit",
+					},
+					{
+						JobBase:      config.JobBase{Name: "e2e"},
+						Reporter:     config.Reporter{Context: "e2e"},
+						Trigger:      `(?m)^/test e2e`,
+						RerunCommand: "/test e2e",
+					},
+				},
+			},
+		},
+		ProwConfig: config.ProwConfig{
+			Tide: config.Tide{
+				Queries: []config.TideQuery{
+					{Repos: []string{"org/repo", "org/nojobs"}},
+					{Orgs: []string{"org", "other"}},
+				},
+			},
+		},
+	}
+	pcfg := &plugins.Configuration{
+		Plugins: map[string][]string{
+			"org/repo": {"lgtm", "not-a-plugin"},
+		},
+	}
+
+	assert.Equal(t, []string{
+		`unknown plugin "not-a-plugin" enabled for org/repo`,
+		`presubmits lint () and unit () in org/repo both report the context "ci"`,
+		`presubmit unit () in org/repo has a rerun_command "/retest unit" which does not match its trigger "(?m)^/test unit"`,
+		`tide query 0 references repo org/nojobs which has no presubmits or postsubmits`,
+		`tide query 1 references org other which has no repos with presubmits or postsubmits`,
+	}, Warnings(cfg, pcfg))
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, ioutil.WriteFile(configPath, []byte(`
+tide:
+  queries:
+  - repos:
+    - org/nojobs
+`), 0600))
+	pluginPath := filepath.Join(dir, "plugins.yaml")
+	require.NoError(t, ioutil.WriteFile(pluginPath, []byte(`
+plugins:
+  org/nojobs:
+  - lgtm
+`), 0600))
+	brokenPath := filepath.Join(dir, "broken.yaml")
+	require.NoError(t, ioutil.WriteFile(brokenPath, []byte("tide: [\n"), 0600))
+
+	out := &bytes.Buffer{}
+	o := &Options{ConfigPath: configPath, PluginConfig: pluginPath, Out: out}
+	require.NoError(t, o.Run())
+	assert.Contains(t, out.String(), "WARNING: tide query 0 references repo org/nojobs")
+
+	o.Strict = true
+	assert.Error(t, o.Run())
+
+	o = &Options{ConfigPath: brokenPath, Out: out}
+	err = o.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), brokenPath+":1")
+	assert.Contains(t, err.Error(), "line")
+
+	// errors without a line are located in the file
+	typePath := filepath.Join(dir, "type.yaml")
+	require.NoError(t, ioutil.WriteFile(typePath, []byte(`tide:
+  queries:
+  - orgs:
+    - org
+  - repos: org/repo
+`), 0600))
+	o = &Options{ConfigPath: typePath, Out: out}
+	err = o.Run()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), typePath+":5")
+}
+
+func TestLocate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	jobsPath := filepath.Join(dir, "jobs.yaml")
+	require.NoError(t, ioutil.WriteFile(jobsPath, []byte(`presubmits:
+  org/repo:
+  - name: lint
+    context: lint
+  - name: unit
+    context: unit
+  - name: lint
+    context: lint-again
+`), 0600))
+	l := newLocator()
+	paths := []string{filepath.Join(dir, "config.yaml"), jobsPath}
+
+	assert.Equal(t, jobsPath+":7", l.locate(errors.New("duplicated presubmit job: lint"), paths))
+	assert.Equal(t, jobsPath+":5", l.locate(errors.New("invalid presubmit job unit: bad"), paths))
+	assert.Equal(t, jobsPath+":2", l.locate(errors.New("json: cannot unmarshal array into Go struct field JobConfig.presubmits.org/repo of type map"), paths))
+	assert.Equal(t, "", l.locate(errors.New("something else"), paths))
+	assert.Equal(t, jobsPath+":5", l.jobPosition(jobsPath, "unit"))
+	assert.Equal(t, "", l.jobPosition("", "unit"))
+}
@@ -0,0 +1,191 @@
+package checkconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// yamlLineRE matches the line of YAML syntax errors
+	yamlLineRE = regexp.MustCompile(`yaml: line (\d+)`)
+	// fieldRE matches the path of the field of JSON unmarshaling errors, e.g.
+	// "Go struct field TideQuery.tide.queries.repos of type []string"
+	fieldRE = regexp.MustCompile(`Go struct field [^.\s]*\.(\S+) of type`)
+	// jobRE matches the name of the job of validation errors, e.g.
+	// "duplicated presubmit job: lint" or "invalid periodic job nightly: ..."
+	jobRE = regexp.MustCompile(`(presubmit|postsubmit|periodic)( job)?\s*:?\s+([\w.-]+)`)
+)
+
+// locator finds the lines of the fields and jobs of YAML files.
+type locator struct {
+	files map[string]*yaml.Node
+}
+
+func newLocator() *locator {
+	return &locator{files: map[string]*yaml.Node{}}
+}
+
+// root returns the parsed YAML file, or nil if it cannot be parsed.
+func (l *locator) root(path string) *yaml.Node {
+	if n, ok := l.files[path]; ok {
+		return n
+	}
+	var n *yaml.Node
+	if data, err := ioutil.ReadFile(path); err == nil { // #nosec
+		n = &yaml.Node{}
+		if err := yaml.Unmarshal(data, n); err != nil {
+			n = nil
+		}
+	}
+	l.files[path] = n
+	return n
+}
+
+// jobPosition returns the file:line of the named job, or just the file if the
+// job cannot be found in it.
+func (l *locator) jobPosition(path, name string) string {
+	if path == "" {
+		return ""
+	}
+	line := 0
+	if lines := nameLines(l.root(path), name); len(lines) > 0 {
+		line = lines[0]
+	}
+	return position(path, line)
+}
+
+// locate returns the file:line an error loading the files comes from, or the
+// empty string if it cannot be told. YAML syntax errors carry their line, the
+// line of fields which cannot be unmarshaled and of invalid jobs is looked up.
+func (l *locator) locate(err error, paths []string) string {
+	msg := err.Error()
+	// prefer the files named in the error
+	var candidates []string
+	for _, path := range paths {
+		if strings.Contains(msg, path) {
+			candidates = append(candidates, path)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = paths
+	}
+
+	if m := yamlLineRE.FindStringSubmatch(msg); m != nil && len(candidates) == 1 {
+		line, _ := strconv.Atoi(m[1])
+		return position(candidates[0], line)
+	}
+	if m := fieldRE.FindStringSubmatch(msg); m != nil {
+		keys := strings.Split(m[1], ".")
+		for _, path := range candidates {
+			if line := keyLine(l.root(path), keys); line > 0 {
+				return position(path, line)
+			}
+		}
+	}
+	if m := jobRE.FindStringSubmatch(msg); m != nil {
+		var found []string
+		for _, path := range candidates {
+			for _, line := range nameLines(l.root(path), m[3]) {
+				found = append(found, position(path, line))
+			}
+		}
+		if len(found) > 0 {
+			// a duplicated job is reported where it is defined again
+			if strings.Contains(msg, "duplicated") {
+				return found[len(found)-1]
+			}
+			return found[0]
+		}
+	}
+	return ""
+}
+
+// keyLine returns the line of the key at the end of the path of keys, or 0 if
+// there is none. Numeric keys index sequences, otherwise every item of
+// sequences is looked into.
+func keyLine(n *yaml.Node, keys []string) int {
+	if n == nil || len(keys) == 0 {
+		return 0
+	}
+	switch n.Kind {
+	case yaml.SequenceNode:
+		if i, err := strconv.Atoi(keys[0]); err == nil {
+			if i < 0 || i >= len(n.Content) {
+				return 0
+			}
+			if len(keys) == 1 {
+				return n.Content[i].Line
+			}
+			return keyLine(n.Content[i], keys[1:])
+		}
+		fallthrough
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			if line := keyLine(c, keys); line > 0 {
+				return line
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value != keys[0] {
+				continue
+			}
+			if len(keys) == 1 {
+				return n.Content[i].Line
+			}
+			if line := keyLine(n.Content[i+1], keys[1:]); line > 0 {
+				return line
+			}
+		}
+	}
+	return 0
+}
+
+// nameLines returns the lines of the mappings whose name is the given one, in
+// the order of the file.
+func nameLines(n *yaml.Node, name string) []int {
+	if n == nil {
+		return nil
+	}
+	var lines []int
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == "name" && n.Content[i+1].Value == name {
+				lines = append(lines, n.Content[i].Line)
+			}
+		}
+	}
+	for _, c := range n.Content {
+		lines = append(lines, nameLines(c, name)...)
+	}
+	return lines
+}
+
+// configFiles returns the config file and the job config files.
+func configFiles(configPath, jobConfigPath string) []string {
+	files := []string{configPath}
+	if jobConfigPath == "" {
+		return files
+	}
+	_ = filepath.Walk(jobConfigPath, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && (filepath.Ext(path) == ".yaml" || filepath.Ext(path) == ".yml") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files
+}
+
+// position formats a file and line, or just the file if the line is unknown.
+func position(path string, line int) string {
+	if line <= 0 {
+		return path
+	}
+	return path + ":" + strconv.Itoa(line)
+}
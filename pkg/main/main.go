@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/jenkins-x/lighthouse/pkg/checkconfig"
+	"github.com/jenkins-x/lighthouse/pkg/stale"
 	"github.com/jenkins-x/lighthouse/pkg/version"
 	"github.com/jenkins-x/lighthouse/pkg/webhook"
 )
@@ -13,6 +15,8 @@ func main() {
 	cmds := webhook.NewCmdWebhook()
 	cmds.Version = version.GetVersion()
 	cmds.SetVersionTemplate("{{printf .Version}}\n")
+	cmds.AddCommand(checkconfig.NewCmdCheckConfig())
+	cmds.AddCommand(stale.NewCmdStale())
 
 	err := cmds.Execute()
 	if err != nil {
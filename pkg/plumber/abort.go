@@ -0,0 +1,65 @@
+package plumber
+
+import (
+	"fmt"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	jxclient "github.com/jenkins-x/jx/pkg/client/clientset/versioned"
+	"github.com/pkg/errors"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	tektonclient "github.com/tektoncd/pipeline/pkg/client/clientset/versioned"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
+
+// Aborter aborts running pipelines
+type Aborter struct {
+	jxClient     jxclient.Interface
+	tektonClient tektonclient.Interface
+	namespace    string
+}
+
+// NewAborter creates a new aborter
+func NewAborter(jxClient jxclient.Interface, tektonClient tektonclient.Interface, namespace string) *Aborter {
+	return &Aborter{jxClient: jxClient, tektonClient: tektonClient, namespace: namespace}
+}
+
+// Abort cancels the Tekton PipelineRuns of the PipelineActivity with the given name and marks
+// the activity as aborted
+func (a *Aborter) Abort(name string) error {
+	activities := a.jxClient.JenkinsV1().PipelineActivities(a.namespace)
+	activity, err := activities.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get PipelineActivity %s", name)
+	}
+
+	spec := activity.Spec
+	selector := fmt.Sprintf("owner=%s,repository=%s,branch=%s,build=%s", spec.GitOwner, spec.GitRepository, spec.GitBranch, spec.Build)
+	if spec.Context != "" {
+		selector += ",context=" + spec.Context
+	}
+	runs, err := a.tektonClient.TektonV1alpha1().PipelineRuns(a.namespace).List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the PipelineRuns of %s", name)
+	}
+	for i := range runs.Items {
+		run := &runs.Items[i]
+		if run.Spec.Status == pipelinev1alpha1.PipelineRunSpecStatusCancelled {
+			continue
+		}
+		if condition := run.Status.GetCondition(apis.ConditionSucceeded); condition != nil && condition.Status != corev1.ConditionUnknown {
+			continue
+		}
+		run.Spec.Status = pipelinev1alpha1.PipelineRunSpecStatusCancelled
+		if _, err := a.tektonClient.TektonV1alpha1().PipelineRuns(a.namespace).Update(run); err != nil {
+			return errors.Wrapf(err, "failed to cancel PipelineRun %s", run.Name)
+		}
+	}
+
+	activity.Spec.Status = v1.ActivityStatusTypeAborted
+	if _, err := activities.Update(activity); err != nil {
+		return errors.Wrapf(err, "failed to mark PipelineActivity %s as aborted", name)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package plumber_test
+
+import (
+	"testing"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	jxfake "github.com/jenkins-x/jx/pkg/client/clientset/versioned/fake"
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
+
+func TestAbort(t *testing.T) {
+	ns := "jx"
+	runLabels := map[string]string{
+		"owner":      "org",
+		"repository": "repo",
+		"branch":     "PR-1",
+		"build":      "2",
+	}
+	otherBuildLabels := map[string]string{
+		"owner":      "org",
+		"repository": "repo",
+		"branch":     "PR-1",
+		"build":      "1",
+	}
+	finished := pipelinev1alpha1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{Name: "finished", Namespace: ns, Labels: runLabels},
+	}
+	finished.Status.SetCondition(&apis.Condition{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue})
+
+	jxClient := jxfake.NewSimpleClientset(&v1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{Name: "org-repo-pr-1-2", Namespace: ns},
+		Spec: v1.PipelineActivitySpec{
+			GitOwner:      "org",
+			GitRepository: "repo",
+			GitBranch:     "PR-1",
+			Build:         "2",
+			Status:        v1.ActivityStatusTypeRunning,
+		},
+	})
+	tektonClient := tektonfake.NewSimpleClientset(
+		&pipelinev1alpha1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: ns, Labels: runLabels}},
+		&pipelinev1alpha1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "other-build", Namespace: ns, Labels: otherBuildLabels}},
+		&finished,
+	)
+
+	aborter := plumber.NewAborter(jxClient, tektonClient, ns)
+	require.NoError(t, aborter.Abort("org-repo-pr-1-2"))
+
+	runs := tektonClient.TektonV1alpha1().PipelineRuns(ns)
+	running, err := runs.Get("running", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, pipelinev1alpha1.PipelineRunSpecStatusCancelled, running.Spec.Status)
+	for _, name := range []string{"other-build", "finished"} {
+		run, err := runs.Get(name, metav1.GetOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, run.Spec.Status, name)
+	}
+
+	activity, err := jxClient.JenkinsV1().PipelineActivities(ns).Get("org-repo-pr-1-2", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, v1.ActivityStatusTypeAborted, activity.Spec.Status)
+
+	assert.Error(t, aborter.Abort("missing"))
+}
@@ -0,0 +1,98 @@
+package plumber
+
+import (
+	"strings"
+	"time"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	jxclient "github.com/jenkins-x/jx/pkg/client/clientset/versioned"
+	jxinformers "github.com/jenkins-x/jx/pkg/client/informers/externalversions"
+	jxlisters "github.com/jenkins-x/jx/pkg/client/listers/jenkins.io/v1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// RepoIndex indexes PipelineActivities by org/repo
+	RepoIndex = "repo"
+
+	// activityResyncPeriod is how often the informer resyncs its cache
+	activityResyncPeriod = 10 * time.Minute
+)
+
+// ActivityCache is a shared informer cache of the PipelineActivities of a namespace, indexed
+// by repository
+type ActivityCache struct {
+	informer  cache.SharedIndexInformer
+	lister    jxlisters.PipelineActivityLister
+	namespace string
+}
+
+// NewActivityCache creates a cache of the PipelineActivities of the namespace. It must be
+// started before it is used.
+func NewActivityCache(jxClient jxclient.Interface, namespace string) (*ActivityCache, error) {
+	factory := jxinformers.NewSharedInformerFactoryWithOptions(jxClient, activityResyncPeriod, jxinformers.WithNamespace(namespace))
+	activities := factory.Jenkins().V1().PipelineActivities()
+	informer := activities.Informer()
+	err := informer.AddIndexers(cache.Indexers{
+		RepoIndex: repoIndexFunc,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to add the PipelineActivity indexers")
+	}
+	return &ActivityCache{
+		informer:  informer,
+		lister:    activities.Lister(),
+		namespace: namespace,
+	}, nil
+}
+
+// Start starts the informer and waits for its cache to sync. The informer stops when the
+// channel is closed.
+func (c *ActivityCache) Start(stopCh <-chan struct{}) error {
+	go c.informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
+		return errors.New("failed to sync the PipelineActivity cache")
+	}
+	return nil
+}
+
+// HasSynced returns true once the cache has been filled
+func (c *ActivityCache) HasSynced() bool {
+	return c.informer.HasSynced()
+}
+
+// List lists the cached PipelineActivities matching the selector
+func (c *ActivityCache) List(selector labels.Selector) ([]*v1.PipelineActivity, error) {
+	return c.lister.PipelineActivities(c.namespace).List(selector)
+}
+
+// ByIndex lists the cached PipelineActivities with the given value for an index, e.g. the
+// activities of a repository using RepoIndex and RepoKey
+func (c *ActivityCache) ByIndex(index, value string) ([]*v1.PipelineActivity, error) {
+	objs, err := c.informer.GetIndexer().ByIndex(index, value)
+	if err != nil {
+		return nil, err
+	}
+	answer := make([]*v1.PipelineActivity, 0, len(objs))
+	for _, obj := range objs {
+		if activity, ok := obj.(*v1.PipelineActivity); ok {
+			answer = append(answer, activity)
+		}
+	}
+	return answer, nil
+}
+
+// RepoKey returns the RepoIndex value of a repository
+func RepoKey(org, repo string) string {
+	return strings.ToLower(org + "/" + repo)
+}
+
+func repoIndexFunc(obj interface{}) ([]string, error) {
+	activity, ok := obj.(*v1.PipelineActivity)
+	if !ok {
+		return nil, nil
+	}
+	return []string{RepoKey(activity.Spec.GitOwner, activity.Spec.GitRepository)}, nil
+}
@@ -0,0 +1,161 @@
+package plumber
+
+import (
+	"sort"
+	"testing"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	jxfake "github.com/jenkins-x/jx/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTestActivity(name, ns, owner, repo, branch, context string, labels map[string]string) *v1.PipelineActivity {
+	return &v1.PipelineActivity{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    labels,
+		},
+		Spec: v1.PipelineActivitySpec{
+			GitOwner:      owner,
+			GitRepository: repo,
+			GitBranch:     branch,
+			Context:       context,
+			Pipeline:      owner + "/" + repo + "/" + branch,
+			Status:        v1.ActivityStatusTypeRunning,
+		},
+	}
+}
+
+func newTestActivities(ns string) []runtime.Object {
+	pr1 := newTestActivity("org-repo-pr-1-1", ns, "org", "repo", "PR-1", "unit", map[string]string{PlumberJobTypeLabel: string(PresubmitJob)})
+	pr1.Annotations = map[string]string{PullsAnnotation: "1:sha1"}
+	pr2 := newTestActivity("org-repo-pr-2-1", ns, "org", "repo", "PR-2", "lint", map[string]string{PlumberJobTypeLabel: string(PresubmitJob)})
+	pr2.Annotations = map[string]string{PullsAnnotation: "2:sha2"}
+	return []runtime.Object{
+		pr1,
+		pr2,
+		newTestActivity("org-repo-master-1", ns, "org", "repo", "master", "release", map[string]string{PlumberJobTypeLabel: string(PostsubmitJob)}),
+		newTestActivity("org-other-pr-1-1", ns, "org", "other", "PR-1", "unit", nil),
+		newTestActivity("org-repo-pr-1-2", "elsewhere", "org", "repo", "PR-1", "unit", nil),
+	}
+}
+
+func startTestCache(t *testing.T, ns string) (*ActivityCache, *jxfake.Clientset, chan struct{}) {
+	jxClient := jxfake.NewSimpleClientset(newTestActivities(ns)...)
+	c, err := NewActivityCache(jxClient, ns)
+	require.NoError(t, err)
+	stopCh := make(chan struct{})
+	require.NoError(t, c.Start(stopCh))
+	return c, jxClient, stopCh
+}
+
+func activityNames(activities []*v1.PipelineActivity) []string {
+	var names []string
+	for _, a := range activities {
+		names = append(names, a.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestActivityCacheByIndex(t *testing.T) {
+	c, _, stopCh := startTestCache(t, "jx")
+	defer close(stopCh)
+
+	testCases := []struct {
+		index    string
+		value    string
+		expected []string
+	}{
+		{
+			index:    RepoIndex,
+			value:    RepoKey("Org", "Repo"),
+			expected: []string{"org-repo-master-1", "org-repo-pr-1-1", "org-repo-pr-2-1"},
+		},
+		{
+			index:    RepoIndex,
+			value:    RepoKey("org", "other"),
+			expected: []string{"org-other-pr-1-1"},
+		},
+		{
+			index: RepoIndex,
+			value: RepoKey("org", "missing"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.index+"/"+tc.value, func(t *testing.T) {
+			activities, err := c.ByIndex(tc.index, tc.value)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, activityNames(activities))
+		})
+	}
+}
+
+func TestPipelineBuilderListHonoursSelector(t *testing.T) {
+	ns := "jx"
+	c, jxClient, stopCh := startTestCache(t, ns)
+	defer close(stopCh)
+
+	testCases := []struct {
+		name     string
+		builder  *PipelineBuilder
+		selector string
+		fields   string
+		expected []string
+	}{
+		{
+			name:     "cached without selector",
+			builder:  &PipelineBuilder{jxClient: jxClient, namespace: ns, cache: c},
+			expected: []string{"org-other-pr-1-1", "org-repo-master-1", "org-repo-pr-1-1", "org-repo-pr-2-1"},
+		},
+		{
+			name:     "cached with selector",
+			builder:  &PipelineBuilder{jxClient: jxClient, namespace: ns, cache: c},
+			selector: PlumberJobTypeLabel + "=" + string(PresubmitJob),
+			expected: []string{"org-repo-pr-1-1", "org-repo-pr-2-1"},
+		},
+		{
+			name:     "cached with field selector",
+			builder:  &PipelineBuilder{jxClient: jxClient, namespace: ns, cache: c},
+			fields:   "metadata.name=org-repo-pr-2-1",
+			expected: []string{"org-repo-pr-2-1"},
+		},
+		{
+			name:     "cached by repository",
+			builder:  &PipelineBuilder{jxClient: jxClient, namespace: ns, cache: c},
+			selector: OrgLabel + "=org," + RepoLabel + "=repo",
+			expected: []string{"org-repo-master-1", "org-repo-pr-1-1", "org-repo-pr-2-1"},
+		},
+		{
+			name:     "cached by repository with selector",
+			builder:  &PipelineBuilder{jxClient: jxClient, namespace: ns, cache: c},
+			selector: OrgLabel + "=org," + RepoLabel + "=repo," + PlumberJobTypeLabel + "=" + string(PresubmitJob),
+			expected: []string{"org-repo-pr-1-1", "org-repo-pr-2-1"},
+		},
+		{
+			name:     "uncached with selector",
+			builder:  &PipelineBuilder{jxClient: jxClient, namespace: ns},
+			selector: PlumberJobTypeLabel + "=" + string(PostsubmitJob),
+			expected: []string{"org-repo-master-1"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := tc.builder.List(metav1.ListOptions{LabelSelector: tc.selector, FieldSelector: tc.fields})
+			require.NoError(t, err)
+			var names []string
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			sort.Strings(names)
+			assert.Equal(t, tc.expected, names)
+		})
+	}
+
+	_, err := (&PipelineBuilder{jxClient: jxClient, namespace: ns, cache: c}).List(metav1.ListOptions{LabelSelector: "!!"})
+	assert.Error(t, err)
+}
@@ -18,4 +18,25 @@ const (
 	// job names can be arbitrarily long, this is added as
 	// an annotation instead of a label.
 	PlumberJobAnnotation = "lighthouse.jenkins-x.io/job"
+	// OrgLabel is added in resources created by lighthouse and
+	// carries the org of the repository the job runs against.
+	OrgLabel = "lighthouse.jenkins-x.io/refs.org"
+	// RepoLabel is added in resources created by lighthouse and
+	// carries the name of the repository the job runs against.
+	RepoLabel = "lighthouse.jenkins-x.io/refs.repo"
+)
+
+const (
+	// BaseRefAnnotation is added to the PipelineActivities created by lighthouse and
+	// carries the base ref the job runs against.
+	BaseRefAnnotation = "lighthouse.jenkins-x.io/baseRef"
+	// BaseSHAAnnotation is added to the PipelineActivities created by lighthouse and
+	// carries the base SHA the job runs against.
+	BaseSHAAnnotation = "lighthouse.jenkins-x.io/baseSHA"
+	// PullsAnnotation is added to the PipelineActivities created by lighthouse and
+	// carries the comma separated number:sha pairs of the pull requests the job runs against.
+	PullsAnnotation = "lighthouse.jenkins-x.io/pulls"
+	// ContextAnnotation is added to the PipelineActivities created by lighthouse and
+	// carries the status context the job reports to.
+	ContextAnnotation = "lighthouse.jenkins-x.io/context"
 )
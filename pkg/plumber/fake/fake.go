@@ -7,6 +7,8 @@ import (
 	"github.com/jenkins-x/jx/pkg/tekton/metapipeline"
 	"github.com/jenkins-x/lighthouse/pkg/plumber"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -40,11 +42,27 @@ func (p *Plumber) Create(po *plumber.PipelineOptions, metapipelineClient metapip
 func (p *Plumber) PrependReactor(s string, s2 string, i func(plumberJob *plumber.PipelineOptions) (handled bool, ret *plumber.PipelineOptions, err error)) {
 }
 
-// List lists the pipelines and ttheir options
+// List lists the pipelines and ttheir options matching the label selector and metadata.name field selector.
+// Like the real plumber, the pipelines carry the labels of the org and repo of their refs.
 func (p *Plumber) List(opts metav1.ListOptions) (*plumber.PipelineOptionsList, error) {
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	fieldSelector, err := fields.ParseSelector(opts.FieldSelector)
+	if err != nil {
+		return nil, err
+	}
 	list := plumber.PipelineOptionsList{}
 	for _, p := range p.Pipelines {
-		list.Items = append(list.Items, *p)
+		set := labels.Set{}
+		if refs := p.Spec.Refs; refs != nil {
+			set[plumber.OrgLabel] = refs.Org
+			set[plumber.RepoLabel] = refs.Repo
+		}
+		if selector.Matches(labels.Merge(set, p.Labels)) && fieldSelector.Matches(fields.Set{"metadata.name": p.Name}) {
+			list.Items = append(list.Items, *p)
+		}
 	}
 	return &list, nil
 }
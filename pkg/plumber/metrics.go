@@ -0,0 +1,22 @@
+package plumber
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var pipelineCreationCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "lighthouse_pipeline_creations",
+	Help: "A counter of the pipelines lighthouse attempted to create, by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(pipelineCreationCounter)
+}
+
+func countPipelineCreation(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	pipelineCreationCounter.WithLabelValues(result).Inc()
+}
@@ -14,22 +14,40 @@ import (
 	"github.com/sirupsen/logrus"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/retry"
 )
 
 // PipelineBuilder default builder
 type PipelineBuilder struct {
 	jxClient  jxclient.Interface
 	namespace string
+	cache     *ActivityCache
 }
 
 // NewPlumber creates a new builder
 func NewPlumber(jxClient jxclient.Interface, namespace string) (Plumber, error) {
-	b := &PipelineBuilder{jxClient, namespace}
+	b := &PipelineBuilder{jxClient: jxClient, namespace: namespace}
+	return b, nil
+}
+
+// NewPlumberWithCache creates a new builder which lists pipelines from the cache once it has synced
+func NewPlumberWithCache(jxClient jxclient.Interface, namespace string, cache *ActivityCache) (Plumber, error) {
+	b := &PipelineBuilder{jxClient: jxClient, namespace: namespace, cache: cache}
 	return b, nil
 }
 
 // Create creates a pipeline
 func (b *PipelineBuilder) Create(request *PipelineOptions, metapipelineClient metapipeline.Client, repository scm.Repository) (*PipelineOptions, error) {
+	answer, err := b.create(request, metapipelineClient, repository)
+	countPipelineCreation(err)
+	return answer, err
+}
+
+func (b *PipelineBuilder) create(request *PipelineOptions, metapipelineClient metapipeline.Client, repository scm.Repository) (*PipelineOptions, error) {
 	spec := &request.Spec
 
 	name := repository.Name
@@ -98,9 +116,98 @@ func (b *PipelineBuilder) Create(request *PipelineOptions, metapipelineClient me
 	if err != nil {
 		return request, errors.Wrap(err, "unable to apply Tekton CRDs")
 	}
+
+	// the pipeline is running by now so failing to label it is not a failure to create it,
+	// which would have it triggered again, although it cannot be listed by repository nor
+	// converted back to its job faithfully
+	err = b.stampActivity(pipelineActivity.Name, request)
+	if err != nil {
+		l.WithError(err).Errorf("unable to add the job labels and annotations to PipelineActivity %s", pipelineActivity.Name)
+	}
 	return request, nil
 }
 
+// stampActivity adds the labels and annotations of the request, and annotations describing its refs
+// and context, to the PipelineActivity so that ToPipelineOptions can convert it back faithfully
+func (b *PipelineBuilder) stampActivity(name string, request *PipelineOptions) error {
+	activities := b.jxClient.JenkinsV1().PipelineActivities(b.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		activity, err := activities.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if activity.Labels == nil {
+			activity.Labels = map[string]string{}
+		}
+		for k, v := range request.Labels {
+			activity.Labels[k] = v
+		}
+		activity.Labels[PlumberJobTypeLabel] = string(request.Spec.Type)
+		for k, v := range refsLabels(request.Spec.Refs) {
+			activity.Labels[k] = v
+		}
+		if activity.Annotations == nil {
+			activity.Annotations = map[string]string{}
+		}
+		for k, v := range request.Annotations {
+			activity.Annotations[k] = v
+		}
+		for k, v := range jobAnnotations(&request.Spec) {
+			activity.Annotations[k] = v
+		}
+		_, err = activities.Update(activity)
+		return err
+	})
+}
+
+// refsLabels returns the labels of the org and repository of the refs, so that the pipelines of a
+// repository can be listed with RepoSelector. Names which are not valid label values are left out.
+func refsLabels(refs *Refs) map[string]string {
+	answer := map[string]string{}
+	if refs == nil {
+		return answer
+	}
+	if len(validation.IsValidLabelValue(refs.Org)) == 0 {
+		answer[OrgLabel] = refs.Org
+	}
+	if len(validation.IsValidLabelValue(refs.Repo)) == 0 {
+		answer[RepoLabel] = refs.Repo
+	}
+	return answer
+}
+
+// RepoSelector returns the label selector of the pipelines of a repository
+func RepoSelector(org, repo string) (string, error) {
+	for _, value := range []string{org, repo} {
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return "", errors.Errorf("invalid name %q: %s", value, strings.Join(errs, ", "))
+		}
+	}
+	return labels.SelectorFromSet(labels.Set{OrgLabel: org, RepoLabel: repo}).String(), nil
+}
+
+// jobAnnotations returns the annotations describing the job, its refs and context
+func jobAnnotations(spec *PipelineOptionsSpec) map[string]string {
+	annotations := map[string]string{
+		PlumberJobAnnotation: spec.Job,
+	}
+	if spec.Context != "" {
+		annotations[ContextAnnotation] = spec.Context
+	}
+	if spec.Refs != nil {
+		annotations[BaseRefAnnotation] = spec.Refs.BaseRef
+		annotations[BaseSHAAnnotation] = spec.Refs.BaseSHA
+		var pulls []string
+		for _, pull := range spec.Refs.Pulls {
+			pulls = append(pulls, fmt.Sprintf("%d:%s", pull.Number, pull.SHA))
+		}
+		if len(pulls) > 0 {
+			annotations[PullsAnnotation] = strings.Join(pulls, ",")
+		}
+	}
+	return annotations
+}
+
 func (b *PipelineBuilder) getBranch(spec *PipelineOptionsSpec) string {
 	branch := spec.Refs.BaseRef
 	if spec.Type == PostsubmitJob {
@@ -131,16 +238,38 @@ func (b *PipelineBuilder) getPullRefs(sourceURL string, spec *PipelineOptionsSpe
 	return pullRef
 }
 
-// List list current pipelines
+// List lists the current pipelines matching the label selector and the metadata.name field selector
+// of the options, using the cache if it has synced
 func (b *PipelineBuilder) List(opts metav1.ListOptions) (*PipelineOptionsList, error) {
-	list, err := b.jxClient.JenkinsV1().PipelineActivities(b.namespace).List(metav1.ListOptions{})
+	answer := &PipelineOptionsList{}
+	if b.cache != nil && b.cache.HasSynced() {
+		selector, err := labels.Parse(opts.LabelSelector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid label selector %q", opts.LabelSelector)
+		}
+		fieldSelector, err := fields.ParseSelector(opts.FieldSelector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid field selector %q", opts.FieldSelector)
+		}
+		activities, err := b.listCached(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, pa := range activities {
+			if fieldSelector.Matches(fields.Set{"metadata.name": pa.Name}) {
+				answer.Items = append(answer.Items, ToPipelineOptions(pa))
+			}
+		}
+		return answer, nil
+	}
+
+	list, err := b.jxClient.JenkinsV1().PipelineActivities(b.namespace).List(metav1.ListOptions{LabelSelector: opts.LabelSelector, FieldSelector: opts.FieldSelector})
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	answer := &PipelineOptionsList{}
 	for _, pa := range list.Items {
 		item := ToPipelineOptions(&pa)
 		answer.Items = append(answer.Items, item)
@@ -148,8 +277,111 @@ func (b *PipelineBuilder) List(opts metav1.ListOptions) (*PipelineOptionsList, e
 	return answer, nil
 }
 
+// listCached lists the cached activities matching the selector. The activities of a repository selected
+// by RepoSelector are looked up in the RepoIndex, which also finds those created before the activities
+// were labelled with their repository.
+func (b *PipelineBuilder) listCached(selector labels.Selector) ([]*v1.PipelineActivity, error) {
+	requirements, _ := selector.Requirements()
+	var org, repo string
+	rest := labels.NewSelector()
+	for i := range requirements {
+		r := &requirements[i]
+		equals := (r.Operator() == selection.Equals || r.Operator() == selection.DoubleEquals) && r.Values().Len() == 1
+		switch {
+		case equals && r.Key() == OrgLabel:
+			org = r.Values().List()[0]
+		case equals && r.Key() == RepoLabel:
+			repo = r.Values().List()[0]
+		default:
+			rest = rest.Add(*r)
+		}
+	}
+	if org == "" || repo == "" {
+		return b.cache.List(selector)
+	}
+
+	activities, err := b.cache.ByIndex(RepoIndex, RepoKey(org, repo))
+	if err != nil {
+		return nil, err
+	}
+	var answer []*v1.PipelineActivity
+	for _, pa := range activities {
+		if pa.Spec.GitOwner == org && pa.Spec.GitRepository == repo && rest.Matches(labels.Set(pa.Labels)) {
+			answer = append(answer, pa)
+		}
+	}
+	return answer, nil
+}
+
 // ToPipelineOptions converts the PipelineActivity to a PipelineOptions object
 func ToPipelineOptions(activity *v1.PipelineActivity) PipelineOptions {
+	if _, ok := activity.Labels[PlumberJobTypeLabel]; ok {
+		return stampedPipelineOptions(activity)
+	}
+	return legacyPipelineOptions(activity)
+}
+
+// stampedPipelineOptions converts a PipelineActivity created by lighthouse using the job
+// labels and annotations added when it was created
+func stampedPipelineOptions(activity *v1.PipelineActivity) PipelineOptions {
+	spec := activity.Spec
+	annotations := activity.Annotations
+
+	ref := &Refs{
+		Org:      spec.GitOwner,
+		Repo:     spec.GitRepository,
+		RepoLink: spec.GitURL,
+		BaseRef:  annotations[BaseRefAnnotation],
+		BaseSHA:  annotations[BaseSHAAnnotation],
+	}
+	if ref.BaseSHA == "" {
+		ref.BaseSHA = spec.BaseSHA
+	}
+	for _, p := range strings.Split(annotations[PullsAnnotation], ",") {
+		parts := strings.SplitN(p, ":", 2)
+		n, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		pull := Pull{
+			Number: n,
+			Ref:    fmt.Sprintf("pull/%d/head", n),
+		}
+		if len(parts) == 2 {
+			pull.SHA = parts[1]
+		}
+		if spec.GitBranch == fmt.Sprintf("PR-%d", n) {
+			pull.Title = spec.PullTitle
+			pull.CommitLink = spec.LastCommitURL
+		}
+		ref.Pulls = append(ref.Pulls, pull)
+	}
+
+	job := annotations[PlumberJobAnnotation]
+	if job == "" {
+		job = spec.Pipeline
+	}
+	context := annotations[ContextAnnotation]
+	if context == "" {
+		context = spec.Context
+	}
+
+	return PipelineOptions{
+		ObjectMeta: activity.ObjectMeta,
+		Spec: PipelineOptionsSpec{
+			Type:      PipelineKind(activity.Labels[PlumberJobTypeLabel]),
+			Namespace: activity.Namespace,
+			Job:       job,
+			Refs:      ref,
+			Context:   context,
+		},
+		Status: PipelineStatus{State: ToPipelineState(spec.Status)},
+	}
+}
+
+// legacyPipelineOptions converts a PipelineActivity without the job labels and annotations,
+// guessing the job type and refs from its branch
+func legacyPipelineOptions(activity *v1.PipelineActivity) PipelineOptions {
 	spec := activity.Spec
 	baseRef := "master"
 
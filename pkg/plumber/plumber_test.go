@@ -22,6 +22,12 @@ func TestPipelineActivityConvert(t *testing.T) {
 		{
 			name: "batch",
 		},
+		{
+			name: "stamped-presubmit",
+		},
+		{
+			name: "stamped-postsubmit",
+		},
 	}
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -0,0 +1,105 @@
+package plumber
+
+import (
+	"testing"
+
+	v1 "github.com/jenkins-x/jx/pkg/apis/jenkins.io/v1"
+	jxfake "github.com/jenkins-x/jx/pkg/client/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStampActivityRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name   string
+		branch string
+		spec   PipelineOptionsSpec
+	}{
+		{
+			name:   "postsubmit on main",
+			branch: "main",
+			spec: PipelineOptionsSpec{
+				Type:    PostsubmitJob,
+				Job:     "release",
+				Context: "release",
+				Refs:    &Refs{Org: "org", Repo: "repo", BaseRef: "main", BaseSHA: "base"},
+			},
+		},
+		{
+			name:   "batch on a release branch",
+			branch: "batch",
+			spec: PipelineOptionsSpec{
+				Type:    BatchJob,
+				Job:     "unit",
+				Context: "unit",
+				Refs: &Refs{
+					Org:     "org",
+					Repo:    "repo",
+					BaseRef: "release-1.0",
+					BaseSHA: "base",
+					Pulls: []Pull{
+						{Number: 1, SHA: "sha1", Ref: "pull/1/head"},
+						{Number: 2, SHA: "sha2", Ref: "pull/2/head"},
+					},
+				},
+			},
+		},
+		{
+			name:   "periodic",
+			branch: "master",
+			spec: PipelineOptionsSpec{
+				Type: PeriodicJob,
+				Job:  "nightly",
+				Refs: &Refs{Org: "org", Repo: "repo", BaseRef: "master", BaseSHA: "base"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := "jx"
+			jxClient := jxfake.NewSimpleClientset(&v1.PipelineActivity{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "org-repo-1",
+					Namespace: ns,
+					Labels:    map[string]string{"build": "1"},
+				},
+				Spec: v1.PipelineActivitySpec{
+					GitOwner:      "org",
+					GitRepository: "repo",
+					GitBranch:     tc.branch,
+					Pipeline:      "org/repo/" + tc.branch,
+					Status:        v1.ActivityStatusTypeRunning,
+				},
+			})
+			b := &PipelineBuilder{jxClient: jxClient, namespace: ns}
+			request := &PipelineOptions{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"extra": "label"}},
+				Spec:       tc.spec,
+			}
+			require.NoError(t, b.stampActivity("org-repo-1", request))
+
+			activity, err := jxClient.JenkinsV1().PipelineActivities(ns).Get("org-repo-1", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "1", activity.Labels["build"])
+			assert.Equal(t, "label", activity.Labels["extra"])
+			assert.Equal(t, "org", activity.Labels[OrgLabel])
+			assert.Equal(t, "repo", activity.Labels[RepoLabel])
+
+			actual := ToPipelineOptions(activity)
+			assert.Equal(t, tc.spec.Type, actual.Spec.Type)
+			assert.Equal(t, tc.spec.Job, actual.Spec.Job)
+			assert.Equal(t, tc.spec.Context, actual.Spec.Context)
+			assert.Equal(t, tc.spec.Refs.BaseRef, actual.Spec.Refs.BaseRef)
+			assert.Equal(t, tc.spec.Refs.BaseSHA, actual.Spec.Refs.BaseSHA)
+			assert.Equal(t, tc.spec.Refs.Pulls, actual.Spec.Refs.Pulls)
+			assert.Equal(t, RunningState, actual.Status.State)
+		})
+	}
+}
+
+func TestStampActivityMissing(t *testing.T) {
+	b := &PipelineBuilder{jxClient: jxfake.NewSimpleClientset(), namespace: "jx"}
+	request := &PipelineOptions{Spec: PipelineOptionsSpec{Type: PeriodicJob, Job: "nightly"}}
+	assert.Error(t, b.stampActivity("missing", request))
+}
@@ -71,6 +71,7 @@ type ProwConfig struct {
 	BranchProtection BranchProtection      `json:"branch-protection,omitempty"`
 	Orgs             map[string]org.Config `json:"orgs,omitempty"`
 	Gerrit           Gerrit                `json:"gerrit,omitempty"`
+	Stale            Stale                 `json:"stale,omitempty"`
 
 	// TODO: Move this out of the main config.
 	JenkinsOperators []JenkinsOperator `json:"jenkins_operators,omitempty"`
@@ -943,6 +944,28 @@ func parseProwConfig(c *Config) error {
 		}
 		c.Tide.StatusUpdatePeriod = period
 	}
+	if c.Tide.MaxWaitString != "" {
+		maxWait, err := time.ParseDuration(c.Tide.MaxWaitString)
+		if err != nil {
+			return fmt.Errorf("cannot parse duration for tide.max_wait: %v", err)
+		}
+		c.Tide.MaxWait = maxWait
+	}
+	if c.Tide.MergeFailures.CommentPeriodString == "" {
+		c.Tide.MergeFailures.CommentPeriod = time.Hour
+	} else {
+		period, err := time.ParseDuration(c.Tide.MergeFailures.CommentPeriodString)
+		if err != nil {
+			return fmt.Errorf("cannot parse duration for tide.merge_failures.comment_period: %v", err)
+		}
+		c.Tide.MergeFailures.CommentPeriod = period
+	}
+	for key, windows := range c.Tide.MergeWindows {
+		if err := windows.parse(); err != nil {
+			return fmt.Errorf("tide merge windows for %q are invalid: %v", key, err)
+		}
+		c.Tide.MergeWindows[key] = windows
+	}
 
 	if c.Tide.MaxGoroutines == 0 {
 		c.Tide.MaxGoroutines = 20
@@ -958,6 +981,26 @@ func parseProwConfig(c *Config) error {
 			return fmt.Errorf("merge type %q for %s is not a valid type", method, name)
 		}
 	}
+	for name, methods := range c.Tide.AllowedMergeTypes {
+		for _, method := range methods {
+			if method != gitprovider.MergeMerge &&
+				method != gitprovider.MergeRebase &&
+				method != gitprovider.MergeSquash {
+				return fmt.Errorf("allowed merge type %q for %s is not a valid type", method, name)
+			}
+		}
+	}
+	for name, depth := range c.Tide.MergeTrainDepthMap {
+		if depth < 0 {
+			return fmt.Errorf("merge train depth %d for %s cannot be negative", depth, name)
+		}
+	}
+	for name, tmpl := range c.Tide.MergeTemplate {
+		if err := tmpl.parse(); err != nil {
+			return fmt.Errorf("merge commit template for %s is invalid: %v", name, err)
+		}
+		c.Tide.MergeTemplate[name] = tmpl
+	}
 
 	for i, tq := range c.Tide.Queries {
 		if err := tq.Validate(); err != nil {
@@ -965,6 +1008,12 @@ func parseProwConfig(c *Config) error {
 		}
 	}
 
+	for i := range c.Stale.Queries {
+		if err := c.Stale.Queries[i].parse(); err != nil {
+			return fmt.Errorf("stale query (index %d) is invalid: %v", i, err)
+		}
+	}
+
 	if c.PlumberJobNamespace == "" {
 		c.PlumberJobNamespace = "default"
 	}
@@ -1203,6 +1203,34 @@ periodics:
 				},
 			},
 		},
+		{
+			name: "valid stale query",
+			prowConfig: `
+stale:
+  queries:
+  - repos:
+    - org/repo
+    stale_after: 720h`,
+		},
+		{
+			name: "reject stale query with invalid repo",
+			prowConfig: `
+stale:
+  queries:
+  - repos:
+    - org`,
+			expectError: true,
+		},
+		{
+			name: "reject stale query with invalid duration",
+			prowConfig: `
+stale:
+  queries:
+  - repos:
+    - org/repo
+    close_after: 30d`,
+			expectError: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -1459,3 +1487,95 @@ func TestSecretAgentLoading(t *testing.T) {
 	}
 
 }
+
+func TestTidePriority(t *testing.T) {
+	tide := &Tide{PriorityLabels: []string{"priority/critical", "priority/high"}}
+	testCases := []struct {
+		labels   []string
+		expected int
+	}{
+		{
+			labels:   []string{"lgtm", "priority/critical"},
+			expected: 0,
+		},
+		{
+			labels:   []string{"priority/high", "priority/critical"},
+			expected: 0,
+		},
+		{
+			labels:   []string{"priority/high"},
+			expected: 1,
+		},
+		{
+			labels:   []string{"lgtm"},
+			expected: 2,
+		},
+		{
+			expected: 2,
+		},
+	}
+	for _, tc := range testCases {
+		if actual := tide.Priority(tc.labels); actual != tc.expected {
+			t.Errorf("expected priority %d for labels %v but got %d", tc.expected, tc.labels, actual)
+		}
+	}
+}
+
+func TestTideMaxWaitParsing(t *testing.T) {
+	c, err := LoadYAMLConfig([]byte("tide:\n  max_wait: 48h\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Tide.MaxWait != 48*time.Hour {
+		t.Errorf("expected max_wait of 48h but got %v", c.Tide.MaxWait)
+	}
+
+	if _, err := LoadYAMLConfig([]byte("tide:\n  max_wait: two days\n")); err == nil {
+		t.Error("expected an error parsing an invalid max_wait")
+	}
+}
+
+func TestTideMergeFailuresParsing(t *testing.T) {
+	c, err := LoadYAMLConfig([]byte("tide:\n  merge_failures:\n    comment: true\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Tide.MergeFailures.CommentPeriod != time.Hour {
+		t.Errorf("expected the default comment_period of 1h but got %v", c.Tide.MergeFailures.CommentPeriod)
+	}
+
+	c, err = LoadYAMLConfig([]byte("tide:\n  merge_failures:\n    comment_period: 30m\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Tide.MergeFailures.CommentPeriod != 30*time.Minute {
+		t.Errorf("expected a comment_period of 30m but got %v", c.Tide.MergeFailures.CommentPeriod)
+	}
+
+	if _, err := LoadYAMLConfig([]byte("tide:\n  merge_failures:\n    comment_period: soon\n")); err == nil {
+		t.Error("expected an error parsing an invalid comment_period")
+	}
+}
+
+func TestTideMergeTrainDepth(t *testing.T) {
+	c, err := LoadYAMLConfig([]byte("tide:\n  merge_train_depth:\n    org: 3\n    org/repo: 5\n    org/other: 0\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		repo     string
+		expected int
+	}{
+		{repo: "repo", expected: 5},
+		{repo: "other", expected: 0},
+		{repo: "third", expected: 3},
+	} {
+		if depth := c.Tide.MergeTrainDepth("org", tc.repo); depth != tc.expected {
+			t.Errorf("expected a merge train depth of %d for org/%s but got %d", tc.expected, tc.repo, depth)
+		}
+	}
+
+	if _, err := LoadYAMLConfig([]byte("tide:\n  merge_train_depth:\n    org: -1\n")); err == nil {
+		t.Error("expected an error parsing a negative merge train depth")
+	}
+}
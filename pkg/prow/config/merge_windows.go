@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/robfig/cron.v2"
+)
+
+// maxMergeWindowSteps bounds the search for the next time merges are allowed.
+const maxMergeWindowSteps = 100
+
+// TideMergeWindows declares when tide may merge the PRs of a branch.
+type TideMergeWindows struct {
+	// Allowed are the windows in which merges are allowed. Merges are allowed at
+	// any time outside freezes if there are none.
+	Allowed []TideMergeWindow `json:"allowed,omitempty"`
+	// Freezes are periods in which merges are not allowed, even within an
+	// allowed window, e.g. for a release.
+	Freezes []TideMergeFreeze `json:"freezes,omitempty"`
+	// ExemptLabel is an optional label of PRs which may be merged outside the
+	// windows, e.g. hotfixes.
+	ExemptLabel string `json:"exempt_label,omitempty"`
+	// Timezone is the IANA time zone of the allowed window schedules. Defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+
+	location *time.Location
+}
+
+// TideMergeWindow is a recurring window in which merges are allowed.
+type TideMergeWindow struct {
+	// Schedule is a cron expression of when the window opens:
+	// minute, hour, day of month, month and day of week, e.g. "0 9 * * 1-5".
+	Schedule string `json:"schedule"`
+	// DurationString compiles into Duration at load time.
+	DurationString string `json:"duration"`
+	// Duration is how long the window stays open.
+	Duration time.Duration `json:"-"`
+
+	schedule cron.Schedule
+}
+
+// TideMergeFreeze is a period in which merges are not allowed.
+type TideMergeFreeze struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// MergeWindowsFor returns the merge windows of a branch, or nil if merges are
+// always allowed. The most specific of the org/repo:branch, org/repo, org and
+// "*" keys of MergeWindows applies.
+func (t *Tide) MergeWindowsFor(org, repo, branch string) *TideMergeWindows {
+	for _, key := range append(branchKeys(org, repo, branch), "*") {
+		if w, ok := t.MergeWindows[key]; ok {
+			return &w
+		}
+	}
+	return nil
+}
+
+func (w *TideMergeWindows) parse() error {
+	w.location = time.UTC
+	if w.Timezone != "" {
+		loc, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q: %v", w.Timezone, err)
+		}
+		w.location = loc
+	}
+	for i := range w.Allowed {
+		window := &w.Allowed[i]
+		schedule, err := cron.Parse("0 " + window.Schedule)
+		if err != nil {
+			return fmt.Errorf("invalid schedule %q: %v", window.Schedule, err)
+		}
+		window.schedule = schedule
+		duration, err := time.ParseDuration(window.DurationString)
+		if err != nil {
+			return fmt.Errorf("cannot parse duration for schedule %q: %v", window.Schedule, err)
+		}
+		if duration <= 0 {
+			return fmt.Errorf("duration for schedule %q must be positive", window.Schedule)
+		}
+		window.Duration = duration
+	}
+	for _, freeze := range w.Freezes {
+		if !freeze.End.After(freeze.Start) {
+			return fmt.Errorf("freeze %q must end after it starts", freeze.Reason)
+		}
+	}
+	return nil
+}
+
+// Exempt returns true if a PR with the labels may be merged outside the windows.
+func (w *TideMergeWindows) Exempt(labels []string) bool {
+	if w == nil || w.ExemptLabel == "" {
+		return false
+	}
+	for _, label := range labels {
+		if label == w.ExemptLabel {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns true if merges are allowed at the time. Otherwise it returns
+// when they will next be allowed, which is zero if never, and the reason of
+// the freeze preventing them, if any.
+func (w *TideMergeWindows) Check(now time.Time) (bool, time.Time, string) {
+	if w == nil {
+		return true, time.Time{}, ""
+	}
+	var reason string
+	if freeze := w.freezeAt(now); freeze != nil {
+		reason = freeze.Reason
+	} else if w.inAllowedWindow(now) {
+		return true, time.Time{}, ""
+	}
+
+	t := now
+	for i := 0; i < maxMergeWindowSteps; i++ {
+		if freeze := w.freezeAt(t); freeze != nil {
+			t = freeze.End
+			continue
+		}
+		if !w.inAllowedWindow(t) {
+			t = w.nextOpening(t)
+			if t.IsZero() {
+				return false, t, reason
+			}
+			continue
+		}
+		return false, t, reason
+	}
+	return false, time.Time{}, reason
+}
+
+func (w *TideMergeWindows) freezeAt(t time.Time) *TideMergeFreeze {
+	for i := range w.Freezes {
+		freeze := &w.Freezes[i]
+		if !t.Before(freeze.Start) && t.Before(freeze.End) {
+			return freeze
+		}
+	}
+	return nil
+}
+
+func (w *TideMergeWindows) inAllowedWindow(t time.Time) bool {
+	if len(w.Allowed) == 0 {
+		return true
+	}
+	t = t.In(w.loc())
+	for _, window := range w.Allowed {
+		if window.schedule == nil {
+			continue
+		}
+		// the window is open if it opened within its duration before t
+		opened := window.schedule.Next(t.Add(-window.Duration))
+		if !opened.IsZero() && !opened.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *TideMergeWindows) nextOpening(t time.Time) time.Time {
+	var next time.Time
+	for _, window := range w.Allowed {
+		if window.schedule == nil {
+			continue
+		}
+		opens := window.schedule.Next(t.In(w.loc()))
+		if !opens.IsZero() && (next.IsZero() || opens.Before(next)) {
+			next = opens
+		}
+	}
+	return next
+}
+
+func (w *TideMergeWindows) loc() *time.Location {
+	if w.location == nil {
+		return time.UTC
+	}
+	return w.location
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func mustTime(t *testing.T, value string) time.Time {
+	answer, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("failed to parse time %s: %v", value, err)
+	}
+	return answer
+}
+
+func TestMergeWindowsCheck(t *testing.T) {
+	c, err := LoadYAMLConfig([]byte(`
+tide:
+  merge_windows:
+    org/repo:
+      allowed:
+      - schedule: "0 9 * * 1-5"
+        duration: 8h
+      freezes:
+      - start: 2020-03-10T00:00:00Z
+        end: 2020-03-12T00:00:00Z
+        reason: release 1.2
+      exempt_label: hotfix
+    org/repo:release:
+      freezes:
+      - start: 2020-03-10T00:00:00Z
+        end: 2020-03-12T00:00:00Z
+        reason: release 1.2
+`))
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	testCases := []struct {
+		name           string
+		branch         string
+		now            string
+		expectedOpen   bool
+		expectedUntil  string
+		expectedReason string
+	}{
+		{
+			name:         "within the window",
+			branch:       "master",
+			now:          "2020-03-09T10:00:00Z",
+			expectedOpen: true,
+		},
+		{
+			name:          "after the window skips the freeze",
+			branch:        "master",
+			now:           "2020-03-09T18:00:00Z",
+			expectedUntil: "2020-03-12T09:00:00Z",
+		},
+		{
+			name:           "during the freeze",
+			branch:         "master",
+			now:            "2020-03-11T10:00:00Z",
+			expectedUntil:  "2020-03-12T09:00:00Z",
+			expectedReason: "release 1.2",
+		},
+		{
+			name:          "at the weekend",
+			branch:        "master",
+			now:           "2020-03-14T10:00:00Z",
+			expectedUntil: "2020-03-16T09:00:00Z",
+		},
+		{
+			name:           "freeze without windows",
+			branch:         "release",
+			now:            "2020-03-11T10:00:00Z",
+			expectedUntil:  "2020-03-12T00:00:00Z",
+			expectedReason: "release 1.2",
+		},
+		{
+			name:         "no freeze without windows",
+			branch:       "release",
+			now:          "2020-03-14T10:00:00Z",
+			expectedOpen: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			windows := c.Tide.MergeWindowsFor("org", "repo", tc.branch)
+			if windows == nil {
+				t.Fatalf("expected merge windows for branch %s", tc.branch)
+			}
+			open, until, reason := windows.Check(mustTime(t, tc.now))
+			if open != tc.expectedOpen {
+				t.Errorf("expected open %v but got %v", tc.expectedOpen, open)
+			}
+			if tc.expectedUntil != "" && !until.Equal(mustTime(t, tc.expectedUntil)) {
+				t.Errorf("expected merges to be allowed at %s but got %s", tc.expectedUntil, until)
+			}
+			if reason != tc.expectedReason {
+				t.Errorf("expected reason %q but got %q", tc.expectedReason, reason)
+			}
+		})
+	}
+
+	if windows := c.Tide.MergeWindowsFor("org", "other", "master"); windows != nil {
+		t.Errorf("expected no merge windows for org/other but got %v", windows)
+	}
+	var noWindows *TideMergeWindows
+	if open, _, _ := noWindows.Check(time.Now()); !open {
+		t.Error("expected merges to be allowed without merge windows")
+	}
+	if !c.Tide.MergeWindowsFor("org", "repo", "master").Exempt([]string{"lgtm", "hotfix"}) {
+		t.Error("expected the hotfix label to be exempt")
+	}
+}
+
+func TestMergeWindowsValidation(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config string
+	}{
+		{
+			name: "invalid schedule",
+			config: `
+tide:
+  merge_windows:
+    org:
+      allowed:
+      - schedule: "every morning"
+        duration: 8h
+`,
+		},
+		{
+			name: "invalid duration",
+			config: `
+tide:
+  merge_windows:
+    org:
+      allowed:
+      - schedule: "0 9 * * 1-5"
+        duration: all day
+`,
+		},
+		{
+			name: "invalid timezone",
+			config: `
+tide:
+  merge_windows:
+    org:
+      timezone: Nowhere/Special
+`,
+		},
+		{
+			name: "freeze ending before it starts",
+			config: `
+tide:
+  merge_windows:
+    org:
+      freezes:
+      - start: 2020-03-12T00:00:00Z
+        end: 2020-03-10T00:00:00Z
+`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := LoadYAMLConfig([]byte(tc.config)); err == nil {
+				t.Error("expected an error loading the config")
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultStaleAfter  = 90 * 24 * time.Hour
+	defaultRottenAfter = 30 * 24 * time.Hour
+	defaultCloseAfter  = 30 * 24 * time.Hour
+)
+
+// Stale is config for the stale job which marks inactive issues and pull requests
+// as stale, then rotten, and finally closes them.
+type Stale struct {
+	Queries []StaleQuery `json:"queries,omitempty"`
+}
+
+// StaleQuery selects the issues and pull requests of some repos and configures how
+// long they can be inactive for at each stage.
+type StaleQuery struct {
+	// Repos are the org/repo strings the query applies to.
+	Repos []string `json:"repos,omitempty"`
+	// Query holds extra search qualifiers, e.g. "is:pr" or "-label:kind/bug".
+	Query string `json:"query,omitempty"`
+
+	// StaleAfterString compiles into StaleAfter at load time.
+	StaleAfterString string `json:"stale_after,omitempty"`
+	// StaleAfter is how long an item can be inactive before it is labelled stale.
+	// Defaults to 90 days.
+	StaleAfter time.Duration `json:"-"`
+	// RottenAfterString compiles into RottenAfter at load time.
+	RottenAfterString string `json:"rotten_after,omitempty"`
+	// RottenAfter is how long a stale item can be inactive before it is labelled rotten.
+	// Defaults to 30 days.
+	RottenAfter time.Duration `json:"-"`
+	// CloseAfterString compiles into CloseAfter at load time.
+	CloseAfterString string `json:"close_after,omitempty"`
+	// CloseAfter is how long a rotten item can be inactive before it is closed.
+	// Defaults to 30 days.
+	CloseAfter time.Duration `json:"-"`
+
+	// StaleComment, RottenComment and CloseComment override the comments posted
+	// when an item is labelled stale, labelled rotten and closed.
+	StaleComment  string `json:"stale_comment,omitempty"`
+	RottenComment string `json:"rotten_comment,omitempty"`
+	CloseComment  string `json:"close_comment,omitempty"`
+}
+
+// parse validates the query and compiles its durations, applying the defaults.
+func (sq *StaleQuery) parse() error {
+	if len(sq.Repos) == 0 {
+		return fmt.Errorf("no repos specified")
+	}
+	for i, repo := range sq.Repos {
+		parts := strings.Split(repo, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("repos[%d]: %q is not of the form org/repo", i, repo)
+		}
+	}
+	var err error
+	if sq.StaleAfter, err = parseDurationOrDefault(sq.StaleAfterString, defaultStaleAfter); err != nil {
+		return fmt.Errorf("cannot parse duration for stale_after: %v", err)
+	}
+	if sq.RottenAfter, err = parseDurationOrDefault(sq.RottenAfterString, defaultRottenAfter); err != nil {
+		return fmt.Errorf("cannot parse duration for rotten_after: %v", err)
+	}
+	if sq.CloseAfter, err = parseDurationOrDefault(sq.CloseAfterString, defaultCloseAfter); err != nil {
+		return fmt.Errorf("cannot parse duration for close_after: %v", err)
+	}
+	return nil
+}
+
+func parseDurationOrDefault(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s is not a positive duration", s)
+	}
+	return d, nil
+}
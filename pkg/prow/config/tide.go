@@ -87,13 +87,20 @@ type Tide struct {
 	// specify the set of PRs that meet merge requirements.
 	Queries TideQueries `json:"queries,omitempty"`
 
-	// A key/value pair of an org/repo as the key and merge method to override
-	// the default method of merge. Valid options are squash, rebase, and merge.
+	// A key/value pair of an org/repo:branch, org/repo or org as the key and merge
+	// method to override the default method of merge. Valid options are squash,
+	// rebase, and merge.
 	MergeType map[string]gitprovider.PullRequestMergeType `json:"merge_method,omitempty"`
 
-	// A key/value pair of an org/repo as the key and Go template to override
-	// the default merge commit title and/or message. Template is passed the
-	// PullRequest struct (prow/github/types.go#PullRequest)
+	// A key/value pair of an org/repo:branch, org/repo or org as the key and the
+	// merge methods PR authors may pick with a "/merge-method <method>" line in the
+	// PR body. The directive is ignored for branches without allowed methods.
+	AllowedMergeTypes map[string][]gitprovider.PullRequestMergeType `json:"allowed_merge_methods,omitempty"`
+
+	// A key/value pair of an org/repo:branch, org/repo or org as the key and Go
+	// template to override the default merge commit title and/or message. Template
+	// is passed the PullRequest struct along with its co-authors, linked issues
+	// and label names (tide.MergeCommitTemplateData)
 	MergeTemplate map[string]TideMergeCommitTemplate `json:"merge_commit_template,omitempty"`
 
 	// URL for tide status contexts.
@@ -144,23 +151,102 @@ type Tide struct {
 	//  0 => unlimited batch size
 	// -1 => batch merging disabled :(
 	BatchSizeLimitMap map[string]int `json:"batch_size_limit,omitempty"`
+
+	// AtomicBatchMap is a key/value pair of an org or org/repo as the key and
+	// whether batches are merged atomically as the value. Atomic batches are
+	// pushed to the base branch as the tested batch commit if the git provider
+	// allows it; otherwise their PRs are merged until one fails, after which
+	// the PRs already merged are reverted.
+	AtomicBatchMap map[string]bool `json:"atomic_batch,omitempty"`
+
+	// UpdateBranchMap is a key/value pair of an org or org/repo as the key and
+	// whether tide updates passing PRs which are behind their base branch as the
+	// value. It only applies to branches whose protection requires PRs to be up to
+	// date (strict). The base branch is merged into the PR branch, which retests it.
+	UpdateBranchMap map[string]bool `json:"update_branch,omitempty"`
+
+	// MergeTrainDepthMap is a key/value pair of an org or org/repo as the key and
+	// the depth of the merge train of the repo as the value. Instead of testing a
+	// single batch, tide tests up to that many speculative batches at once, each
+	// stacking one more PR on the previous one, and merges the longest passing
+	// batch. Merge trains are disabled if the depth is zero.
+	MergeTrainDepthMap map[string]int `json:"merge_train_depth,omitempty"`
+
+	// PriorityLabels is an optional list of labels, highest priority first, e.g.
+	// priority/critical then priority/high. PRs are merged and batched in order of
+	// their highest priority label, then age, PRs without any of the labels last.
+	PriorityLabels []string `json:"priority_labels,omitempty"`
+
+	// MaxWaitString compiles into MaxWait at load time.
+	MaxWaitString string `json:"max_wait,omitempty"`
+	// MaxWait is how long a PR can wait in its pool before it is merged ahead of
+	// every priority label so that low priority PRs still merge eventually.
+	// Disabled if zero.
+	MaxWait time.Duration `json:"-"`
+
+	// MergeWindows is a key/value pair of an org/repo:branch, org/repo or org as
+	// the key and the windows in which tide may merge the PRs of the branches.
+	// The "*" key can be used as a global default. Merges are always allowed if
+	// no key applies.
+	MergeWindows map[string]TideMergeWindows `json:"merge_windows,omitempty"`
+
+	// MergeFailures configures how tide reports the PRs it fails to merge.
+	MergeFailures TideMergeFailures `json:"merge_failures,omitempty"`
 }
 
-// MergeMethod returns the merge method to use for a repo. The default of merge is
-// returned when not overridden.
-func (t *Tide) MergeMethod(org, repo string) gitprovider.PullRequestMergeType {
-	name := org + "/" + repo
+// TideMergeFailures configures the comments and notifications of merge failures.
+type TideMergeFailures struct {
+	// Comment enables a comment on the PRs tide fails to merge explaining why.
+	// The comment is replaced on later failures and removed once the PR merges.
+	Comment bool `json:"comment,omitempty"`
+	// CommentPeriodString compiles into CommentPeriod at load time.
+	CommentPeriodString string `json:"comment_period,omitempty"`
+	// CommentPeriod is the minimum time between two comments on the same PR, and
+	// between two notifications of the webhook about it. Defaults to an hour.
+	CommentPeriod time.Duration `json:"-"`
+	// WebhookURL is sent a JSON description of the merge failures, e.g. to alert
+	// whoever operates tide.
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
 
-	v, ok := t.MergeType[name]
-	if !ok {
-		if ov, found := t.MergeType[org]; found {
-			return ov
+// Priority returns the priority of a PR with the labels, lower values merging first:
+// the index of its highest priority label in PriorityLabels, or the number of
+// priority labels if it has none of them.
+func (t *Tide) Priority(labels []string) int {
+	for i, priorityLabel := range t.PriorityLabels {
+		for _, label := range labels {
+			if label == priorityLabel {
+				return i
+			}
 		}
+	}
+	return len(t.PriorityLabels)
+}
+
+// branchKeys returns the keys of the per branch tide settings, most specific first.
+func branchKeys(org, repo, branch string) []string {
+	return []string{fmt.Sprintf("%s/%s:%s", org, repo, branch), org + "/" + repo, org}
+}
 
-		return gitprovider.MergeMerge
+// MergeMethod returns the merge method to use for a branch. The default of merge is
+// returned when not overridden.
+func (t *Tide) MergeMethod(org, repo, branch string) gitprovider.PullRequestMergeType {
+	for _, key := range branchKeys(org, repo, branch) {
+		if v, ok := t.MergeType[key]; ok {
+			return v
+		}
 	}
+	return gitprovider.MergeMerge
+}
 
-	return v
+// AllowedMergeMethods returns the merge methods PR authors may pick for a branch.
+func (t *Tide) AllowedMergeMethods(org, repo, branch string) []gitprovider.PullRequestMergeType {
+	for _, key := range branchKeys(org, repo, branch) {
+		if v, ok := t.AllowedMergeTypes[key]; ok {
+			return v
+		}
+	}
+	return nil
 }
 
 // BatchSizeLimit return the batch size limit for the given repo
@@ -176,16 +262,56 @@ func (t *Tide) BatchSizeLimit(org, repo string) int {
 	//return t.BatchSizeLimitMap["*"]
 }
 
-// MergeCommitTemplate returns a struct with Go template string(s) or nil
-func (t *Tide) MergeCommitTemplate(org, repo string) TideMergeCommitTemplate {
-	name := org + "/" + repo
+// AtomicBatch returns true if the batches of the given repo are merged atomically
+func (t *Tide) AtomicBatch(org, repo string) bool {
+	if atomic, ok := t.AtomicBatchMap[org+"/"+repo]; ok {
+		return atomic
+	}
+	return t.AtomicBatchMap[org]
+}
 
-	v, ok := t.MergeTemplate[name]
-	if !ok {
-		return t.MergeTemplate[org]
+// UpdateBranch returns true if tide updates the PRs of the given repo which are behind their base branch
+func (t *Tide) UpdateBranch(org, repo string) bool {
+	if update, ok := t.UpdateBranchMap[org+"/"+repo]; ok {
+		return update
 	}
+	return t.UpdateBranchMap[org]
+}
+
+// MergeTrainDepth returns the depth of the merge train of the given repo, zero if disabled
+func (t *Tide) MergeTrainDepth(org, repo string) int {
+	if depth, ok := t.MergeTrainDepthMap[org+"/"+repo]; ok {
+		return depth
+	}
+	return t.MergeTrainDepthMap[org]
+}
 
-	return v
+// MergeCommitTemplate returns a struct with Go template string(s) or nil
+func (t *Tide) MergeCommitTemplate(org, repo, branch string) TideMergeCommitTemplate {
+	for _, key := range branchKeys(org, repo, branch) {
+		if v, ok := t.MergeTemplate[key]; ok {
+			return v
+		}
+	}
+	return TideMergeCommitTemplate{}
+}
+
+func (tmct *TideMergeCommitTemplate) parse() error {
+	if tmct.TitleTemplate != "" {
+		title, err := template.New("CommitTitle").Parse(tmct.TitleTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid title template: %v", err)
+		}
+		tmct.Title = title
+	}
+	if tmct.BodyTemplate != "" {
+		body, err := template.New("CommitBody").Parse(tmct.BodyTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid body template: %v", err)
+		}
+		tmct.Body = body
+	}
+	return nil
 }
 
 // TideQuery is turned into a GitHub search query. See the docs for details:
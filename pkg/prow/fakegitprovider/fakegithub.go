@@ -19,6 +19,7 @@ package fakegitprovider
 import (
 	"fmt"
 	"regexp"
+	"sort"
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
@@ -36,9 +37,11 @@ const (
 
 // FakeClient is like client, but fake.
 type FakeClient struct {
-	Issues              map[int][]*scm.Issue
-	OrgMembers          map[string][]string
-	Collaborators       []string
+	Issues        map[int][]*scm.Issue
+	OrgMembers    map[string][]string
+	Collaborators []string
+	// login:permission
+	UserPermissions     map[string]string
 	IssueComments       map[int][]*scm.Comment
 	IssueCommentID      int
 	PullRequests        map[int]*scm.PullRequest
@@ -89,6 +92,9 @@ type FakeClient struct {
 
 	// A list of refs that got deleted via DeleteRef
 	RefsDeleted []struct{ Org, Repo, Ref string }
+
+	// PullRequestMergeability overrides the mergeability derived from PullRequests
+	PullRequestMergeability map[int]gitprovider.Mergeability
 }
 
 // BotName returns authenticated login.
@@ -106,6 +112,25 @@ func (f *FakeClient) IsMember(org, user string) (bool, error) {
 	return false, nil
 }
 
+// GetUserPermission returns the permission level of a user.
+func (f *FakeClient) GetUserPermission(org, repo, user string) (string, error) {
+	if perm, ok := f.UserPermissions[user]; ok {
+		return perm, nil
+	}
+	return "none", nil
+}
+
+// HasPermission returns true if the permission level of a user is one of the roles.
+func (f *FakeClient) HasPermission(org, repo, user string, roles ...string) (bool, error) {
+	perm, _ := f.GetUserPermission(org, repo, user)
+	for _, r := range roles {
+		if r == perm {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // ListIssueComments returns comments.
 func (f *FakeClient) ListIssueComments(owner, repo string, number int) ([]*scm.Comment, error) {
 	return append([]*scm.Comment{}, f.IssueComments[number]...), nil
@@ -225,6 +250,38 @@ func (f *FakeClient) GetPullRequest(owner, repo string, number int) (*scm.PullRe
 	return val, nil
 }
 
+// GetPullRequestMergeability returns the mergeability of the pull request, which is
+// conflicting unless it is mergeable if it is not overridden.
+func (f *FakeClient) GetPullRequestMergeability(owner, repo string, number int) (gitprovider.Mergeability, error) {
+	if m, ok := f.PullRequestMergeability[number]; ok {
+		return m, nil
+	}
+	pr, err := f.GetPullRequest(owner, repo, number)
+	if err != nil {
+		return gitprovider.MergeabilityUnknown, err
+	}
+	if pr.Mergeable {
+		return gitprovider.MergeabilityMergeable, nil
+	}
+	return gitprovider.MergeabilityConflicting, nil
+}
+
+// ListOpenPullRequests returns the pull requests which are neither closed nor merged.
+func (f *FakeClient) ListOpenPullRequests(owner, repo string) ([]*scm.PullRequest, error) {
+	var numbers []int
+	for number, pr := range f.PullRequests {
+		if !pr.Closed && !pr.Merged {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	var prs []*scm.PullRequest
+	for _, number := range numbers {
+		prs = append(prs, f.PullRequests[number])
+	}
+	return prs, nil
+}
+
 // GetPullRequestChanges returns the file modifications in a PR.
 func (f *FakeClient) GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error) {
 	return f.PullRequestChanges[number], nil
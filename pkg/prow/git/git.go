@@ -313,6 +313,66 @@ func (r *Repo) Push(repo, branch string) error {
 	return err
 }
 
+// redact removes the password from git output.
+func (r *Repo) redact(b []byte) string {
+	if r.pass == "" {
+		return string(b)
+	}
+	return strings.Replace(string(b), r.pass, "<redacted>", -1)
+}
+
+// FetchBranch fetches the branch of the remote repo and returns the SHA of its head.
+func (r *Repo) FetchBranch(branch string) (string, error) {
+	r.logger.Infof("Fetching %s (branch: %s).", r.repo, branch)
+	if b, err := retryCmd(r.logger, r.Dir, r.git, "fetch", r.base+"/"+r.repo, branch); err != nil {
+		return "", fmt.Errorf("git fetch failed for branch %s: %v. output: %s", branch, err, r.redact(b))
+	}
+	sha, err := r.RevParse("FETCH_HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sha), nil
+}
+
+// PushToBranch pushes the commitlike to the branch of the remote repo. The
+// remote rejects the push unless it fast forwards the branch.
+func (r *Repo) PushToBranch(commitlike, branch string) error {
+	r.logger.Infof("Pushing %s to %s (branch: %s).", commitlike, r.repo, branch)
+	co := r.gitCommand("push", r.base+"/"+r.repo, commitlike+":refs/heads/"+branch)
+	if b, err := co.CombinedOutput(); err != nil {
+		return fmt.Errorf("error pushing %s to branch %s: %v. output: %s", commitlike, branch, err, r.redact(b))
+	}
+	return nil
+}
+
+// FirstParents returns the commits on the first parent history of head since
+// base, newest first.
+func (r *Repo) FirstParents(base, head string) ([]string, error) {
+	b, err := r.gitCommand("rev-list", "--first-parent", base+".."+head).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error listing the commits from %s to %s: %v. output: %s", base, head, err, string(b))
+	}
+	return strings.Fields(string(b)), nil
+}
+
+// Revert commits the revert of the commit on top of the current branch. Merge
+// commits are reverted against their first parent.
+func (r *Repo) Revert(commit string) error {
+	r.logger.Infof("Reverting %s.", commit)
+	b, err := r.gitCommand("rev-list", "--parents", "-n", "1", commit).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error listing the parents of %s: %v. output: %s", commit, err, string(b))
+	}
+	args := []string{"revert", "--no-edit"}
+	if len(strings.Fields(string(b))) > 2 {
+		args = append(args, "-m", "1")
+	}
+	if b, err := r.gitCommand(append(args, commit)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("error reverting %s: %v. output: %s", commit, err, string(b))
+	}
+	return nil
+}
+
 // CheckoutPullRequest does exactly that.
 func (r *Repo) CheckoutPullRequest(number int) error {
 	r.logger.Infof("Fetching and checking out %s#%d.", r.repo, number)
@@ -155,3 +155,9 @@ func (lg *LocalGit) RevParse(org, repo, commitlike string) (string, error) {
 	rdir := filepath.Join(lg.Dir, org, repo)
 	return runCmdOutput(lg.Git, rdir, "rev-parse", commitlike)
 }
+
+// Merge does git merge --no-ff of the commitlike into the current branch.
+func (lg *LocalGit) Merge(org, repo, commitlike string) error {
+	rdir := filepath.Join(lg.Dir, org, repo)
+	return runCmd(lg.Git, rdir, "merge", "--no-ff", "-m", "merge", commitlike)
+}
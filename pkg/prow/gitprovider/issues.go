@@ -202,12 +202,40 @@ func (c *Client) ReopenIssue(owner, repo string, number int) error {
 	panic("implement me")
 }
 
-// FindIssues find issues
+// FindIssues searches for issues and pull requests matching the query, optionally sorted
+// by a field such as "created" or "updated". All the pages of results are returned.
 func (c *Client) FindIssues(query, sort string, asc bool) ([]scm.Issue, error) {
-	panic("implement me")
+	if sort != "" {
+		order := "desc"
+		if asc {
+			order = "asc"
+		}
+		query = fmt.Sprintf("%s sort:%s-%s", query, sort, order)
+	}
+	ctx := context.Background()
+	var issues []scm.Issue
+	opts := scm.SearchOptions{Query: query, Page: 1, Size: 100}
+	for {
+		results, resp, err := c.client.Issues.Search(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			if result != nil {
+				issues = append(issues, result.Issue)
+			}
+		}
+		if resp == nil || resp.Page.Next == 0 || len(results) == 0 {
+			return issues, nil
+		}
+		opts.Page = resp.Page.Next
+	}
 }
 
 // CloseIssue close issue
 func (c *Client) CloseIssue(owner, repo string, number int) error {
-	panic("implement me")
+	ctx := context.Background()
+	fullName := c.repositoryName(owner, repo)
+	_, err := c.client.Issues.Close(ctx, fullName, number)
+	return err
 }
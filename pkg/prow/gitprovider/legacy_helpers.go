@@ -19,6 +19,8 @@ const (
 	RoleMaintainer = "maintainer"
 	// RoleMember specifies the user is a regular user, or only lists regular users
 	RoleMember = "member"
+	// RoleWrite specifies the user has write access to a repository
+	RoleWrite = "write"
 	// StatePending specifies the user has an invitation to the org/team.
 	StatePending = "pending"
 	// StateActive specifies the user's membership is active.
@@ -0,0 +1,73 @@
+package gitprovider
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	apiRequestCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_git_provider_requests",
+		Help: "A counter of the API requests made to the git provider.",
+	}, []string{"provider", "method", "response_code"})
+	rateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lighthouse_git_provider_rate_limit_remaining",
+		Help: "The number of API requests remaining in the current rate limit window of the git provider.",
+	}, []string{"provider"})
+	rateLimitLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lighthouse_git_provider_rate_limit_limit",
+		Help: "The number of API requests allowed per rate limit window of the git provider.",
+	}, []string{"provider"})
+)
+
+func init() {
+	prometheus.MustRegister(apiRequestCounter)
+	prometheus.MustRegister(rateLimitRemaining)
+	prometheus.MustRegister(rateLimitLimit)
+}
+
+// InstrumentClient makes the scm client count the API requests it makes and record the rate limits
+// reported by the git provider. It should be called once, before the client is shared.
+func InstrumentClient(client *scm.Client, provider string) {
+	if client == nil {
+		return
+	}
+	httpClient := &http.Client{}
+	if client.Client != nil {
+		*httpClient = *client.Client
+	}
+	if _, ok := httpClient.Transport.(*instrumentedTransport); ok {
+		return
+	}
+	next := httpClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	httpClient.Transport = &instrumentedTransport{provider: provider, next: next}
+	client.Client = httpClient
+}
+
+type instrumentedTransport struct {
+	provider string
+	next     http.RoundTripper
+}
+
+// RoundTrip performs the request, recording its outcome and any rate limit headers
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+		rates := &RateLimits{}
+		rates.populate(&scm.Response{Header: resp.Header})
+		if rates.Limit > 0 {
+			rateLimitRemaining.WithLabelValues(t.provider).Set(float64(rates.Remaining))
+			rateLimitLimit.WithLabelValues(t.provider).Set(float64(rates.Limit))
+		}
+	}
+	apiRequestCounter.WithLabelValues(t.provider, req.Method, code).Inc()
+	return resp, err
+}
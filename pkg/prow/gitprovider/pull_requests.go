@@ -1,7 +1,13 @@
 package gitprovider
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/jenkins-x/go-scm/scm"
 )
@@ -22,6 +28,120 @@ func (c *Client) GetPullRequest(owner, repo string, number int) (*scm.PullReques
 	return pr, err
 }
 
+// Mergeability is whether a pull request can be merged into its base branch
+type Mergeability string
+
+const (
+	// MergeabilityUnknown means the git provider has not computed the mergeability yet,
+	// which is the case for a little while after a push
+	MergeabilityUnknown Mergeability = "unknown"
+	// MergeabilityMergeable means the pull request can be merged
+	MergeabilityMergeable Mergeability = "mergeable"
+	// MergeabilityConflicting means the pull request conflicts with its base branch
+	MergeabilityConflicting Mergeability = "conflicting"
+)
+
+// GetPullRequestMergeability returns whether the pull request can be merged. GitHub
+// reports the mergeability as unknown until it has computed it, and reports a
+// conflict with the "dirty" mergeable state. GitLab reports conflicts separately
+// from its merge status. Other git providers only tell whether the pull request
+// can be merged, which is also false while it is not computed, so the pull
+// requests they cannot merge are of unknown mergeability.
+func (c *Client) GetPullRequestMergeability(owner, repo string, number int) (Mergeability, error) {
+	switch c.client.Driver {
+	case scm.DriverGithub:
+	case scm.DriverGitlab:
+		return c.gitLabMergeability(owner, repo, number)
+	default:
+		pr, err := c.GetPullRequest(owner, repo, number)
+		if err != nil {
+			return MergeabilityUnknown, err
+		}
+		if pr.Mergeable {
+			return MergeabilityMergeable, nil
+		}
+		return MergeabilityUnknown, nil
+	}
+	ctx := context.Background()
+	res, err := c.client.Do(ctx, &scm.Request{
+		Method: http.MethodGet,
+		Path:   fmt.Sprintf("repos/%s/pulls/%d", c.repositoryName(owner, repo), number),
+	})
+	if err != nil {
+		return MergeabilityUnknown, err
+	}
+	defer res.Body.Close()
+	if res.Status != http.StatusOK {
+		return MergeabilityUnknown, fmt.Errorf("failed to get %s/%s#%d: status %d", owner, repo, number, res.Status)
+	}
+	var answer struct {
+		Mergeable      *bool  `json:"mergeable"`
+		MergeableState string `json:"mergeable_state"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&answer); err != nil {
+		return MergeabilityUnknown, err
+	}
+	switch {
+	case answer.Mergeable == nil:
+		return MergeabilityUnknown, nil
+	case *answer.Mergeable:
+		return MergeabilityMergeable, nil
+	case answer.MergeableState == "dirty":
+		return MergeabilityConflicting, nil
+	}
+	return MergeabilityUnknown, nil
+}
+
+// gitLabMergeability returns whether a GitLab merge request can be merged. Its merge
+// status is "unchecked" or "checking" until GitLab has computed it.
+func (c *Client) gitLabMergeability(owner, repo string, number int) (Mergeability, error) {
+	ctx := context.Background()
+	res, err := c.client.Do(ctx, &scm.Request{
+		Method: http.MethodGet,
+		Path:   fmt.Sprintf("api/v4/projects/%s/merge_requests/%d", url.PathEscape(c.repositoryName(owner, repo)), number),
+	})
+	if err != nil {
+		return MergeabilityUnknown, err
+	}
+	defer res.Body.Close()
+	if res.Status != http.StatusOK {
+		return MergeabilityUnknown, fmt.Errorf("failed to get %s/%s!%d: status %d", owner, repo, number, res.Status)
+	}
+	var answer struct {
+		MergeStatus  string `json:"merge_status"`
+		HasConflicts bool   `json:"has_conflicts"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&answer); err != nil {
+		return MergeabilityUnknown, err
+	}
+	switch {
+	case answer.HasConflicts:
+		return MergeabilityConflicting, nil
+	case answer.MergeStatus == "can_be_merged":
+		return MergeabilityMergeable, nil
+	}
+	return MergeabilityUnknown, nil
+}
+
+// ListOpenPullRequests lists the open pull requests of a repository
+func (c *Client) ListOpenPullRequests(owner, repo string) ([]*scm.PullRequest, error) {
+	ctx := context.Background()
+	fullName := c.repositoryName(owner, repo)
+	var allPRs []*scm.PullRequest
+	opts := scm.PullRequestListOptions{Open: true, Page: 1, Size: 100}
+	for {
+		prs, resp, err := c.client.PullRequests.List(ctx, fullName, opts)
+		if err != nil {
+			return nil, err
+		}
+		allPRs = append(allPRs, prs...)
+		if resp == nil || resp.Page.Next == 0 || len(prs) == 0 {
+			return allPRs, nil
+		}
+		opts.Page = resp.Page.Next
+	}
+}
+
 // ListPullRequestComments list pull request comments
 func (c *Client) ListPullRequestComments(owner, repo string, number int) ([]*scm.Comment, error) {
 	ctx := context.Background()
@@ -30,6 +150,29 @@ func (c *Client) ListPullRequestComments(owner, repo string, number int) ([]*scm
 	return pr, err
 }
 
+// ListPRCommits lists all the commits of a pull request
+func (c *Client) ListPRCommits(owner, repo string, number int) ([]scm.Commit, error) {
+	ctx := context.Background()
+	fullName := c.repositoryName(owner, repo)
+	var answer []scm.Commit
+	opts := scm.ListOptions{Page: 1, Size: 100}
+	for {
+		commits, resp, err := c.client.PullRequests.ListCommits(ctx, fullName, number, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, commit := range commits {
+			if commit != nil {
+				answer = append(answer, *commit)
+			}
+		}
+		if resp == nil || resp.Page.Next == 0 || len(commits) == 0 {
+			return answer, nil
+		}
+		opts.Page = resp.Page.Next
+	}
+}
+
 // GetPullRequestChanges returns the changes in a pull request
 func (c *Client) GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error) {
 	ctx := context.Background()
@@ -38,14 +181,137 @@ func (c *Client) GetPullRequestChanges(org, repo string, number int) ([]*scm.Cha
 	return changes, err
 }
 
-// Merge reopens a pull request
+// Merge merges a pull request. The details are only honoured on GitHub, other
+// git providers merge with their default method and message.
 func (c *Client) Merge(owner, repo string, number int, details MergeDetails) error {
+	if c.client.Driver == scm.DriverGithub {
+		return c.mergeGitHub(owner, repo, number, details)
+	}
 	ctx := context.Background()
 	fullName := c.repositoryName(owner, repo)
 	_, err := c.client.PullRequests.Merge(ctx, fullName, number)
 	return err
 }
 
+// mergeGitHub merges a pull request with the GitHub merge API, mapping the
+// refusals of GitHub to the merge errors.
+func (c *Client) mergeGitHub(owner, repo string, number int, details MergeDetails) error {
+	ctx := context.Background()
+	body, err := json.Marshal(struct {
+		CommitTitle   string `json:"commit_title,omitempty"`
+		CommitMessage string `json:"commit_message,omitempty"`
+		SHA           string `json:"sha,omitempty"`
+		MergeMethod   string `json:"merge_method,omitempty"`
+	}{details.CommitTitle, details.CommitMessage, details.SHA, details.MergeMethod})
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(ctx, &scm.Request{
+		Method: http.MethodPut,
+		Path:   fmt.Sprintf("repos/%s/pulls/%d/merge", c.repositoryName(owner, repo), number),
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   bytes.NewReader(body),
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.Status == http.StatusOK {
+		return nil
+	}
+	var answer struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&answer)
+	switch res.Status {
+	case http.StatusMethodNotAllowed:
+		switch {
+		case strings.Contains(answer.Message, "Base branch was modified"):
+			return UnmergablePRBaseChangedError(answer.Message)
+		case strings.Contains(answer.Message, "You're not authorized to push to this branch"):
+			return UnauthorizedToPushError(answer.Message)
+		case strings.Contains(answer.Message, "Merge commits are not allowed on this repository"):
+			return MergeCommitsForbiddenError(answer.Message)
+		}
+		return UnmergablePRError(answer.Message)
+	case http.StatusConflict:
+		return ModifiedHeadError(answer.Message)
+	}
+	return fmt.Errorf("failed to merge %s/%s#%d: status %d: %s", owner, repo, number, res.Status, answer.Message)
+}
+
+// UpdatePullRequestBranch merges the base branch into the head branch of a pull request
+// using the GitHub update branch API. The update fails if the head of the pull request
+// is no longer expectedHeadSHA. An UpdateBranchUnsupportedError is returned if the git
+// provider has no such API.
+func (c *Client) UpdatePullRequestBranch(owner, repo string, number int, expectedHeadSHA string) error {
+	if c.client.Driver != scm.DriverGithub {
+		return UpdateBranchUnsupportedError(fmt.Sprintf("git provider %s cannot update pull request branches", c.client.Driver.String()))
+	}
+	ctx := context.Background()
+	body, err := json.Marshal(map[string]string{"expected_head_sha": expectedHeadSHA})
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(ctx, &scm.Request{
+		Method: http.MethodPut,
+		Path:   fmt.Sprintf("repos/%s/pulls/%d/update-branch", c.repositoryName(owner, repo), number),
+		Header: http.Header{
+			"Accept":       []string{"application/vnd.github.lydian-preview+json"},
+			"Content-Type": []string{"application/json"},
+		},
+		Body: bytes.NewReader(body),
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.Status {
+	case http.StatusAccepted, http.StatusOK:
+		return nil
+	case http.StatusNotFound, http.StatusUnsupportedMediaType:
+		return UpdateBranchUnsupportedError(fmt.Sprintf("updating the branch of %s/%s#%d is not supported: status %d", owner, repo, number, res.Status))
+	}
+	return fmt.Errorf("failed to update the branch of %s/%s#%d: status %d", owner, repo, number, res.Status)
+}
+
+// CreatePullRequest opens a pull request of the head branch against the base branch and
+// returns its number. Only GitHub is supported.
+func (c *Client) CreatePullRequest(owner, repo, title, body, head, base string) (int, error) {
+	if c.client.Driver != scm.DriverGithub {
+		return 0, fmt.Errorf("git provider %s cannot open pull requests", c.client.Driver.String())
+	}
+	ctx := context.Background()
+	data, err := json.Marshal(map[string]string{"title": title, "body": body, "head": head, "base": base})
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.client.Do(ctx, &scm.Request{
+		Method: http.MethodPost,
+		Path:   fmt.Sprintf("repos/%s/pulls", c.repositoryName(owner, repo)),
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	var answer struct {
+		Number  int    `json:"number"`
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&answer)
+	if res.Status != http.StatusCreated {
+		return 0, fmt.Errorf("failed to open a pull request of %s against %s in %s/%s: status %d: %s", head, base, owner, repo, res.Status, answer.Message)
+	}
+	return answer.Number, nil
+}
+
+// UpdateBranchUnsupportedError happens when the git provider cannot update the branch of a PR.
+type UpdateBranchUnsupportedError string
+
+func (e UpdateBranchUnsupportedError) Error() string { return string(e) }
+
 // ModifiedHeadError happens when github refuses to merge a PR because the PR changed.
 type ModifiedHeadError string
 
@@ -78,5 +344,8 @@ func (c *Client) ReopenPR(owner, repo string, number int) error {
 
 // ClosePR closes a pull request
 func (c *Client) ClosePR(owner, repo string, number int) error {
-	panic("implement me")
+	ctx := context.Background()
+	fullName := c.repositoryName(owner, repo)
+	_, err := c.client.PullRequests.Close(ctx, fullName, number)
+	return err
 }
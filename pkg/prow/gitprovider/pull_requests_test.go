@@ -0,0 +1,58 @@
+package gitprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPRCommitsPaginates(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/repos/org/repo/pulls/1/commits", r.URL.Path)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/org/repo/pulls/1/commits?page=2&per_page=100>; rel="next"`, server.URL))
+		}
+		fmt.Fprintf(w, `[{"sha": "sha%d", "commit": {"message": "commit %d"}}]`, page, page)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	c := ToTestClient(scmClient)
+
+	commits, err := c.ListPRCommits("org", "repo", 1)
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+	assert.Equal(t, "sha1", commits[0].Sha)
+	assert.Equal(t, "sha2", commits[1].Sha)
+}
+
+func TestCreatePullRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/repos/org/repo/pulls", r.URL.Path)
+		var input map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&input))
+		assert.Equal(t, map[string]string{"title": "Revert", "body": "why", "head": "revert", "base": "master"}, input)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"number": 7}`)
+	}))
+	defer server.Close()
+
+	scmClient, err := github.New(server.URL)
+	require.NoError(t, err)
+	c := ToTestClient(scmClient)
+
+	number, err := c.CreatePullRequest("org", "repo", "Revert", "why", "revert", "master")
+	require.NoError(t, err)
+	assert.Equal(t, 7, number)
+}
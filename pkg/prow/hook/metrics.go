@@ -17,6 +17,9 @@ limitations under the License.
 package hook
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -25,22 +28,35 @@ var (
 	webhookCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "prow_webhook_counter",
 		Help: "A counter of the webhooks made to prow.",
-	}, []string{"event_type"})
+	}, []string{"provider", "event_type", "action"})
 	responseCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "prow_webhook_response_codes",
 		Help: "A counter of the different responses hook has responded to webhooks with.",
 	}, []string{"response_code"})
+	webhookLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lighthouse_webhook_duration_seconds",
+		Help:    "Histogram of the time taken to handle webhooks by event type.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"event_type"})
+	pluginCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lighthouse_plugin_invocations",
+		Help: "A counter of the plugin handlers invoked for webhooks.",
+	}, []string{"plugin", "event_type"})
 )
 
 func init() {
 	prometheus.MustRegister(webhookCounter)
 	prometheus.MustRegister(responseCounter)
+	prometheus.MustRegister(webhookLatency)
+	prometheus.MustRegister(pluginCounter)
 }
 
 // Metrics is a set of metrics gathered by hook.
 type Metrics struct {
 	WebhookCounter  *prometheus.CounterVec
 	ResponseCounter *prometheus.CounterVec
+	WebhookLatency  *prometheus.HistogramVec
+	PluginCounter   *prometheus.CounterVec
 }
 
 // NewMetrics creates a new set of metrics for the hook server.
@@ -48,5 +64,39 @@ func NewMetrics() *Metrics {
 	return &Metrics{
 		WebhookCounter:  webhookCounter,
 		ResponseCounter: responseCounter,
+		WebhookLatency:  webhookLatency,
+		PluginCounter:   pluginCounter,
+	}
+}
+
+// CountWebhook records a webhook received from the git provider. It is a no-op on nil metrics.
+func (m *Metrics) CountWebhook(provider, eventType, action string) {
+	if m == nil {
+		return
+	}
+	m.WebhookCounter.WithLabelValues(provider, eventType, action).Inc()
+}
+
+// CountResponse records the HTTP status code a webhook was responded to with. It is a no-op on nil metrics.
+func (m *Metrics) CountResponse(code int) {
+	if m == nil {
+		return
+	}
+	m.ResponseCounter.WithLabelValues(strconv.Itoa(code)).Inc()
+}
+
+// ObserveWebhookLatency records how long a webhook took to handle since start. It is a no-op on nil metrics.
+func (m *Metrics) ObserveWebhookLatency(eventType string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.WebhookLatency.WithLabelValues(eventType).Observe(time.Since(start).Seconds())
+}
+
+// CountPlugin records a plugin handler being invoked for an event. It is a no-op on nil metrics.
+func (m *Metrics) CountPlugin(plugin, eventType string) {
+	if m == nil {
+		return
 	}
+	m.PluginCounter.WithLabelValues(plugin, eventType).Inc()
 }
@@ -24,6 +24,8 @@ import (
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/blockade"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/cat"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/cherrypickunapproved"
+	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/commandhelp"
+	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/dco"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/dog"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/help"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/hold"
@@ -32,14 +34,18 @@ import (
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/lifecycle"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/milestone"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/milestonestatus"
+	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/needsrebase"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/override"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/owners-label"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/pony"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/shrug"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/sigmention"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/size"
+	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/slackevents"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/stage"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/trigger"
+	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/updateconfig"
+	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/verifyowners"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/welcome"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/wip"
 	_ "github.com/jenkins-x/lighthouse/pkg/prow/plugins/yuks"
@@ -25,6 +25,8 @@ const (
 	ClaYes          = "cncf-cla: yes"
 	CpApproved      = "cherry-pick-approved"
 	CpUnapproved    = "do-not-merge/cherry-pick-not-approved"
+	DcoNo           = "dco-signoff: no"
+	DcoYes          = "dco-signoff: yes"
 	GoodFirstIssue  = "good first issue"
 	Help            = "help wanted"
 	Hold            = "do-not-merge/hold"
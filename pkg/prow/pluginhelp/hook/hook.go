@@ -0,0 +1,300 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package hook provides the plugin help components to be compiled into the hook binary.
+// This includes the code to fetch help from normal and external plugins and the code to
+// build and serve a pluginhelp.Help struct.
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+const (
+	// PluginHelpRoute is the route the HelpAgent is served on by the webhook server.
+	PluginHelpRoute = "/plugin-help"
+
+	// externalHelpTimeout is how long to wait for external plugins to return their help.
+	externalHelpTimeout = time.Second
+)
+
+type pluginAgent interface {
+	Config() *plugins.Configuration
+}
+
+// HelpAgent is a handler that generates and serve plugin help information.
+type HelpAgent struct {
+	log *logrus.Entry
+	pa  pluginAgent
+}
+
+// NewHelpAgent constructs a new HelpAgent.
+func NewHelpAgent(pa pluginAgent) *HelpAgent {
+	return &HelpAgent{
+		log: logrus.WithField("client", "plugin-help"),
+		pa:  pa,
+	}
+}
+
+func (ha *HelpAgent) generateNormalPluginHelp(config *plugins.Configuration, revMap map[string][]string) (allPlugins []string, pluginHelp map[string]pluginhelp.PluginHelp) {
+	pluginHelp = map[string]pluginhelp.PluginHelp{}
+	for name, provider := range plugins.HelpProviders() {
+		allPlugins = append(allPlugins, name)
+		if provider == nil {
+			ha.log.Warnf("No help is provided for internal plugin %q.", name)
+			continue
+		}
+		help, err := provider(config, revMap[name])
+		if err != nil {
+			ha.log.WithError(err).Errorf("Generating help from normal plugin %q.", name)
+			continue
+		}
+		help.Events = plugins.EventsForPlugin(name)
+		pluginHelp[name] = *help
+	}
+	sort.Strings(allPlugins)
+	return
+}
+
+func (ha *HelpAgent) generateExternalPluginHelp(config *plugins.Configuration, revMap map[string][]string) (allPlugins []string, pluginHelp map[string]pluginhelp.PluginHelp) {
+	externals := map[string]plugins.ExternalPlugin{}
+	for _, exts := range config.ExternalPlugins {
+		for _, ext := range exts {
+			externals[ext.Name] = ext
+		}
+	}
+
+	type externalResult struct {
+		name string
+		help *pluginhelp.PluginHelp
+	}
+	externalResultChan := make(chan externalResult, len(externals))
+	for _, ext := range externals {
+		allPlugins = append(allPlugins, ext.Name)
+		go func(ext plugins.ExternalPlugin) {
+			help, err := externalHelp(ext.Endpoint, revMap[ext.Name])
+			if err != nil {
+				ha.log.WithError(err).Errorf("Getting help from external plugin %q.", ext.Name)
+				help = nil
+			} else {
+				help.Events = ext.Events
+			}
+			externalResultChan <- externalResult{name: ext.Name, help: help}
+		}(ext)
+	}
+	sort.Strings(allPlugins)
+
+	pluginHelp = map[string]pluginhelp.PluginHelp{}
+	timeout := time.After(externalHelpTimeout)
+	for range externals {
+		select {
+		case <-timeout:
+			ha.log.Warn("Timed out waiting for help from external plugins.")
+			return
+		case result := <-externalResultChan:
+			if result.help != nil {
+				pluginHelp[result.name] = *result.help
+			}
+		}
+	}
+	return
+}
+
+// GeneratePluginHelp compiles and returns the help information for all plugins.
+// Plugins enabled for a whole org are only described for the repos which enable
+// them explicitly, as the repos of an org are not listed.
+func (ha *HelpAgent) GeneratePluginHelp() *pluginhelp.Help {
+	return ha.generate(ha.pa.Config(), map[string]sets.String{})
+}
+
+// GenerateRepoPluginHelp compiles and returns the help information for the plugins
+// enabled on the given repo, whether they are enabled for the repo or its org.
+func (ha *HelpAgent) GenerateRepoPluginHelp(org, repo string) *pluginhelp.Help {
+	config := ha.pa.Config()
+	fullName := fmt.Sprintf("%s/%s", org, repo)
+
+	scoped := *config
+	scoped.Plugins = map[string][]string{}
+	for _, key := range []string{org, fullName} {
+		if ps, ok := config.Plugins[key]; ok {
+			scoped.Plugins[key] = ps
+		}
+	}
+	scoped.ExternalPlugins = map[string][]plugins.ExternalPlugin{}
+	for _, key := range []string{org, fullName} {
+		if exts, ok := config.ExternalPlugins[key]; ok {
+			scoped.ExternalPlugins[key] = exts
+		}
+	}
+
+	help := ha.generate(&scoped, map[string]sets.String{org: sets.NewString(fullName)})
+	// Only describe the plugins which are enabled on the repo.
+	enabled := sets.NewString()
+	for _, ps := range scoped.Plugins {
+		enabled.Insert(ps...)
+	}
+	for name := range help.PluginHelp {
+		if !enabled.Has(name) {
+			delete(help.PluginHelp, name)
+		}
+	}
+	help.RepoPlugins[""] = enabled.List()
+	return help
+}
+
+func (ha *HelpAgent) generate(config *plugins.Configuration, orgToRepos map[string]sets.String) *pluginhelp.Help {
+	normalRevMap, externalRevMap := reversePluginMaps(config, orgToRepos)
+
+	allPlugins, pluginHelp := ha.generateNormalPluginHelp(config, normalRevMap)
+
+	allExternalPlugins, externalPluginHelp := ha.generateExternalPluginHelp(config, externalRevMap)
+
+	// Load repo->plugins maps from config
+	repoPlugins := map[string][]string{
+		"": allPlugins,
+	}
+	for repo, plugins := range config.Plugins {
+		repoPlugins[repo] = plugins
+	}
+	repoExternalPlugins := map[string][]string{
+		"": allExternalPlugins,
+	}
+	for repo, exts := range config.ExternalPlugins {
+		for _, ext := range exts {
+			repoExternalPlugins[repo] = append(repoExternalPlugins[repo], ext.Name)
+		}
+	}
+
+	return &pluginhelp.Help{
+		AllRepos:            allRepos(config, orgToRepos),
+		RepoPlugins:         repoPlugins,
+		RepoExternalPlugins: repoExternalPlugins,
+		PluginHelp:          pluginHelp,
+		ExternalPluginHelp:  externalPluginHelp,
+	}
+}
+
+// ServeHTTP serves the JSON help for all plugins, or for the plugins enabled on
+// a single repo if the 'repo' query parameter is given as org/repo.
+func (ha *HelpAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+
+	var help *pluginhelp.Help
+	if fullName := r.URL.Query().Get("repo"); fullName != "" {
+		parts := strings.Split(fullName, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.Error(w, fmt.Sprintf("invalid repo %q, expected org/repo", fullName), http.StatusBadRequest)
+			return
+		}
+		help = ha.GenerateRepoPluginHelp(parts[0], parts[1])
+	} else {
+		help = ha.GeneratePluginHelp()
+	}
+	b, err := json.Marshal(help)
+	if err != nil {
+		ha.log.WithError(err).Error("Marshaling plugin help.")
+		http.Error(w, "failed to marshal plugin help", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(b); err != nil {
+		ha.log.WithError(err).Error("Writing plugin help response.")
+	}
+}
+
+func reversePluginMaps(config *plugins.Configuration, orgToRepos map[string]sets.String) (normal, external map[string][]string) {
+	normal = map[string][]string{}
+	for repo, enabledPlugins := range config.Plugins {
+		repos := flatten(repo, orgToRepos)
+		for _, plugin := range enabledPlugins {
+			normal[plugin] = append(normal[plugin], repos...)
+		}
+	}
+	external = map[string][]string{}
+	for repo, extPlugins := range config.ExternalPlugins {
+		repos := flatten(repo, orgToRepos)
+		for _, plugin := range extPlugins {
+			external[plugin.Name] = append(external[plugin.Name], repos...)
+		}
+	}
+	return
+}
+
+// flatten returns the org/repo strings for a plugin configuration key, which
+// is either an org/repo or an org whose known repos are used.
+func flatten(key string, orgToRepos map[string]sets.String) []string {
+	if strings.Contains(key, "/") {
+		return []string{key}
+	}
+	if repos, ok := orgToRepos[key]; ok {
+		return repos.List()
+	}
+	return nil
+}
+
+func allRepos(config *plugins.Configuration, orgToRepos map[string]sets.String) []string {
+	all := sets.NewString()
+	for repo := range config.Plugins {
+		all.Insert(flatten(repo, orgToRepos)...)
+	}
+	for repo := range config.ExternalPlugins {
+		all.Insert(flatten(repo, orgToRepos)...)
+	}
+	return all.List()
+}
+
+// externalHelp fetches the help of an external plugin by posting the repos it is
+// enabled on to its /help endpoint.
+func externalHelp(endpoint string, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing url: %s err: %v", endpoint, err)
+	}
+	u.Path = path.Join(u.Path, "/help")
+	b, err := json.Marshal(enabledRepos)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling enabled repos: %q, err: %v", enabledRepos, err)
+	}
+
+	client := &http.Client{Timeout: externalHelpTimeout}
+	resp, err := client.Post(u.String(), "application/json", bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("error posting to %s: %v", u.String(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("response to %s has status code %d", u.String(), resp.StatusCode)
+	}
+	var help pluginhelp.PluginHelp
+	if err := json.NewDecoder(resp.Body).Decode(&help); err != nil {
+		return nil, fmt.Errorf("failed to decode json response from %s: %v", u.String(), err)
+	}
+	return &help, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+type fakePluginAgent struct {
+	config *plugins.Configuration
+}
+
+func (f fakePluginAgent) Config() *plugins.Configuration {
+	return f.config
+}
+
+func init() {
+	for _, name := range []string{"org-plugin", "repo-plugin", "unused-plugin"} {
+		name := name
+		plugins.RegisterPushEventHandler(name, func(plugins.Agent, scm.PushHook) error { return nil },
+			func(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+				return &pluginhelp.PluginHelp{Description: name, Config: map[string]string{"repos": fmt.Sprint(enabledRepos)}}, nil
+			})
+	}
+}
+
+func TestGeneratePluginHelp(t *testing.T) {
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/help" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var repos []string
+		if err := json.NewDecoder(r.Body).Decode(&repos); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(pluginhelp.PluginHelp{Description: fmt.Sprint(repos)})
+	}))
+	defer external.Close()
+
+	config := &plugins.Configuration{
+		Plugins: map[string][]string{
+			"org":      {"org-plugin"},
+			"org/repo": {"repo-plugin"},
+		},
+		ExternalPlugins: map[string][]plugins.ExternalPlugin{
+			"org/repo": {{Name: "ext", Endpoint: external.URL, Events: []string{"push"}}},
+		},
+	}
+	ha := NewHelpAgent(fakePluginAgent{config: config})
+
+	help := ha.GeneratePluginHelp()
+	if expected := []string{"org/repo"}; !reflect.DeepEqual(help.AllRepos, expected) {
+		t.Errorf("expected AllRepos %v, got %v", expected, help.AllRepos)
+	}
+	if got := help.PluginHelp["repo-plugin"].Config["repos"]; got != "[org/repo]" {
+		t.Errorf("expected repo-plugin to be enabled on org/repo, got %s", got)
+	}
+	if got := help.PluginHelp["repo-plugin"].Events; !reflect.DeepEqual(got, []string{"push"}) {
+		t.Errorf("expected repo-plugin events to be populated, got %v", got)
+	}
+	ext, ok := help.ExternalPluginHelp["ext"]
+	if !ok {
+		t.Fatalf("expected help for the external plugin, got %v", help.ExternalPluginHelp)
+	}
+	if ext.Description != "[org/repo]" || !reflect.DeepEqual(ext.Events, []string{"push"}) {
+		t.Errorf("unexpected external plugin help %+v", ext)
+	}
+
+	help = ha.GenerateRepoPluginHelp("org", "repo")
+	if expected := []string{"org-plugin", "repo-plugin"}; !reflect.DeepEqual(help.RepoPlugins[""], expected) {
+		t.Errorf("expected plugins %v, got %v", expected, help.RepoPlugins[""])
+	}
+	if _, ok := help.PluginHelp["unused-plugin"]; ok {
+		t.Error("expected no help for plugins which are not enabled on the repo")
+	}
+	if got := help.PluginHelp["org-plugin"].Config["repos"]; got != "[org/repo]" {
+		t.Errorf("expected org-plugin to be enabled on org/repo, got %s", got)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	config := &plugins.Configuration{
+		Plugins: map[string][]string{
+			"org/repo": {"repo-plugin"},
+		},
+	}
+	s := httptest.NewServer(NewHelpAgent(fakePluginAgent{config: config}))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + PluginHelpRoute + "?repo=org/repo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	var help pluginhelp.Help
+	if err := json.NewDecoder(resp.Body).Decode(&help); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := help.PluginHelp["repo-plugin"]; !ok {
+		t.Errorf("expected help for repo-plugin, got %v", help.PluginHelp)
+	}
+
+	resp, err = http.Get(s.URL + PluginHelpRoute + "?repo=invalid")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package commandhelp contains a plugin which replies with the commands enabled on a repo.
+package commandhelp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
+	helphook "github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp/hook"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+const pluginName = "command-help"
+
+var commandHelpRe = regexp.MustCompile(`(?mi)^/lighthouse\s+help\s*$`)
+
+func init() {
+	plugins.RegisterGenericCommentHandler(pluginName, handleGenericComment, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: "The command-help plugin replies with a table of the commands enabled on the repository, who can use them and examples.",
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/lighthouse help",
+		Description: "Lists the commands enabled on this repository.",
+		Featured:    true,
+		WhoCanUse:   "Anyone",
+		Examples:    []string{"/lighthouse help"},
+	})
+	return pluginHelp, nil
+}
+
+type githubClient interface {
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+}
+
+// helpGenerator generates the help of the plugins enabled on a repo
+type helpGenerator interface {
+	GenerateRepoPluginHelp(org, repo string) *pluginhelp.Help
+}
+
+// staticPluginAgent provides the plugin configuration the event is handled with
+type staticPluginAgent struct {
+	config *plugins.Configuration
+}
+
+func (s staticPluginAgent) Config() *plugins.Configuration {
+	return s.config
+}
+
+func handleGenericComment(pc plugins.Agent, e gitprovider.GenericCommentEvent) error {
+	return handle(pc.GitHubClient, helphook.NewHelpAgent(staticPluginAgent{config: pc.PluginConfig}), e)
+}
+
+func handle(gc githubClient, hg helpGenerator, e gitprovider.GenericCommentEvent) error {
+	if e.Action != scm.ActionCreate || !commandHelpRe.MatchString(e.Body) {
+		return nil
+	}
+	org := e.Repo.Namespace
+	repo := e.Repo.Name
+
+	table := commandTable(hg.GenerateRepoPluginHelp(org, repo))
+	return gc.CreateComment(org, repo, e.Number, e.IsPR, plugins.FormatResponseRaw(e.Body, e.Link, e.Author.Login, table))
+}
+
+// commandTable renders the commands of the plugins in the help as a markdown table
+func commandTable(help *pluginhelp.Help) string {
+	type row struct {
+		plugin  string
+		command pluginhelp.Command
+	}
+	var rows []row
+	addRows := func(helps map[string]pluginhelp.PluginHelp) {
+		for name, h := range helps {
+			for _, c := range h.Commands {
+				rows = append(rows, row{plugin: name, command: c})
+			}
+		}
+	}
+	addRows(help.PluginHelp)
+	addRows(help.ExternalPluginHelp)
+	if len(rows) == 0 {
+		return "There are no commands enabled on this repository."
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].plugin != rows[j].plugin {
+			return rows[i].plugin < rows[j].plugin
+		}
+		return rows[i].command.Usage < rows[j].command.Usage
+	})
+
+	lines := []string{
+		"The following commands are enabled on this repository:",
+		"",
+		"| Command | Plugin | Description | Who can use | Examples |",
+		"| --- | --- | --- | --- | --- |",
+	}
+	for _, r := range rows {
+		var examples []string
+		for _, example := range r.command.Examples {
+			examples = append(examples, fmt.Sprintf("`%s`", cell(example)))
+		}
+		lines = append(lines, fmt.Sprintf("| `%s` | %s | %s | %s | %s |",
+			cell(r.command.Usage), r.plugin, cell(r.command.Description), cell(r.command.WhoCanUse), strings.Join(examples, "<br>")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cell escapes text so that it fits in a single markdown table cell
+func cell(s string) string {
+	s = strings.Replace(s, "|", "\\|", -1)
+	return strings.Join(strings.Fields(s), " ")
+}
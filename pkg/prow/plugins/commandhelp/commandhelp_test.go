@@ -0,0 +1,135 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package commandhelp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/fakegitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
+)
+
+type fakeHelpGenerator struct {
+	org, repo string
+}
+
+func (f *fakeHelpGenerator) GenerateRepoPluginHelp(org, repo string) *pluginhelp.Help {
+	f.org, f.repo = org, repo
+	return &pluginhelp.Help{
+		PluginHelp: map[string]pluginhelp.PluginHelp{
+			"lgtm": {
+				Commands: []pluginhelp.Command{{
+					Usage:       "/lgtm [cancel]",
+					Description: "Adds or removes the 'lgtm' label.",
+					WhoCanUse:   "Collaborators on the repository.",
+					Examples:    []string{"/lgtm", "/lgtm cancel"},
+				}},
+			},
+			"lifecycle": {
+				Commands: []pluginhelp.Command{{
+					Usage:       "/[remove-]lifecycle <frozen|stale|rotten>",
+					Description: "Flags an issue or PR\nas frozen/stale/rotten.",
+					WhoCanUse:   "Anyone",
+				}},
+			},
+		},
+		ExternalPluginHelp: map[string]pluginhelp.PluginHelp{
+			"external": {},
+		},
+	}
+}
+
+func TestHandle(t *testing.T) {
+	testcases := []struct {
+		name          string
+		action        scm.Action
+		body          string
+		expectComment bool
+	}{
+		{
+			name:          "help command replies with the commands",
+			action:        scm.ActionCreate,
+			body:          "/lighthouse help",
+			expectComment: true,
+		},
+		{
+			name:   "edited comments are ignored",
+			action: scm.ActionEdit,
+			body:   "/lighthouse help",
+		},
+		{
+			name:   "other comments are ignored",
+			action: scm.ActionCreate,
+			body:   "/help",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			fgc := &fakegitprovider.FakeClient{
+				IssueComments:       map[int][]*scm.Comment{},
+				PullRequestComments: map[int][]*scm.Comment{},
+			}
+			hg := &fakeHelpGenerator{}
+			e := gitprovider.GenericCommentEvent{
+				Action: tc.action,
+				Body:   tc.body,
+				IsPR:   true,
+				Number: 5,
+				Repo:   scm.Repository{Namespace: "org", Name: "repo"},
+				Author: scm.User{Login: "user"},
+			}
+			if err := handle(fgc, hg, e); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.expectComment {
+				if len(fgc.PullRequestCommentsAdded) != 0 {
+					t.Errorf("expected no comment, got %v", fgc.PullRequestCommentsAdded)
+				}
+				return
+			}
+			if len(fgc.PullRequestCommentsAdded) != 1 {
+				t.Fatalf("expected a comment, got %v", fgc.PullRequestCommentsAdded)
+			}
+			if hg.org != "org" || hg.repo != "repo" {
+				t.Errorf("expected help for org/repo, got %s/%s", hg.org, hg.repo)
+			}
+			comment := fgc.PullRequestCommentsAdded[0]
+			for _, expected := range []string{
+				"| `/[remove-]lifecycle <frozen\\|stale\\|rotten>` | lifecycle | Flags an issue or PR as frozen/stale/rotten. | Anyone |  |",
+				"| `/lgtm [cancel]` | lgtm | Adds or removes the 'lgtm' label. | Collaborators on the repository. | `/lgtm`<br>`/lgtm cancel` |",
+			} {
+				if !strings.Contains(comment, expected) {
+					t.Errorf("expected comment to contain %q, got %q", expected, comment)
+				}
+			}
+			if strings.Index(comment, "lifecycle") > strings.Index(comment, "`/lgtm") {
+				t.Errorf("expected commands sorted by plugin, got %q", comment)
+			}
+		})
+	}
+}
+
+func TestCommandTableWithoutCommands(t *testing.T) {
+	table := commandTable(&pluginhelp.Help{})
+	if table != "There are no commands enabled on this repository." {
+		t.Errorf("unexpected table %q", table)
+	}
+}
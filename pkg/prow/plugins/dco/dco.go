@@ -0,0 +1,268 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dco implements a DCO (https://developercertificate.org/) checker plugin
+package dco
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/labels"
+	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+const (
+	pluginName               = "dco"
+	dcoContextName           = "dco"
+	dcoContextMessageFailed  = "Commits in PR missing Signed-off-by"
+	dcoContextMessageSuccess = "All commits have Signed-off-by"
+
+	dcoMsgPruneMatch   = "Thanks for your pull request. Before we can look at it, you'll need to add a 'DCO signoff' to your commits."
+	dcoNotFoundMessage = `Thanks for your pull request. Before we can look at it, you'll need to add a 'DCO signoff' to your commits.
+
+Every commit needs a ` + "`Signed-off-by`" + ` line matching the name and email of its author or committer, which certifies that you wrote the change or otherwise have the right to submit it under the project's license. You can sign off your existing commits with:
+
+` + "```" + `
+git rebase --signoff origin/%s
+git push --force-with-lease
+` + "```" + `
+
+Full details of the Developer Certificate of Origin can be found at [developercertificate.org](https://developercertificate.org/).
+
+**The list of commits missing DCO signoff**:
+
+%s
+
+<details>
+
+%s
+</details>
+`
+)
+
+var (
+	checkDCORe = regexp.MustCompile(`(?mi)^/check-dco\s*$`)
+	signOffRe  = regexp.MustCompile(`(?mi)^signed-off-by:\s*(.*?)\s*<([^>]*)>\s*$`)
+)
+
+func init() {
+	plugins.RegisterPullRequestHandler(pluginName, handlePullRequest, helpProvider)
+	plugins.RegisterGenericCommentHandler(pluginName, handleComment, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+	pluginHelp := &pluginhelp.PluginHelp{
+		Description: fmt.Sprintf("The dco plugin checks that every commit of a pull request has a 'Signed-off-by' line matching its author or committer, as required by the [Developer Certificate of Origin](https://developercertificate.org/). It sets the '%s' status context and the '%s' or '%s' label.", dcoContextName, labels.DcoYes, labels.DcoNo),
+	}
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/check-dco",
+		Description: "Forces rechecking of the DCO status.",
+		Featured:    true,
+		WhoCanUse:   "Anyone",
+		Examples:    []string{"/check-dco"},
+	})
+	return pluginHelp, nil
+}
+
+type gitHubClient interface {
+	AddLabel(owner, repo string, number int, label string, pr bool) error
+	RemoveLabel(owner, repo string, number int, label string, pr bool) error
+	GetIssueLabels(org, repo string, number int, pr bool) ([]*scm.Label, error)
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+	CreateStatus(owner, repo, ref string, s *scm.StatusInput) (*scm.Status, error)
+	GetPullRequest(owner, repo string, number int) (*scm.PullRequest, error)
+	ListPRCommits(org, repo string, number int) ([]scm.Commit, error)
+}
+
+type commentPruner interface {
+	PruneComments(pr bool, shouldPrune func(*scm.Comment) bool)
+}
+
+func handlePullRequest(pc plugins.Agent, pe scm.PullRequestHook) error {
+	cp, err := pc.CommentPruner()
+	if err != nil {
+		return err
+	}
+	return handlePR(pc.GitHubClient, pc.Logger, cp, pe)
+}
+
+func handlePR(gc gitHubClient, log *logrus.Entry, cp commentPruner, pe scm.PullRequestHook) error {
+	if pe.Action != scm.ActionOpen && pe.Action != scm.ActionReopen && pe.Action != scm.ActionSync {
+		return nil
+	}
+	return handle(gc, log, cp, pe.Repo.Namespace, pe.Repo.Name, &pe.PullRequest)
+}
+
+func handleComment(pc plugins.Agent, ce gitprovider.GenericCommentEvent) error {
+	cp, err := pc.CommentPruner()
+	if err != nil {
+		return err
+	}
+	return handleCommentEvent(pc.GitHubClient, pc.Logger, cp, ce)
+}
+
+func handleCommentEvent(gc gitHubClient, log *logrus.Entry, cp commentPruner, ce gitprovider.GenericCommentEvent) error {
+	// Only consider open PRs and new comments.
+	if ce.IssueState != "open" || ce.Action != scm.ActionCreate || !ce.IsPR {
+		return nil
+	}
+	if !checkDCORe.MatchString(ce.Body) {
+		return nil
+	}
+	org := ce.Repo.Namespace
+	repo := ce.Repo.Name
+	pr, err := gc.GetPullRequest(org, repo, ce.Number)
+	if err != nil {
+		return fmt.Errorf("error getting pull request for comment: %v", err)
+	}
+	return handle(gc, log, cp, org, repo, pr)
+}
+
+// handle checks the commits of a pull request and reports the result.
+func handle(gc gitHubClient, log *logrus.Entry, cp commentPruner, org, repo string, pr *scm.PullRequest) error {
+	commits, err := gc.ListPRCommits(org, repo, pr.Number)
+	if err != nil {
+		return fmt.Errorf("error listing commits for pull request: %v", err)
+	}
+	log.Debugf("Found %d commits in PR", len(commits))
+
+	commitsMissingDCO := checkCommitMessages(commits)
+	return takeAction(gc, log, cp, org, repo, pr, commitsMissingDCO)
+}
+
+// checkCommitMessages returns the commits which are not signed off by their author or committer.
+func checkCommitMessages(commits []scm.Commit) []scm.Commit {
+	var commitsMissingDCO []scm.Commit
+	for _, commit := range commits {
+		if !isSignedOff(commit) {
+			commitsMissingDCO = append(commitsMissingDCO, commit)
+		}
+	}
+	return commitsMissingDCO
+}
+
+func isSignedOff(commit scm.Commit) bool {
+	for _, match := range signOffRe.FindAllStringSubmatch(commit.Message, -1) {
+		name, email := match[1], match[2]
+		for _, signature := range []scm.Signature{commit.Author, commit.Committer} {
+			if signature.Email != "" && strings.EqualFold(signature.Email, email) {
+				return true
+			}
+			if signature.Email == "" && signature.Name != "" && signature.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// takeAction adds or removes the DCO labels, sets the status context and posts or prunes
+// the comment explaining how to sign off commits.
+func takeAction(gc gitHubClient, log *logrus.Entry, cp commentPruner, org, repo string, pr *scm.PullRequest, commitsMissingDCO []scm.Commit) error {
+	number := pr.Number
+	signedOff := len(commitsMissingDCO) == 0
+
+	issueLabels, err := gc.GetIssueLabels(org, repo, number, true)
+	if err != nil {
+		return fmt.Errorf("failed to get the labels of %s/%s#%d: %v", org, repo, number, err)
+	}
+	hasYesLabel, hasNoLabel := false, false
+	for _, l := range issueLabels {
+		hasYesLabel = hasYesLabel || l.Name == labels.DcoYes
+		hasNoLabel = hasNoLabel || l.Name == labels.DcoNo
+	}
+
+	status := &scm.StatusInput{
+		State: scm.StateSuccess,
+		Label: dcoContextName,
+		Desc:  dcoContextMessageSuccess,
+	}
+	if !signedOff {
+		status.State = scm.StateFailure
+		status.Desc = dcoContextMessageFailed
+	}
+	if _, err := gc.CreateStatus(org, repo, pr.Head.Sha, status); err != nil {
+		return fmt.Errorf("error setting pull request status: %v", err)
+	}
+
+	if signedOff {
+		if hasNoLabel {
+			log.Infof("Removing %q label", labels.DcoNo)
+			if err := gc.RemoveLabel(org, repo, number, labels.DcoNo, true); err != nil {
+				log.WithError(err).Errorf("Failed to remove %q label", labels.DcoNo)
+			}
+			// The comment listing the commits missing a sign off is now resolved.
+			cp.PruneComments(true, func(comment *scm.Comment) bool {
+				return strings.Contains(comment.Body, dcoMsgPruneMatch)
+			})
+		}
+		if !hasYesLabel {
+			log.Infof("Adding %q label", labels.DcoYes)
+			if err := gc.AddLabel(org, repo, number, labels.DcoYes, true); err != nil {
+				log.WithError(err).Errorf("Failed to add %q label", labels.DcoYes)
+			}
+		}
+		return nil
+	}
+
+	if hasYesLabel {
+		log.Infof("Removing %q label", labels.DcoYes)
+		if err := gc.RemoveLabel(org, repo, number, labels.DcoYes, true); err != nil {
+			log.WithError(err).Errorf("Failed to remove %q label", labels.DcoYes)
+		}
+	}
+	if !hasNoLabel {
+		log.Infof("Adding %q label", labels.DcoNo)
+		if err := gc.AddLabel(org, repo, number, labels.DcoNo, true); err != nil {
+			log.WithError(err).Errorf("Failed to add %q label", labels.DcoNo)
+		}
+	}
+	// Replace any previous comment as the commits missing a sign off may have changed.
+	cp.PruneComments(true, func(comment *scm.Comment) bool {
+		return strings.Contains(comment.Body, dcoMsgPruneMatch)
+	})
+	msg := fmt.Sprintf(dcoNotFoundMessage, pr.Base.Ref, markdownSHAList(commitsMissingDCO), plugins.AboutThisBot)
+	return gc.CreateComment(org, repo, number, true, msg)
+}
+
+// markdownSHAList returns a markdown list of the commits, linking to them where possible.
+func markdownSHAList(list []scm.Commit) string {
+	lines := make([]string, len(list))
+	for i, commit := range list {
+		// if we somehow encounter a SHA that's less than 7 characters, we will just use it as is.
+		shortSHA := commit.Sha
+		if len(shortSHA) > 7 {
+			shortSHA = shortSHA[:7]
+		}
+
+		// get the first line of the commit
+		message := strings.Split(commit.Message, "\n")[0]
+
+		if commit.Link != "" {
+			lines[i] = fmt.Sprintf("- [%s](%s) %s", shortSHA, commit.Link, message)
+		} else {
+			lines[i] = fmt.Sprintf("- `%s` %s", shortSHA, message)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
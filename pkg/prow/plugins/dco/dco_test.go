@@ -0,0 +1,242 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dco
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/commentpruner"
+	"github.com/jenkins-x/lighthouse/pkg/prow/fakegitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/labels"
+)
+
+var (
+	alice = scm.Signature{Name: "Alice", Email: "alice@example.com"}
+	bob   = scm.Signature{Name: "Bob", Email: "bob@example.com"}
+)
+
+func TestIsSignedOff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		commit   scm.Commit
+		expected bool
+	}{
+		{
+			name:     "signed off by author",
+			commit:   scm.Commit{Message: "fix\n\nSigned-off-by: Alice <alice@example.com>", Author: alice, Committer: bob},
+			expected: true,
+		},
+		{
+			name:     "signed off by committer with a different case",
+			commit:   scm.Commit{Message: "fix\n\nsigned-off-by: Bob <BOB@example.com>\n", Author: alice, Committer: bob},
+			expected: true,
+		},
+		{
+			name:   "signed off by someone else",
+			commit: scm.Commit{Message: "fix\n\nSigned-off-by: Mallory <mallory@example.com>", Author: alice, Committer: bob},
+		},
+		{
+			name:   "sign off not on its own line",
+			commit: scm.Commit{Message: "fix Signed-off-by: Alice <alice@example.com>", Author: alice, Committer: alice},
+		},
+		{
+			name:   "no sign off",
+			commit: scm.Commit{Message: "fix", Author: alice, Committer: alice},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isSignedOff(tc.commit))
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	signed := scm.Commit{Sha: "1111111111", Message: "signed\n\nSigned-off-by: Alice <alice@example.com>", Author: alice, Committer: alice}
+	unsigned := scm.Commit{Sha: "2222222222", Message: "unsigned", Author: alice, Committer: alice}
+
+	testCases := []struct {
+		name           string
+		commits        []scm.Commit
+		existingLabels []string
+		hasComment     bool
+
+		expectedState   scm.State
+		expectedAdded   []string
+		expectedRemoved []string
+		expectComment   bool
+		expectPruned    bool
+	}{
+		{
+			name:          "all commits signed off",
+			commits:       []scm.Commit{signed},
+			expectedState: scm.StateSuccess,
+			expectedAdded: []string{labels.DcoYes},
+		},
+		{
+			name:          "commit missing sign off",
+			commits:       []scm.Commit{signed, unsigned},
+			expectedState: scm.StateFailure,
+			expectedAdded: []string{labels.DcoNo},
+			expectComment: true,
+		},
+		{
+			name:            "sign off fixed",
+			commits:         []scm.Commit{signed},
+			existingLabels:  []string{labels.DcoNo},
+			hasComment:      true,
+			expectedState:   scm.StateSuccess,
+			expectedAdded:   []string{labels.DcoYes},
+			expectedRemoved: []string{labels.DcoNo},
+			expectPruned:    true,
+		},
+		{
+			name:            "sign off removed",
+			commits:         []scm.Commit{unsigned},
+			existingLabels:  []string{labels.DcoYes},
+			expectedState:   scm.StateFailure,
+			expectedAdded:   []string{labels.DcoNo},
+			expectedRemoved: []string{labels.DcoYes},
+			expectComment:   true,
+		},
+		{
+			name:           "still signed off",
+			commits:        []scm.Commit{signed},
+			existingLabels: []string{labels.DcoYes},
+			expectedState:  scm.StateSuccess,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakegitprovider.FakeClient{
+				CommitMap:           map[string][]scm.Commit{"org/repo#1": tc.commits},
+				PullRequestComments: map[int][]*scm.Comment{},
+				IssueComments:       map[int][]*scm.Comment{},
+			}
+			for _, l := range tc.existingLabels {
+				fc.PullRequestLabelsExisting = append(fc.PullRequestLabelsExisting, "org/repo#1:"+l)
+			}
+			if tc.hasComment {
+				fc.PullRequestComments[1] = []*scm.Comment{{ID: 42, Body: dcoMsgPruneMatch, Author: scm.User{Login: fakegitprovider.Bot}}}
+			}
+			pe := scm.PullRequestHook{
+				Action: scm.ActionSync,
+				Repo:   scm.Repository{Namespace: "org", Name: "repo"},
+				PullRequest: scm.PullRequest{
+					Number: 1,
+					Base:   scm.PullRequestBranch{Ref: "master"},
+					Head:   scm.PullRequestBranch{Sha: "abcdef"},
+				},
+			}
+			log := logrus.WithField("plugin", pluginName)
+			cp := commentpruner.NewEventClient(fc, log, "org", "repo", 1)
+
+			require.NoError(t, handlePR(fc, log, cp, pe))
+
+			require.Len(t, fc.CreatedStatuses["abcdef"], 1)
+			status := fc.CreatedStatuses["abcdef"][0]
+			assert.Equal(t, dcoContextName, status.Label)
+			assert.Equal(t, tc.expectedState, status.State)
+
+			var added, removed []string
+			for _, l := range tc.expectedAdded {
+				added = append(added, "org/repo#1:"+l)
+			}
+			for _, l := range tc.expectedRemoved {
+				removed = append(removed, "org/repo#1:"+l)
+			}
+			assert.Equal(t, added, fc.PullRequestLabelsAdded)
+			assert.Equal(t, removed, fc.PullRequestLabelsRemoved)
+
+			if tc.expectComment {
+				require.Len(t, fc.PullRequestCommentsAdded, 1)
+				assert.Contains(t, fc.PullRequestCommentsAdded[0], "- `2222222` unsigned")
+				assert.Contains(t, fc.PullRequestCommentsAdded[0], "git rebase --signoff origin/master")
+				assert.NotContains(t, fc.PullRequestCommentsAdded[0], "1111111")
+			} else {
+				assert.Empty(t, fc.PullRequestCommentsAdded)
+			}
+			if tc.expectPruned {
+				assert.Equal(t, []string{"org/repo#42"}, fc.PullRequestCommentsDeleted)
+			} else {
+				assert.Empty(t, fc.PullRequestCommentsDeleted)
+			}
+		})
+	}
+}
+
+func TestHandleCommentEvent(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		state       string
+		expectCheck bool
+	}{
+		{
+			name:        "check-dco on open PR",
+			body:        "/check-dco",
+			state:       "open",
+			expectCheck: true,
+		},
+		{
+			name:  "check-dco on closed PR",
+			body:  "/check-dco",
+			state: "closed",
+		},
+		{
+			name:  "other comment",
+			body:  "/check-dco please",
+			state: "open",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakegitprovider.FakeClient{
+				CommitMap: map[string][]scm.Commit{"org/repo#1": {{Sha: "1111111111", Message: "unsigned"}}},
+				PullRequests: map[int]*scm.PullRequest{
+					1: {Number: 1, Head: scm.PullRequestBranch{Sha: "abcdef"}},
+				},
+				PullRequestComments: map[int][]*scm.Comment{},
+				IssueComments:       map[int][]*scm.Comment{},
+			}
+			ce := gitprovider.GenericCommentEvent{
+				IsPR:       true,
+				Action:     scm.ActionCreate,
+				Body:       tc.body,
+				Number:     1,
+				Repo:       scm.Repository{Namespace: "org", Name: "repo"},
+				IssueState: tc.state,
+			}
+			log := logrus.WithField("plugin", pluginName)
+			cp := commentpruner.NewEventClient(fc, log, "org", "repo", 1)
+
+			require.NoError(t, handleCommentEvent(fc, log, cp, ce))
+
+			if tc.expectCheck {
+				assert.Len(t, fc.CreatedStatuses["abcdef"], 1)
+			} else {
+				assert.Empty(t, fc.CreatedStatuses)
+			}
+		})
+	}
+}
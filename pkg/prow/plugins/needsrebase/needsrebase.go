@@ -0,0 +1,218 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package needsrebase contains a plugin which labels pull requests which cannot be merged
+// into their base branch without a rebase.
+package needsrebase
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/commentpruner"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/labels"
+	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+const (
+	pluginName         = "needs-rebase"
+	needsRebaseMessage = "This PR cannot be merged into its base branch because of conflicts. Please rebase it on, or merge, the base branch and resolve the conflicts. The `" + labels.NeedsRebase + "` label is removed once it can be merged."
+	// oldNeedsRebaseMessage is the message of the comments made by previous versions
+	// of the plugin, which are pruned too
+	oldNeedsRebaseMessage = "PR needs rebase."
+)
+
+var (
+	// recheckAttempts and recheckDelay bound how long the pull requests whose
+	// mergeability the git provider has not computed yet, e.g. right after a push,
+	// are checked again for
+	recheckAttempts = 3
+	recheckDelay    = 10 * time.Second
+	// afterFunc schedules the deferred checks
+	afterFunc = time.AfterFunc
+
+	// rechecks holds the pull requests with a deferred check, so that the checks of
+	// successive events do not pile up
+	rechecks     = map[string]bool{}
+	rechecksLock sync.Mutex
+)
+
+func init() {
+	plugins.RegisterPullRequestHandler(pluginName, handlePullRequest, helpProvider)
+	plugins.RegisterPushEventHandler(pluginName, handlePush, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+	// The {WhoCanUse, Usage, Examples} fields are omitted because this plugin cannot be triggered manually.
+	return &pluginhelp.PluginHelp{
+			Description: "The needs-rebase plugin manages the '" + labels.NeedsRebase + "' label by checking whether pull requests can be merged into their base branch. Pull requests are checked when they are opened or updated, and all the open pull requests of a branch are checked again when the branch is pushed to. Pull requests whose mergeability is not computed yet are checked again a little later.",
+		},
+		nil
+}
+
+type githubClient interface {
+	GetPullRequest(org, repo string, number int) (*scm.PullRequest, error)
+	GetPullRequestMergeability(org, repo string, number int) (gitprovider.Mergeability, error)
+	ListOpenPullRequests(org, repo string) ([]*scm.PullRequest, error)
+	GetIssueLabels(org, repo string, number int, pr bool) ([]*scm.Label, error)
+	AddLabel(org, repo string, number int, label string, pr bool) error
+	RemoveLabel(org, repo string, number int, label string, pr bool) error
+	CreateComment(org, repo string, number int, pr bool, comment string) error
+	BotName() (string, error)
+	ListIssueComments(org, repo string, number int) ([]*scm.Comment, error)
+	ListPullRequestComments(org, repo string, number int) ([]*scm.Comment, error)
+	DeleteComment(org, repo string, number, id int, pr bool) error
+}
+
+type pruneClient interface {
+	PruneComments(bool, func(*scm.Comment) bool)
+}
+
+func handlePullRequest(pc plugins.Agent, pre scm.PullRequestHook) error {
+	cp, err := pc.CommentPruner()
+	if err != nil {
+		return err
+	}
+	return handlePR(pc.GitHubClient, pc.Logger, cp, pre)
+}
+
+func handlePR(ghc githubClient, log *logrus.Entry, cp pruneClient, pre scm.PullRequestHook) error {
+	if pre.Action != scm.ActionOpen && pre.Action != scm.ActionReopen && pre.Action != scm.ActionSync {
+		return nil
+	}
+	org := pre.Repo.Namespace
+	repo := pre.Repo.Name
+	number := pre.PullRequest.Number
+
+	// The pull request in the webhook payload is often stale, so fetch it again.
+	pr, err := ghc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get pull request %s/%s#%d: %v", org, repo, number, err)
+	}
+	return takeAction(ghc, log, cp, org, repo, pr)
+}
+
+func handlePush(pc plugins.Agent, pe scm.PushHook) error {
+	return handlePushEvent(pc.GitHubClient, pc.Logger, pe)
+}
+
+// handlePushEvent checks the open pull requests of the pushed branch, whose mergeability
+// may have changed with the push.
+func handlePushEvent(ghc githubClient, log *logrus.Entry, pe scm.PushHook) error {
+	if pe.Deleted || !strings.HasPrefix(pe.Ref, "refs/heads/") {
+		return nil
+	}
+	org := pe.Repo.Namespace
+	repo := pe.Repo.Name
+	branch := strings.TrimPrefix(pe.Ref, "refs/heads/")
+
+	prs, err := ghc.ListOpenPullRequests(org, repo)
+	if err != nil {
+		return fmt.Errorf("failed to list open pull requests of %s/%s: %v", org, repo, err)
+	}
+	for _, p := range prs {
+		if p.Base.Ref != branch {
+			continue
+		}
+		l := log.WithField("pr", p.Number)
+		cp := commentpruner.NewEventClient(ghc, l.WithField("client", "commentpruner"), org, repo, p.Number)
+		if err := takeAction(ghc, l, cp, org, repo, p); err != nil {
+			l.WithError(err).Error("Failed to update the needs-rebase label.")
+		}
+	}
+	return nil
+}
+
+// scheduleRecheck checks the pull request again after a while unless a check is
+// already scheduled. The event is not held up while the git provider computes the
+// mergeability.
+func scheduleRecheck(ghc githubClient, log *logrus.Entry, cp pruneClient, org, repo string, pr *scm.PullRequest, attempt int) {
+	key := fmt.Sprintf("%s/%s#%d", org, repo, pr.Number)
+	rechecksLock.Lock()
+	defer rechecksLock.Unlock()
+	if rechecks[key] {
+		return
+	}
+	rechecks[key] = true
+	afterFunc(recheckDelay, func() {
+		rechecksLock.Lock()
+		delete(rechecks, key)
+		rechecksLock.Unlock()
+		if err := check(ghc, log, cp, org, repo, pr, attempt); err != nil {
+			log.WithError(err).Error("Failed to update the needs-rebase label.")
+		}
+	})
+}
+
+// takeAction adds or removes the needs-rebase label and its explanatory comment depending
+// on whether the pull request can be merged. While the git provider has not computed the
+// mergeability, the pull request is checked again a few times after a while.
+func takeAction(ghc githubClient, log *logrus.Entry, cp pruneClient, org, repo string, pr *scm.PullRequest) error {
+	return check(ghc, log, cp, org, repo, pr, 0)
+}
+
+// check is takeAction for the given attempt of the deferred checks.
+func check(ghc githubClient, log *logrus.Entry, cp pruneClient, org, repo string, pr *scm.PullRequest, attempt int) error {
+	m, err := ghc.GetPullRequestMergeability(org, repo, pr.Number)
+	if err != nil {
+		return fmt.Errorf("failed to get the mergeability of %s/%s#%d: %v", org, repo, pr.Number, err)
+	}
+	if m == gitprovider.MergeabilityUnknown {
+		if attempt < recheckAttempts {
+			log.Info("The mergeability is not known yet, checking the pull request again later.")
+			scheduleRecheck(ghc, log, cp, org, repo, pr, attempt+1)
+		} else {
+			log.Info("The mergeability is still not known, the pull request is checked again when it or its base branch changes.")
+		}
+		return nil
+	}
+	issueLabels, err := ghc.GetIssueLabels(org, repo, pr.Number, true)
+	if err != nil {
+		return fmt.Errorf("failed to get the labels of %s/%s#%d: %v", org, repo, pr.Number, err)
+	}
+	hasLabel := false
+	for _, l := range issueLabels {
+		if l.Name == labels.NeedsRebase {
+			hasLabel = true
+			break
+		}
+	}
+
+	if m == gitprovider.MergeabilityConflicting && !hasLabel {
+		log.Infof("Adding %q label.", labels.NeedsRebase)
+		if err := ghc.AddLabel(org, repo, pr.Number, labels.NeedsRebase, true); err != nil {
+			return err
+		}
+		msg := plugins.FormatSimpleResponse(pr.Author.Login, needsRebaseMessage)
+		return ghc.CreateComment(org, repo, pr.Number, true, msg)
+	} else if m == gitprovider.MergeabilityMergeable && hasLabel {
+		log.Infof("Removing %q label.", labels.NeedsRebase)
+		if err := ghc.RemoveLabel(org, repo, pr.Number, labels.NeedsRebase, true); err != nil {
+			return err
+		}
+		cp.PruneComments(true, func(c *scm.Comment) bool {
+			return strings.Contains(c.Body, needsRebaseMessage) || strings.Contains(c.Body, oldNeedsRebaseMessage)
+		})
+	}
+	return nil
+}
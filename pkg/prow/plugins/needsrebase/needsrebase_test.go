@@ -0,0 +1,215 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package needsrebase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/commentpruner"
+	"github.com/jenkins-x/lighthouse/pkg/prow/fakegitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/labels"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+func testPR(number int, base string, mergeable bool) *scm.PullRequest {
+	return &scm.PullRequest{
+		Number:    number,
+		Mergeable: mergeable,
+		Author:    scm.User{Login: "author"},
+		Base:      scm.PullRequestBranch{Ref: base},
+	}
+}
+
+func newFakeClient(prs ...*scm.PullRequest) *fakegitprovider.FakeClient {
+	fc := &fakegitprovider.FakeClient{
+		PullRequests:        map[int]*scm.PullRequest{},
+		PullRequestComments: map[int][]*scm.Comment{},
+		IssueComments:       map[int][]*scm.Comment{},
+	}
+	for _, pr := range prs {
+		fc.PullRequests[pr.Number] = pr
+	}
+	return fc
+}
+
+// deferChecks captures the deferred checks, which are run by the returned function until
+// none is left. It returns how many ran.
+func deferChecks() func() int {
+	var scheduled []func()
+	afterFunc = func(_ time.Duration, f func()) *time.Timer {
+		scheduled = append(scheduled, f)
+		return nil
+	}
+	return func() int {
+		ran := 0
+		for len(scheduled) > 0 {
+			f := scheduled[0]
+			scheduled = scheduled[1:]
+			f()
+			ran++
+		}
+		return ran
+	}
+}
+
+func TestHandlePR(t *testing.T) {
+	message := plugins.FormatSimpleResponse("author", needsRebaseMessage)
+	testCases := []struct {
+		name      string
+		action    scm.Action
+		mergeable bool
+		unknown   bool
+		// knownLater makes the mergeability known by the first deferred check
+		knownLater bool
+		hasLabel   bool
+
+		expectAdded    bool
+		expectRemoved  bool
+		expectComment  bool
+		expectRechecks int
+	}{
+		{
+			name:          "unmergeable PR is labeled",
+			action:        scm.ActionOpen,
+			expectAdded:   true,
+			expectComment: true,
+		},
+		{
+			name:     "unmergeable PR already labeled",
+			action:   scm.ActionSync,
+			hasLabel: true,
+		},
+		{
+			name:          "mergeable PR is unlabeled",
+			action:        scm.ActionSync,
+			mergeable:     true,
+			hasLabel:      true,
+			expectRemoved: true,
+		},
+		{
+			name:      "mergeable PR without label",
+			action:    scm.ActionReopen,
+			mergeable: true,
+		},
+		{
+			name:           "PR of unknown mergeability is not labeled",
+			action:         scm.ActionSync,
+			unknown:        true,
+			expectRechecks: 3,
+		},
+		{
+			name:           "PR of unknown mergeability keeps its label",
+			action:         scm.ActionSync,
+			unknown:        true,
+			hasLabel:       true,
+			expectRechecks: 3,
+		},
+		{
+			name:           "PR whose mergeability is computed later is labeled",
+			action:         scm.ActionSync,
+			unknown:        true,
+			knownLater:     true,
+			expectAdded:    true,
+			expectComment:  true,
+			expectRechecks: 1,
+		},
+		{
+			name:   "other actions are ignored",
+			action: scm.ActionClose,
+		},
+	}
+	defer func() { afterFunc = time.AfterFunc }()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fc := newFakeClient(testPR(1, "master", tc.mergeable))
+			if tc.unknown {
+				fc.PullRequestMergeability = map[int]gitprovider.Mergeability{1: gitprovider.MergeabilityUnknown}
+			}
+			if tc.hasLabel {
+				fc.PullRequestLabelsExisting = []string{"org/repo#1:" + labels.NeedsRebase}
+				fc.PullRequestComments[1] = []*scm.Comment{{ID: 42, Body: message, Author: scm.User{Login: fakegitprovider.Bot}}}
+			}
+			pre := scm.PullRequestHook{
+				Action:      tc.action,
+				Repo:        scm.Repository{Namespace: "org", Name: "repo"},
+				PullRequest: scm.PullRequest{Number: 1},
+			}
+			log := logrus.WithField("plugin", pluginName)
+			cp := commentpruner.NewEventClient(fc, log, "org", "repo", 1)
+
+			runChecks := deferChecks()
+			assert.NoError(t, handlePR(fc, log, cp, pre))
+			if tc.knownLater {
+				delete(fc.PullRequestMergeability, 1)
+			}
+			assert.Equal(t, tc.expectRechecks, runChecks(), "deferred checks")
+
+			if tc.expectAdded {
+				assert.Equal(t, []string{"org/repo#1:" + labels.NeedsRebase}, fc.PullRequestLabelsAdded)
+			} else {
+				assert.Empty(t, fc.PullRequestLabelsAdded)
+			}
+			if tc.expectRemoved {
+				assert.Equal(t, []string{"org/repo#1:" + labels.NeedsRebase}, fc.PullRequestLabelsRemoved)
+				assert.Equal(t, []string{"org/repo#42"}, fc.PullRequestCommentsDeleted)
+			} else {
+				assert.Empty(t, fc.PullRequestLabelsRemoved)
+				assert.Empty(t, fc.PullRequestCommentsDeleted)
+			}
+			if tc.expectComment {
+				assert.Equal(t, []string{"org/repo#1:" + message}, fc.PullRequestCommentsAdded)
+			} else {
+				assert.Empty(t, fc.PullRequestCommentsAdded)
+			}
+		})
+	}
+}
+
+func TestHandlePushEvent(t *testing.T) {
+	closed := testPR(4, "master", false)
+	closed.Closed = true
+	fc := newFakeClient(
+		testPR(1, "master", false),
+		testPR(2, "master", true),
+		testPR(3, "release", false),
+		closed,
+	)
+	fc.PullRequestLabelsExisting = []string{"org/repo#2:" + labels.NeedsRebase}
+	// the mergeability of PR 5 is still being computed after the push
+	fc.PullRequests[5] = testPR(5, "master", false)
+	fc.PullRequestMergeability = map[int]gitprovider.Mergeability{5: gitprovider.MergeabilityUnknown}
+	runChecks := deferChecks()
+	defer func() { afterFunc = time.AfterFunc }()
+
+	pe := scm.PushHook{
+		Ref:  "refs/heads/master",
+		Repo: scm.Repository{Namespace: "org", Name: "repo"},
+	}
+	assert.NoError(t, handlePushEvent(fc, logrus.WithField("plugin", pluginName), pe))
+	// the event does not wait for the mergeability of PR 5, which is checked later
+	assert.Equal(t, recheckAttempts, runChecks())
+
+	assert.Equal(t, []string{"org/repo#1:" + labels.NeedsRebase}, fc.PullRequestLabelsAdded)
+	assert.Equal(t, []string{"org/repo#2:" + labels.NeedsRebase}, fc.PullRequestLabelsRemoved)
+	assert.Len(t, fc.PullRequestCommentsAdded, 1)
+}
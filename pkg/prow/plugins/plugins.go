@@ -35,6 +35,7 @@ import (
 	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
 	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
 	"github.com/jenkins-x/lighthouse/pkg/prow/repoowners"
+	"github.com/jenkins-x/lighthouse/pkg/prow/slack"
 	"github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/yaml"
@@ -141,9 +142,7 @@ type Agent struct {
 	MetapipelineClient metapipeline.Client
 	GitClient          git2.Client
 	KubernetesClient   kubernetes.Interface
-	/*
-		SlackClient      *slack.Client
-	*/
+	SlackClient        *slack.Client
 
 	OwnersClient *repoowners.Client
 
@@ -170,9 +169,7 @@ func NewAgent(clientFactory jxfactory.Factory, configAgent *config.Agent, plugin
 		GitClient:          clientAgent.GitClient,
 		PlumberClient:      clientAgent.PlumberClient,
 		MetapipelineClient: metapipelineClient,
-		/*
-			SlackClient:   clientAgent.SlackClient,
-		*/
+		SlackClient:        clientAgent.SlackClient,
 		OwnersClient: repoowners.NewClient(
 			clientAgent.GitClient, gitHubClient,
 			prowConfig, pluginConfig.MDYAMLEnabled,
@@ -211,9 +208,7 @@ type ClientAgent struct {
 	GitClient          git2.Client
 	PlumberClient      plumber.Plumber
 	MetapipelineClient metapipeline.Client
-
-	/*	SlackClient      *slack.Client
-	 */
+	SlackClient        *slack.Client
 }
 
 // ConfigAgent contains the agent mutex and the Agent configuration.
@@ -0,0 +1,232 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package slackevents contains a plugin which relays comments mentioning
+// configured channels to Slack and warns on Slack about manual pushes to
+// protected branches.
+package slackevents
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+const (
+	pluginName = "slackevents"
+)
+
+// mentionMatcher matches team mentions like @org/sig-testing, capturing the team.
+var mentionMatcher = regexp.MustCompile(`(?m)@[\w-]+/([\w-]+)`)
+
+type slackClient interface {
+	WriteMessage(text string, channel string) error
+}
+
+type githubClient interface {
+	BotName() (string, error)
+	Search(opts scm.SearchOptions) ([]*scm.SearchIssue, *gitprovider.RateLimits, error)
+}
+
+type client struct {
+	GitHubClient githubClient
+	SlackClient  slackClient
+	SlackConfig  plugins.Slack
+	Config       *config.Config
+}
+
+func init() {
+	plugins.RegisterPushEventHandler(pluginName, handlePush, helpProvider)
+	plugins.RegisterGenericCommentHandler(pluginName, handleComment, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+	configInfo := map[string]string{
+		"": fmt.Sprintf("Team mentions on the git provider are reiterated for the following Slack channels: %s.", strings.Join(config.Slack.MentionChannels, ", ")),
+	}
+	for _, repo := range enabledRepos {
+		parts := strings.Split(repo, "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid repo in enabledRepos: %q", repo)
+		}
+		if mw := getMergeWarning(config.Slack.MergeWarnings, parts[0], parts[1]); mw != nil {
+			configInfo[repo] = fmt.Sprintf("In this repo pushes to protected branches which do not come from a merged pull request are considered manual and trigger manual merge warnings if the user who pushed is not a member of this universal whitelist: %s or pushed to a branch they are not specifically whitelisted for: %#v.<br>Warnings are sent to the following Slack channels: %s.", strings.Join(mw.WhiteList, ", "), mw.BranchWhiteList, strings.Join(mw.Channels, ", "))
+		} else {
+			configInfo[repo] = "There are no manual merge warnings configured for this repo."
+		}
+	}
+	return &pluginhelp.PluginHelp{
+			Description: "The slackevents plugin reacts to various git provider events by commenting in Slack channels.\n<ol><li>The plugin can create comments to alert on manual merges. Manual merges are pushes to a protected branch made by a normal user instead of the merge of a pull request by a bot or trusted user.</li><li>The plugin can create comments to reiterate team mentions like '@org/sig-testing' from the git provider.</li></ol>",
+			Config:      configInfo,
+		},
+		nil
+}
+
+func handleComment(pc plugins.Agent, e gitprovider.GenericCommentEvent) error {
+	if pc.SlackClient == nil {
+		pc.Logger.Debug("no Slack client configured")
+		return nil
+	}
+	c := client{
+		GitHubClient: pc.GitHubClient,
+		SlackConfig:  pc.PluginConfig.Slack,
+		SlackClient:  pc.SlackClient,
+		Config:       pc.Config,
+	}
+	return echoToSlack(c, e)
+}
+
+func handlePush(pc plugins.Agent, pe scm.PushHook) error {
+	if pc.SlackClient == nil {
+		pc.Logger.Debug("no Slack client configured")
+		return nil
+	}
+	c := client{
+		GitHubClient: pc.GitHubClient,
+		SlackConfig:  pc.PluginConfig.Slack,
+		SlackClient:  pc.SlackClient,
+		Config:       pc.Config,
+	}
+	return notifyOnSlackIfManualMerge(c, pe)
+}
+
+func notifyOnSlackIfManualMerge(pc client, pe scm.PushHook) error {
+	if pe.Deleted || pe.After == "" {
+		return nil
+	}
+	org := pe.Repo.Namespace
+	repo := pe.Repo.Name
+	branch := strings.TrimPrefix(pe.Ref, "refs/heads/")
+
+	// Fetch MergeWarning for the repo we received the push event.
+	mw := getMergeWarning(pc.SlackConfig.MergeWarnings, org, repo)
+	if mw == nil {
+		return nil
+	}
+	// If the MergeWarning whitelist has the pushing user then no need to send a message.
+	if isWhiteListed(mw, branch, pe) {
+		return nil
+	}
+	if !isProtectedBranch(pc.Config, org, repo, branch) {
+		return nil
+	}
+	merged, err := isFromMergedPR(pc.GitHubClient, org, repo, pe.After)
+	if err != nil {
+		return fmt.Errorf("failed to find pull requests for %s/%s@%s: %v", org, repo, pe.After, err)
+	}
+	if merged {
+		return nil
+	}
+
+	message := fmt.Sprintf("*Warning:* %s (<@%s>) manually pushed to %s/%s:%s without merging a pull request %s", pe.Sender.Login, pe.Sender.Login, org, repo, branch, pe.Compare)
+	for _, channel := range mw.Channels {
+		if err := pc.SlackClient.WriteMessage(message, channel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isFromMergedPR tells whether the commit belongs to a merged pull request, which is the
+// case when a pull request was merged rather than commits being pushed directly.
+func isFromMergedPR(ghc githubClient, org, repo, sha string) (bool, error) {
+	query := fmt.Sprintf("%s repo:%s/%s is:pr is:merged", sha, org, repo)
+	results, _, err := ghc.Search(scm.SearchOptions{Query: query})
+	if err != nil {
+		return false, err
+	}
+	return len(results) > 0, nil
+}
+
+// isProtectedBranch tells whether merge warnings apply to the branch. When branch
+// protection is configured for the org only protected branches are watched,
+// otherwise every branch of the repositories listed in the merge warning is.
+func isProtectedBranch(cfg *config.Config, org, repo, branch string) bool {
+	if cfg == nil {
+		return true
+	}
+	if _, ok := cfg.BranchProtection.Orgs[org]; !ok {
+		return true
+	}
+	policy, err := cfg.GetBranchProtection(org, repo, branch)
+	if err != nil {
+		return true
+	}
+	return policy != nil && policy.Protect != nil && *policy.Protect
+}
+
+func isWhiteListed(mw *plugins.MergeWarning, branch string, pe scm.PushHook) bool {
+	bwl := mw.BranchWhiteList[branch]
+	inWhiteList := stringInArray(pe.Sender.Login, mw.WhiteList)
+	inBranchWhiteList := stringInArray(pe.Sender.Login, bwl)
+	return inWhiteList || inBranchWhiteList
+}
+
+func getMergeWarning(mergeWarnings []plugins.MergeWarning, org, repo string) *plugins.MergeWarning {
+	fullNamePath := fmt.Sprintf("%s/%s", org, repo)
+	for _, mw := range mergeWarnings {
+		if !stringInArray(fullNamePath, mw.Repos) && !stringInArray(org, mw.Repos) {
+			continue
+		}
+		return &mw
+	}
+	return nil
+}
+
+func stringInArray(str string, list []string) bool {
+	for _, v := range list {
+		if v == str {
+			return true
+		}
+	}
+	return false
+}
+
+func echoToSlack(pc client, e gitprovider.GenericCommentEvent) error {
+	// Ignore bot comments and comments that aren't new.
+	botName, err := pc.GitHubClient.BotName()
+	if err != nil {
+		return err
+	}
+	if e.Author.Login == botName {
+		return nil
+	}
+	if e.Action != scm.ActionCreate {
+		return nil
+	}
+
+	notified := map[string]bool{}
+	for _, match := range mentionMatcher.FindAllStringSubmatch(e.Body, -1) {
+		channel := match[1]
+		// Check if this team is a slack channel that should be notified.
+		if notified[channel] || !stringInArray(channel, pc.SlackConfig.MentionChannels) {
+			continue
+		}
+		notified[channel] = true
+
+		msg := fmt.Sprintf("%s was mentioned by %s (<@%s>) on %s. (%s)\n>>>%s", channel, e.Author.Login, e.Author.Login, e.Repo.FullName, e.Link, e.Body)
+		if err := pc.SlackClient.WriteMessage(msg, channel); err != nil {
+			return fmt.Errorf("failed to send message on slack channel: %q with message %q. Err: %v", channel, msg, err)
+		}
+	}
+	return nil
+}
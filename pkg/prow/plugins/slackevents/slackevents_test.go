@@ -0,0 +1,289 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slackevents
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/prow/slack"
+)
+
+type fakeGitHubClient struct {
+	mergedSHAs []string
+	queries    []string
+}
+
+func (f *fakeGitHubClient) BotName() (string, error) {
+	return "k8s-ci-robot", nil
+}
+
+func (f *fakeGitHubClient) Search(opts scm.SearchOptions) ([]*scm.SearchIssue, *gitprovider.RateLimits, error) {
+	f.queries = append(f.queries, opts.Query)
+	for _, sha := range f.mergedSHAs {
+		if strings.HasPrefix(opts.Query, sha+" ") {
+			return []*scm.SearchIssue{{}}, &gitprovider.RateLimits{}, nil
+		}
+	}
+	return nil, &gitprovider.RateLimits{}, nil
+}
+
+type fakeSlackClient struct {
+	messages map[string]string
+}
+
+func (fk *fakeSlackClient) WriteMessage(text string, channel string) error {
+	fk.messages[channel] = text
+	return nil
+}
+
+// slackStandIn is a local HTTP stand-in for the Slack chat.postMessage API.
+type slackStandIn struct {
+	sync.Mutex
+	messages map[string]string
+}
+
+func (s *slackStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.messages[r.Form.Get("channel")] = r.Form.Get("text")
+	fmt.Fprint(w, `{"ok": true}`)
+}
+
+func TestPush(t *testing.T) {
+	var noMessages = map[string]string{}
+	var stdWarningMessage = "*Warning:* someone (<@someone>) manually pushed to kubernetes/kubernetes:master without merging a pull request https://example.com/compare"
+
+	pushEv := scm.PushHook{
+		Ref:     "refs/heads/master",
+		After:   "abc123",
+		Compare: "https://example.com/compare",
+		Sender: scm.User{
+			Login: "someone",
+		},
+		Repo: scm.Repository{
+			Namespace: "kubernetes",
+			Name:      "kubernetes",
+			FullName:  "kubernetes/kubernetes",
+		},
+	}
+
+	pushEvManualBranchWhiteListed := pushEv
+	pushEvManualBranchWhiteListed.Ref = "refs/heads/warningbranch"
+
+	pushEvManualNotBranchWhiteListed := pushEv
+	pushEvManualNotBranchWhiteListed.Ref = "refs/heads/warningbranch"
+	pushEvManualNotBranchWhiteListed.Sender.Login = "someone-else"
+
+	pushEvTrustedUser := pushEv
+	pushEvTrustedUser.Sender.Login = "k8s-merge-robot"
+
+	pushEvOtherRepo := pushEv
+	pushEvOtherRepo.Repo.Namespace = "kubernetes-sigs"
+	pushEvOtherRepo.Repo.Name = "other"
+
+	pushEvDeleted := pushEv
+	pushEvDeleted.Deleted = true
+
+	testCases := []struct {
+		name       string
+		pushReq    scm.PushHook
+		mergedSHAs []string
+		expected   map[string]string
+	}{
+		{
+			name:    "If the push is manual and not whitelisted a warning is sent to all channels",
+			pushReq: pushEv,
+			expected: map[string]string{
+				"sig-contribex":  stdWarningMessage,
+				"kubernetes-dev": stdWarningMessage,
+			},
+		},
+		{
+			name:       "If the pushed commit belongs to a merged PR no warning is sent",
+			pushReq:    pushEv,
+			mergedSHAs: []string{"abc123"},
+			expected:   noMessages,
+		},
+		{
+			name:     "If the user is on the whitelist no warning is sent",
+			pushReq:  pushEvTrustedUser,
+			expected: noMessages,
+		},
+		{
+			name:     "If the user is on the branch whitelist no warning is sent",
+			pushReq:  pushEvManualBranchWhiteListed,
+			expected: noMessages,
+		},
+		{
+			name:    "If the user is not on the branch whitelist a warning is sent",
+			pushReq: pushEvManualNotBranchWhiteListed,
+			expected: map[string]string{
+				"sig-contribex":  strings.Replace(strings.Replace(stdWarningMessage, "someone", "someone-else", 2), "master", "warningbranch", 1),
+				"kubernetes-dev": strings.Replace(strings.Replace(stdWarningMessage, "someone", "someone-else", 2), "master", "warningbranch", 1),
+			},
+		},
+		{
+			name:     "If the repo has no merge warning configured no warning is sent",
+			pushReq:  pushEvOtherRepo,
+			expected: noMessages,
+		},
+		{
+			name:     "If the branch was deleted no warning is sent",
+			pushReq:  pushEvDeleted,
+			expected: noMessages,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			standIn := &slackStandIn{messages: map[string]string{}}
+			server := httptest.NewServer(standIn)
+			defer server.Close()
+
+			c := client{
+				GitHubClient: &fakeGitHubClient{mergedSHAs: tc.mergedSHAs},
+				SlackClient:  slack.NewClientWithBaseURL("token", server.URL),
+				SlackConfig: plugins.Slack{
+					MergeWarnings: []plugins.MergeWarning{
+						{
+							Repos:     []string{"kubernetes/kubernetes"},
+							Channels:  []string{"kubernetes-dev", "sig-contribex"},
+							WhiteList: []string{"k8s-merge-robot"},
+							BranchWhiteList: map[string][]string{
+								"warningbranch": {"someone"},
+							},
+						},
+					},
+				},
+			}
+
+			if err := notifyOnSlackIfManualMerge(c, tc.pushReq); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(standIn.messages, tc.expected) {
+				t.Errorf("expected messages %v, got %v", tc.expected, standIn.messages)
+			}
+		})
+	}
+}
+
+func TestComment(t *testing.T) {
+	orig := gitprovider.GenericCommentEvent{
+		Action: scm.ActionCreate,
+		Author: scm.User{
+			Login: "jdoe",
+		},
+		Link: "https://example.com/issue/1#comment",
+		Repo: scm.Repository{
+			FullName: "kubernetes/kubernetes",
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		action   scm.Action
+		author   string
+		body     string
+		expected []string
+	}{
+		{
+			name:     "No mentions",
+			action:   scm.ActionCreate,
+			body:     "Nothing to see here",
+			expected: []string{},
+		},
+		{
+			name:     "Mention of a configured channel",
+			action:   scm.ActionCreate,
+			body:     "cc @kubernetes/sig-node",
+			expected: []string{"sig-node"},
+		},
+		{
+			name:     "Mentions of a configured and unconfigured channel",
+			action:   scm.ActionCreate,
+			body:     "cc @kubernetes/sig-node and @kubernetes/sig-unknown",
+			expected: []string{"sig-node"},
+		},
+		{
+			name:     "Repeated mentions of channels",
+			action:   scm.ActionCreate,
+			body:     "cc @kubernetes/sig-node @kubernetes/sig-api-machinery @kubernetes/sig-node",
+			expected: []string{"sig-api-machinery", "sig-node"},
+		},
+		{
+			name:     "Edited comments are ignored",
+			action:   scm.ActionEdit,
+			body:     "cc @kubernetes/sig-node",
+			expected: []string{},
+		},
+		{
+			name:     "Comments by the bot are ignored",
+			action:   scm.ActionCreate,
+			author:   "k8s-ci-robot",
+			body:     "cc @kubernetes/sig-node",
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := orig
+			e.Action = tc.action
+			e.Body = tc.body
+			if tc.author != "" {
+				e.Author.Login = tc.author
+			}
+			fakeSlackClient := &fakeSlackClient{
+				messages: make(map[string]string),
+			}
+			c := client{
+				GitHubClient: &fakeGitHubClient{},
+				SlackClient:  fakeSlackClient,
+				SlackConfig:  plugins.Slack{MentionChannels: []string{"sig-node", "sig-api-machinery"}},
+			}
+
+			if err := echoToSlack(c, e); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tc.expected) != len(fakeSlackClient.messages) {
+				t.Fatalf("expected messages for %v, got %v", tc.expected, fakeSlackClient.messages)
+			}
+			for _, channel := range tc.expected {
+				msg, ok := fakeSlackClient.messages[channel]
+				if !ok {
+					t.Errorf("expected a message for channel %s", channel)
+				}
+				if !strings.Contains(msg, e.Link) || !strings.Contains(msg, tc.body) {
+					t.Errorf("message %q should contain the comment link and body", msg)
+				}
+			}
+		})
+	}
+}
@@ -35,6 +35,9 @@ func handleGenericComment(c Client, trigger *plugins.Trigger, gc gitprovider.Gen
 	repo := gc.Repo.Name
 	number := gc.Number
 	commentAuthor := gc.Author.Login
+	if err := handleRerun(c, gc); err != nil {
+		return err
+	}
 	// Only take action when a comment is first created,
 	// when it belongs to a PR,
 	// and the PR is open.
@@ -0,0 +1,174 @@
+package trigger
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/pjutil"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+var (
+	rerunPostsubmitRe = regexp.MustCompile(`(?m)^/rerun-postsubmit\s+(\S+)\s*$`)
+	rerunPeriodicRe   = regexp.MustCompile(`(?m)^/rerun-periodic\s+(\S+)\s*$`)
+)
+
+type permissionClient interface {
+	HasPermission(org, repo, user string, roles ...string) (bool, error)
+}
+
+// CanRerun returns true if the user has write permission on the repository and so
+// may rerun its postsubmits and periodics.
+func CanRerun(ghc permissionClient, org, repo, user string) (bool, error) {
+	return ghc.HasPermission(org, repo, user, gitprovider.RoleAdmin, gitprovider.RoleWrite)
+}
+
+// RerunPostsubmit starts the postsubmit job with the given name against a commit of a branch.
+func RerunPostsubmit(c Client, repository scm.Repository, branch, sha, jobName, eventGUID string) error {
+	var job *config.Postsubmit
+	for _, p := range c.Config.GetPostsubmits(repository) {
+		if p.Name == jobName {
+			job = &p
+			break
+		}
+	}
+	if job == nil {
+		return fmt.Errorf("no postsubmit named %q for %s/%s", jobName, repository.Namespace, repository.Name)
+	}
+	if !job.Brancher.ShouldRun(branch) {
+		return fmt.Errorf("postsubmit %q does not run against branch %s", jobName, branch)
+	}
+	refs := plumber.Refs{
+		Org:     repository.Namespace,
+		Repo:    repository.Name,
+		BaseRef: branch,
+		BaseSHA: sha,
+	}
+	return createRerun(c, pjutil.PostsubmitSpec(*job, refs), job.JobBase, repository, eventGUID)
+}
+
+// RerunPeriodic starts the periodic job with the given name against the head of a branch
+// of the repository. Only the periodics which clone the repository can be rerun from it.
+func RerunPeriodic(c Client, repository scm.Repository, branch, jobName, eventGUID string) error {
+	var job *config.Periodic
+	for _, p := range c.Config.AllPeriodics() {
+		if p.Name == jobName && PeriodicClones(p, repository) {
+			job = &p
+			break
+		}
+	}
+	if job == nil {
+		return fmt.Errorf("no periodic named %q for %s/%s", jobName, repository.Namespace, repository.Name)
+	}
+	sha, err := c.GitHubClient.GetRef(repository.Namespace, repository.Name, "heads/"+branch)
+	if err != nil {
+		return fmt.Errorf("failed to get the head of %s/%s branch %s: %v", repository.Namespace, repository.Name, branch, err)
+	}
+	spec := pjutil.PeriodicSpec(*job)
+	spec.Refs = &plumber.Refs{
+		Org:     repository.Namespace,
+		Repo:    repository.Name,
+		BaseRef: branch,
+		BaseSHA: sha,
+	}
+	return createRerun(c, spec, job.JobBase, repository, eventGUID)
+}
+
+// PeriodicClones returns true if the extra refs of the periodic include the repository.
+func PeriodicClones(p config.Periodic, repository scm.Repository) bool {
+	for _, ref := range p.ExtraRefs {
+		if ref.Org == repository.Namespace && ref.Repo == repository.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func createRerun(c Client, spec plumber.PipelineOptionsSpec, jb config.JobBase, repository scm.Repository, eventGUID string) error {
+	labels := make(map[string]string)
+	for k, v := range jb.Labels {
+		labels[k] = v
+	}
+	labels[gitprovider.EventGUID] = eventGUID
+	pj := pjutil.NewPlumberJob(spec, labels, jb.Annotations)
+	c.Logger.WithFields(pjutil.PlumberJobFields(&pj)).Info("Creating a new plumberJob for a rerun.")
+	_, err := c.PlumberClient.Create(&pj, c.MetapipelineClient, repository)
+	return err
+}
+
+// handleRerun handles the /rerun-postsubmit and /rerun-periodic commands. Postsubmits are
+// rerun against the merge commit of a merged PR and periodics against the head of its base branch.
+func handleRerun(c Client, gc gitprovider.GenericCommentEvent) error {
+	postsubmits := rerunPostsubmitRe.FindAllStringSubmatch(gc.Body, -1)
+	periodics := rerunPeriodicRe.FindAllStringSubmatch(gc.Body, -1)
+	if gc.Action != scm.ActionCreate || !gc.IsPR || (len(postsubmits) == 0 && len(periodics) == 0) {
+		return nil
+	}
+	org := gc.Repo.Namespace
+	repo := gc.Repo.Name
+	number := gc.Number
+	commentAuthor := gc.Author.Login
+
+	botName, err := c.GitHubClient.BotName()
+	if err != nil {
+		return err
+	}
+	if commentAuthor == botName {
+		return nil
+	}
+	respond := func(msg string) error {
+		c.Logger.Infof("Commenting \"%s\".", msg)
+		return c.GitHubClient.CreateComment(org, repo, number, true, plugins.FormatResponseRaw(gc.Body, gc.Link, commentAuthor, msg))
+	}
+
+	allowed, err := CanRerun(c.GitHubClient, org, repo, commentAuthor)
+	if err != nil {
+		return fmt.Errorf("error checking the permission of %s: %v", commentAuthor, err)
+	}
+	if !allowed {
+		return respond("Only users with write permission on the repository can rerun postsubmit and periodic jobs.")
+	}
+
+	pr, err := c.GitHubClient.GetPullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+	repository := pr.Repository()
+	branch := pr.Base.Ref
+
+	var started, failed []string
+	if len(postsubmits) > 0 && (!pr.Merged || pr.MergeSha == "") {
+		failed = append(failed, "postsubmits can only be rerun on merged pull requests")
+		postsubmits = nil
+	}
+	for _, match := range postsubmits {
+		if err := RerunPostsubmit(c, repository, branch, pr.MergeSha, match[1], gc.GUID); err != nil {
+			c.Logger.WithError(err).Errorf("Failed to rerun postsubmit %s.", match[1])
+			failed = append(failed, err.Error())
+			continue
+		}
+		started = append(started, fmt.Sprintf("postsubmit `%s` at %s", match[1], pr.MergeSha))
+	}
+	for _, match := range periodics {
+		if err := RerunPeriodic(c, repository, branch, match[1], gc.GUID); err != nil {
+			c.Logger.WithError(err).Errorf("Failed to rerun periodic %s.", match[1])
+			failed = append(failed, err.Error())
+			continue
+		}
+		started = append(started, fmt.Sprintf("periodic `%s` on %s", match[1], branch))
+	}
+
+	var lines []string
+	for _, s := range started {
+		lines = append(lines, "Started "+s+".")
+	}
+	for _, f := range failed {
+		lines = append(lines, "Could not rerun: "+f+".")
+	}
+	return respond(strings.Join(lines, "\n"))
+}
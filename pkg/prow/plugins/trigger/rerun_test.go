@@ -0,0 +1,161 @@
+package trigger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/jenkins-x/lighthouse/pkg/plumber/fake"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/fakegitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleRerun(t *testing.T) {
+	testCases := []struct {
+		name     string
+		author   string
+		body     string
+		merged   bool
+		expected []string
+		comment  string
+	}{
+		{
+			name:     "rerun postsubmit on merged PR",
+			author:   "writer",
+			body:     "/rerun-postsubmit release",
+			merged:   true,
+			expected: []string{"postsubmit:release@mergesha"},
+			comment:  "Started postsubmit `release` at mergesha.",
+		},
+		{
+			name:    "rerun postsubmit on unmerged PR",
+			author:  "writer",
+			body:    "/rerun-postsubmit release",
+			comment: "Could not rerun: postsubmits can only be rerun on merged pull requests.",
+		},
+		{
+			name:    "rerun unknown postsubmit",
+			author:  "writer",
+			body:    "/rerun-postsubmit nope",
+			merged:  true,
+			comment: "Could not rerun: no postsubmit named \"nope\" for org/repo.",
+		},
+		{
+			name:     "rerun periodic",
+			author:   "admin",
+			body:     "/rerun-periodic nightly",
+			expected: []string{"periodic:nightly@" + fakegitprovider.TestRef},
+			comment:  "Started periodic `nightly` on master.",
+		},
+		{
+			name:    "rerun periodic of another repository",
+			author:  "admin",
+			body:    "/rerun-periodic other-nightly",
+			comment: "Could not rerun: no periodic named \"other-nightly\" for org/repo.",
+		},
+		{
+			name:    "rerun periodic without refs",
+			author:  "admin",
+			body:    "/rerun-periodic cleanup",
+			comment: "Could not rerun: no periodic named \"cleanup\" for org/repo.",
+		},
+		{
+			name:    "user without write permission",
+			author:  "reader",
+			body:    "/rerun-periodic nightly",
+			comment: "Only users with write permission on the repository can rerun postsubmit and periodic jobs.",
+		},
+		{
+			name:   "other comment",
+			author: "writer",
+			body:   "/rerun-postsubmit",
+			merged: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repository := scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo"}
+			g := &fakegitprovider.FakeClient{
+				UserPermissions: map[string]string{"writer": "write", "admin": "admin", "reader": "read"},
+				PullRequests: map[int]*scm.PullRequest{
+					1: {
+						Number:   1,
+						Merged:   tc.merged,
+						MergeSha: "mergesha",
+						Base:     scm.PullRequestBranch{Ref: "master", Repo: repository},
+					},
+				},
+				IssueComments:       map[int][]*scm.Comment{},
+				PullRequestComments: map[int][]*scm.Comment{},
+			}
+			fakePlumberClient := fake.NewPlumber()
+			c := Client{
+				GitHubClient:  g,
+				PlumberClient: fakePlumberClient,
+				Config:        &config.Config{},
+				Logger:        logrus.WithField("plugin", PluginName),
+			}
+			require.NoError(t, c.Config.SetPostsubmits(map[string][]config.Postsubmit{
+				"org/repo": {{JobBase: config.JobBase{Name: "release"}}},
+			}))
+			c.Config.Periodics = []config.Periodic{
+				{JobBase: config.JobBase{Name: "nightly", UtilityConfig: config.UtilityConfig{ExtraRefs: []plumber.Refs{{Org: "org", Repo: "repo"}}}}},
+				{JobBase: config.JobBase{Name: "other-nightly", UtilityConfig: config.UtilityConfig{ExtraRefs: []plumber.Refs{{Org: "other", Repo: "repo"}}}}},
+				{JobBase: config.JobBase{Name: "cleanup"}},
+			}
+
+			gc := gitprovider.GenericCommentEvent{
+				GUID:   "guid",
+				Action: scm.ActionCreate,
+				IsPR:   true,
+				Number: 1,
+				Body:   tc.body,
+				Repo:   repository,
+				Author: scm.User{Login: tc.author},
+			}
+			require.NoError(t, handleRerun(c, gc))
+
+			var started []string
+			for _, pj := range fakePlumberClient.Pipelines {
+				started = append(started, string(pj.Spec.Type)+":"+pj.Spec.Job+"@"+pj.Spec.Refs.BaseSHA)
+				assert.Equal(t, "guid", pj.Labels[gitprovider.EventGUID])
+			}
+			if len(tc.expected) > 0 {
+				assert.Equal(t, tc.expected, started)
+			} else {
+				assert.Empty(t, started)
+			}
+			if tc.comment == "" {
+				assert.Empty(t, g.PullRequestCommentsAdded)
+				return
+			}
+			require.Len(t, g.PullRequestCommentsAdded, 1)
+			assert.True(t, strings.Contains(g.PullRequestCommentsAdded[0], tc.comment), g.PullRequestCommentsAdded[0])
+		})
+	}
+}
+
+func TestRerunPostsubmitBranches(t *testing.T) {
+	fakePlumberClient := fake.NewPlumber()
+	c := Client{
+		GitHubClient:  &fakegitprovider.FakeClient{},
+		PlumberClient: fakePlumberClient,
+		Config:        &config.Config{},
+		Logger:        logrus.WithField("plugin", PluginName),
+	}
+	require.NoError(t, c.Config.SetPostsubmits(map[string][]config.Postsubmit{
+		"org/repo": {{JobBase: config.JobBase{Name: "release"}, Brancher: config.Brancher{Branches: []string{"master"}}}},
+	}))
+	repository := scm.Repository{Namespace: "org", Name: "repo", FullName: "org/repo"}
+
+	assert.Error(t, RerunPostsubmit(c, repository, "feature", "abc", "release", "guid"))
+	require.NoError(t, RerunPostsubmit(c, repository, "master", "abc", "release", "guid"))
+	require.Len(t, fakePlumberClient.Pipelines, 1)
+	assert.Equal(t, plumber.PostsubmitJob, fakePlumberClient.Pipelines[0].Spec.Type)
+	assert.Equal(t, "master", fakePlumberClient.Pipelines[0].Spec.Refs.BaseRef)
+}
@@ -66,7 +66,8 @@ func helpProvider(config *plugins.Configuration, enabledRepos []string) (*plugin
 	pluginHelp := &pluginhelp.PluginHelp{
 		Description: `The trigger plugin starts tests in reaction to commands and pull request events. It is responsible for ensuring that test jobs are only run on trusted PRs. A PR is considered trusted if the author is a member of the 'trusted organization' for the repository or if such a member has left an '/ok-to-test' command on the PR.
 <br>Trigger starts jobs automatically when a new trusted PR is created or when an untrusted PR becomes trusted, but it can also be used to start jobs manually via the '/test' command.
-<br>The '/retest' command can be used to rerun jobs that have reported failure.`,
+<br>The '/retest' command can be used to rerun jobs that have reported failure.
+<br>The '/rerun-postsubmit' and '/rerun-periodic' commands can be used on a merged PR to rerun a postsubmit against its merge commit or a periodic against the head of its base branch.`,
 		Config: configInfo,
 	}
 	pluginHelp.AddCommand(pluginhelp.Command{
@@ -90,6 +91,20 @@ func helpProvider(config *plugins.Configuration, enabledRepos []string) (*plugin
 		WhoCanUse:   "Anyone can trigger this command on a trusted PR.",
 		Examples:    []string{"/retest"},
 	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/rerun-postsubmit <job name>",
+		Description: "Reruns a postsubmit job against the merge commit of a merged PR.",
+		Featured:    false,
+		WhoCanUse:   "Users with write permission on the repository.",
+		Examples:    []string{"/rerun-postsubmit release"},
+	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/rerun-periodic <job name>",
+		Description: "Reruns a periodic job which clones the repository against the head of the base branch of the PR.",
+		Featured:    false,
+		WhoCanUse:   "Users with write permission on the repository.",
+		Examples:    []string{"/rerun-periodic nightly"},
+	})
 	return pluginHelp, nil
 }
 
@@ -98,6 +113,7 @@ type githubClient interface {
 	BotName() (string, error)
 	IsCollaborator(org, repo, user string) (bool, error)
 	IsMember(org, user string) (bool, error)
+	HasPermission(org, repo, user string, roles ...string) (bool, error)
 	GetPullRequest(org, repo string, number int) (*scm.PullRequest, error)
 	GetRef(org, repo, ref string) (string, error)
 	CreateComment(owner, repo string, number int, pr bool, comment string) error
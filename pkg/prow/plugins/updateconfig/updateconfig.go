@@ -0,0 +1,312 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package updateconfig contains a plugin which keeps ConfigMaps in sync with
+// configuration files stored in a repository.
+package updateconfig
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+const (
+	pluginName = "config-updater"
+)
+
+func init() {
+	plugins.RegisterPullRequestHandler(pluginName, handlePullRequest, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+	var files []string
+	for file, spec := range config.ConfigUpdater.Maps {
+		files = append(files, fmt.Sprintf("'%s' is kept in sync with the `%s` configmap", file, spec.Name))
+	}
+	sort.Strings(files)
+	configInfo := map[string]string{}
+	for _, repo := range enabledRepos {
+		configInfo[repo] = strings.Join(files, ".<br>")
+	}
+	return &pluginhelp.PluginHelp{
+			Description: "The config-updater plugin automatically redeploys configuration and plugin configuration files when they change. The plugin watches for pull request merges that modify any of the configured files and updates the cluster's configmap resources in response.",
+			Config:      configInfo,
+		},
+		nil
+}
+
+type githubClient interface {
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+	GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error)
+	GetFile(org, repo, filepath, commit string) ([]byte, error)
+}
+
+func handlePullRequest(pc plugins.Agent, pre scm.PullRequestHook) error {
+	return handle(pc.GitHubClient, pc.KubernetesClient.CoreV1(), pc.Config.PlumberJobNamespace, pc.Logger, pre, pc.PluginConfig.ConfigUpdater.Maps)
+}
+
+// FileGetter knows how to get the contents of a file by name
+type FileGetter interface {
+	GetFile(filename string) ([]byte, error)
+}
+
+type gitProviderFileGetter struct {
+	org, repo, commit string
+	client            githubClient
+}
+
+func (g *gitProviderFileGetter) GetFile(filename string) ([]byte, error) {
+	return g.client.GetFile(g.org, g.repo, filename, g.commit)
+}
+
+// ConfigMapID is the name and namespace of a ConfigMap to update
+type ConfigMapID struct {
+	Name, Namespace string
+}
+
+// ConfigMapUpdate is populated with information about a config map that should
+// be updated.
+type ConfigMapUpdate struct {
+	Key, Filename string
+}
+
+// Update updates the configmap with the data from the identified files
+func Update(fg FileGetter, kc corev1.ConfigMapInterface, name, namespace string, updates []ConfigMapUpdate, logger *logrus.Entry) error {
+	cm, getErr := kc.Get(name, metav1.GetOptions{})
+	isNotFound := errors.IsNotFound(getErr)
+	if getErr != nil && !isNotFound {
+		return fmt.Errorf("failed to fetch current state of configmap: %v", getErr)
+	}
+
+	if cm == nil || isNotFound {
+		cm = &coreapi.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+		}
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	if cm.BinaryData == nil {
+		cm.BinaryData = map[string][]byte{}
+	}
+
+	for _, upd := range updates {
+		if upd.Filename == "" {
+			logger.WithField("key", upd.Key).Debug("Deleting key.")
+			delete(cm.Data, upd.Key)
+			delete(cm.BinaryData, upd.Key)
+			continue
+		}
+
+		content, err := fg.GetFile(upd.Filename)
+		if err != nil {
+			return fmt.Errorf("get file err: %v", err)
+		}
+		logger.WithFields(logrus.Fields{"key": upd.Key, "filename": upd.Filename}).Debug("Populating key.")
+		if utf8.Valid(content) {
+			delete(cm.BinaryData, upd.Key)
+			cm.Data[upd.Key] = string(content)
+		} else {
+			delete(cm.Data, upd.Key)
+			cm.BinaryData[upd.Key] = content
+		}
+	}
+
+	var updateErr error
+	var verb string
+	if isNotFound {
+		verb = "create"
+		_, updateErr = kc.Create(cm)
+	} else {
+		verb = "update"
+		_, updateErr = kc.Update(cm)
+	}
+	if updateErr != nil {
+		return fmt.Errorf("%s config map err: %v", verb, updateErr)
+	}
+	return nil
+}
+
+// matchConfigMap returns the ConfigMapSpec for the first configured path or glob
+// matching the file, if any.
+func matchConfigMap(maps map[string]plugins.ConfigMapSpec, filename string, log *logrus.Entry) (plugins.ConfigMapSpec, bool) {
+	// Exact paths win over globs, which are tried in a stable order.
+	if cm, ok := maps[filename]; ok {
+		return cm, true
+	}
+	var patterns []string
+	for pattern := range maps {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		found, err := path.Match(pattern, filename)
+		if err != nil {
+			// Should not happen, log the error and continue
+			log.WithError(err).WithField("pattern", pattern).Info("Invalid glob match")
+			continue
+		}
+		if found {
+			return maps[pattern], true
+		}
+	}
+	return plugins.ConfigMapSpec{}, false
+}
+
+// FilterChanges determines which of the changes are relevant for config updating, returning mapping of
+// config map to key to filename to update that key from.
+func FilterChanges(maps map[string]plugins.ConfigMapSpec, changes []*scm.Change, log *logrus.Entry) map[ConfigMapID][]ConfigMapUpdate {
+	toUpdate := map[ConfigMapID][]ConfigMapUpdate{}
+	for _, change := range changes {
+		cm, found := matchConfigMap(maps, change.Path, log)
+		if !found {
+			continue // This file does not define a configmap
+		}
+
+		namespaces := cm.Namespaces
+		if len(namespaces) == 0 {
+			namespaces = append([]string{cm.Namespace}, cm.AdditionalNamespaces...)
+		}
+		// Yes, update the configmap with the contents of this file
+		for _, ns := range namespaces {
+			id := ConfigMapID{Name: cm.Name, Namespace: ns}
+			key := cm.Key
+			if key == "" {
+				key = path.Base(change.Path)
+			}
+			if change.Deleted {
+				// not setting the filename field will cause the key to be deleted
+				toUpdate[id] = append(toUpdate[id], ConfigMapUpdate{Key: key})
+			} else {
+				toUpdate[id] = append(toUpdate[id], ConfigMapUpdate{Key: key, Filename: change.Path})
+			}
+		}
+	}
+	return toUpdate
+}
+
+func message(cm ConfigMapID, updates []ConfigMapUpdate, indent string) string {
+	identifier := fmt.Sprintf("`%s` configmap", cm.Name)
+	if cm.Namespace != "" {
+		identifier = fmt.Sprintf("%s in namespace `%s`", identifier, cm.Namespace)
+	}
+	msg := fmt.Sprintf("%s using the following files:", identifier)
+	for _, u := range updates {
+		if u.Filename == "" {
+			msg = fmt.Sprintf("%s\n%s- key `%s` removed", msg, indent, u.Key)
+			continue
+		}
+		msg = fmt.Sprintf("%s\n%s- key `%s` using file `%s`", msg, indent, u.Key, u.Filename)
+	}
+	return msg
+}
+
+func handle(gc githubClient, kc corev1.ConfigMapsGetter, defaultNamespace string, log *logrus.Entry, pre scm.PullRequestHook, configMaps map[string]plugins.ConfigMapSpec) error {
+	// Only consider newly merged PRs
+	if pre.Action != scm.ActionClose {
+		return nil
+	}
+
+	if len(configMaps) == 0 { // Nothing to update
+		return nil
+	}
+
+	pr := pre.PullRequest
+
+	if !pr.Merged || pr.MergeSha == "" {
+		return nil
+	}
+	// Only changes merged into the default branch are deployed
+	if pr.Base.Repo.Branch != "" && pr.Base.Repo.Branch != pr.Base.Ref {
+		return nil
+	}
+
+	org := pr.Base.Repo.Namespace
+	repo := pr.Base.Repo.Name
+
+	// Which files changed in this PR?
+	changes, err := gc.GetPullRequestChanges(org, repo, pr.Number)
+	if err != nil {
+		return err
+	}
+
+	// Are any of the changes files ones that define a configmap we want to update?
+	toUpdate := FilterChanges(configMaps, changes, log)
+
+	var ids []ConfigMapID
+	for cm := range toUpdate {
+		ids = append(ids, cm)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].Name != ids[j].Name {
+			return ids[i].Name < ids[j].Name
+		}
+		return ids[i].Namespace < ids[j].Namespace
+	})
+
+	var updated []string
+	indent := " " // one space
+	if len(toUpdate) > 1 {
+		indent = "   " // three spaces for sub bullets
+	}
+	fg := &gitProviderFileGetter{org: org, repo: repo, commit: pr.MergeSha, client: gc}
+	for _, id := range ids {
+		data := toUpdate[id]
+		cm := id
+		if cm.Namespace == "" {
+			cm.Namespace = defaultNamespace
+		}
+		logger := log.WithFields(logrus.Fields{"configmap": map[string]string{"name": cm.Name, "namespace": cm.Namespace}})
+		if err := Update(fg, kc.ConfigMaps(cm.Namespace), cm.Name, cm.Namespace, data, logger); err != nil {
+			return err
+		}
+		updated = append(updated, message(cm, data, indent))
+	}
+
+	var msg string
+	switch n := len(updated); n {
+	case 0:
+		return nil
+	case 1:
+		msg = fmt.Sprintf("Updated the %s", updated[0])
+	default:
+		msg = fmt.Sprintf("Updated the following %d configmaps:\n", n)
+		for _, updateMsg := range updated {
+			msg += fmt.Sprintf(" * %s\n", updateMsg) // one space indent
+		}
+	}
+
+	if err := gc.CreateComment(org, repo, pr.Number, true, plugins.FormatResponseRaw(pr.Body, pr.Link, pr.Author.Login, msg)); err != nil {
+		return fmt.Errorf("comment err: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package updateconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/fakegitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+)
+
+const (
+	defaultNamespace = "default"
+	mergeSHA         = "12345"
+)
+
+func TestUpdateConfig(t *testing.T) {
+	basicPR := scm.PullRequest{
+		Number:   1,
+		Merged:   true,
+		MergeSha: mergeSHA,
+		Base: scm.PullRequestBranch{
+			Ref: "master",
+			Repo: scm.Repository{
+				Namespace: "jenkins-x",
+				Name:      "lighthouse",
+				Branch:    "master",
+			},
+		},
+		Author: scm.User{Login: "foo"},
+	}
+
+	testcases := []struct {
+		name            string
+		prAction        scm.Action
+		merged          bool
+		baseRef         string
+		changes         []*scm.Change
+		existConfigMaps []runtime.Object
+		expectedData    map[string]map[string]string
+		expectComment   bool
+	}{
+		{
+			name:     "Opened PR, no update",
+			prAction: scm.ActionOpen,
+			merged:   false,
+			changes: []*scm.Change{
+				{Path: "prow/config.yaml"},
+			},
+		},
+		{
+			name:     "Closed but not merged PR, no update",
+			prAction: scm.ActionClose,
+			merged:   false,
+			changes: []*scm.Change{
+				{Path: "prow/config.yaml"},
+			},
+		},
+		{
+			name:     "Merged PR to a non-default branch, no update",
+			prAction: scm.ActionClose,
+			merged:   true,
+			baseRef:  "release",
+			changes: []*scm.Change{
+				{Path: "prow/config.yaml"},
+			},
+		},
+		{
+			name:     "Merged PR not touching config, no update",
+			prAction: scm.ActionClose,
+			merged:   true,
+			changes: []*scm.Change{
+				{Path: "README.md"},
+			},
+		},
+		{
+			name:     "Merged PR creates the config configmap in every namespace",
+			prAction: scm.ActionClose,
+			merged:   true,
+			changes: []*scm.Change{
+				{Path: "prow/config.yaml"},
+			},
+			expectedData: map[string]map[string]string{
+				"default/config": {"config.yaml": "new-config"},
+				"other/config":   {"config.yaml": "new-config"},
+			},
+			expectComment: true,
+		},
+		{
+			name:     "Merged PR updates an existing configmap and keeps other keys",
+			prAction: scm.ActionClose,
+			merged:   true,
+			changes: []*scm.Change{
+				{Path: "prow/plugins.yaml"},
+			},
+			existConfigMaps: []runtime.Object{
+				&coreapi.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "plugins", Namespace: defaultNamespace},
+					Data:       map[string]string{"plugins.yaml": "old-plugins", "other": "kept"},
+				},
+			},
+			expectedData: map[string]map[string]string{
+				"default/plugins": {"plugins.yaml": "new-plugins", "other": "kept"},
+			},
+			expectComment: true,
+		},
+		{
+			name:     "Glob paths map each file to its own key",
+			prAction: scm.ActionClose,
+			merged:   true,
+			changes: []*scm.Change{
+				{Path: "jobs/a.yaml"},
+				{Path: "jobs/b.yaml"},
+			},
+			expectedData: map[string]map[string]string{
+				"default/jobs": {"a.yaml": "job-a", "b.yaml": "job-b"},
+			},
+			expectComment: true,
+		},
+		{
+			name:     "Deleted files remove their key",
+			prAction: scm.ActionClose,
+			merged:   true,
+			changes: []*scm.Change{
+				{Path: "jobs/a.yaml", Deleted: true},
+			},
+			existConfigMaps: []runtime.Object{
+				&coreapi.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "jobs", Namespace: defaultNamespace},
+					Data:       map[string]string{"a.yaml": "job-a", "b.yaml": "job-b"},
+				},
+			},
+			expectedData: map[string]map[string]string{
+				"default/jobs": {"b.yaml": "job-b"},
+			},
+			expectComment: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := basicPR
+			pr.Merged = tc.merged
+			if tc.baseRef != "" {
+				pr.Base.Ref = tc.baseRef
+			}
+			event := scm.PullRequestHook{
+				Action:      tc.prAction,
+				PullRequest: pr,
+			}
+
+			fgc := &fakegitprovider.FakeClient{
+				PullRequestComments: map[int][]*scm.Comment{},
+				PullRequestChanges:  map[int][]*scm.Change{pr.Number: tc.changes},
+				RemoteFiles: map[string]map[string]string{
+					"prow/config.yaml":  {mergeSHA: "new-config"},
+					"prow/plugins.yaml": {mergeSHA: "new-plugins"},
+					"jobs/a.yaml":       {mergeSHA: "job-a"},
+					"jobs/b.yaml":       {mergeSHA: "job-b"},
+				},
+			}
+			kc := fake.NewSimpleClientset(tc.existConfigMaps...)
+
+			maps := map[string]plugins.ConfigMapSpec{
+				"prow/config.yaml": {
+					Name:       "config",
+					Namespaces: []string{"", "other"},
+				},
+				"prow/plugins.yaml": {
+					Name:       "plugins",
+					Namespaces: []string{""},
+				},
+				"jobs/*.yaml": {
+					Name:       "jobs",
+					Namespaces: []string{""},
+				},
+			}
+
+			if err := handle(fgc, kc.CoreV1(), defaultNamespace, logrus.WithField("plugin", pluginName), event, maps); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tc.expectComment && len(fgc.PullRequestCommentsAdded) != 1 {
+				t.Errorf("expected a comment, got %v", fgc.PullRequestCommentsAdded)
+			}
+			if !tc.expectComment && len(fgc.PullRequestCommentsAdded) != 0 {
+				t.Errorf("expected no comment, got %v", fgc.PullRequestCommentsAdded)
+			}
+
+			for id, expected := range tc.expectedData {
+				parts := strings.SplitN(id, "/", 2)
+				cm, err := kc.CoreV1().ConfigMaps(parts[0]).Get(parts[1], metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("failed to get configmap %s: %v", id, err)
+				}
+				if !reflect.DeepEqual(cm.Data, expected) {
+					t.Errorf("configmap %s: expected data %v, got %v", id, expected, cm.Data)
+				}
+			}
+			if len(tc.expectedData) == 0 {
+				cms, err := kc.CoreV1().ConfigMaps(defaultNamespace).List(metav1.ListOptions{})
+				if err != nil {
+					t.Fatalf("failed to list configmaps: %v", err)
+				}
+				if len(cms.Items) != len(tc.existConfigMaps) {
+					t.Errorf("expected no configmaps to be created, got %v", cms.Items)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterChanges(t *testing.T) {
+	maps := map[string]plugins.ConfigMapSpec{
+		"config/*.yaml": {Name: "globbed", Namespaces: []string{""}},
+		"config/special.yaml": {
+			Name:       "special",
+			Key:        "special-key",
+			Namespaces: []string{"a", "b"},
+		},
+	}
+	changes := []*scm.Change{
+		{Path: "config/one.yaml"},
+		{Path: "config/special.yaml"},
+		{Path: "config/nested/two.yaml"},
+		{Path: "other.yaml"},
+	}
+	expected := map[ConfigMapID][]ConfigMapUpdate{
+		{Name: "globbed", Namespace: ""}:  {{Key: "one.yaml", Filename: "config/one.yaml"}},
+		{Name: "special", Namespace: "a"}: {{Key: "special-key", Filename: "config/special.yaml"}},
+		{Name: "special", Namespace: "b"}: {{Key: "special-key", Filename: "config/special.yaml"}},
+	}
+	actual := FilterChanges(maps, changes, logrus.WithField("plugin", pluginName))
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,317 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package verifyowners contains a plugin which validates the OWNERS files changed by pull requests.
+package verifyowners
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/labels"
+	"github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/prow/repoowners"
+)
+
+const (
+	pluginName            = "verify-owners"
+	ownersFileName        = "OWNERS"
+	aliasesFileName       = "OWNERS_ALIASES"
+	invalidOwnersResponse = "The following problems were found in the OWNERS files changed by this pull request"
+)
+
+func init() {
+	plugins.RegisterPullRequestHandler(pluginName, handlePullRequest, helpProvider)
+}
+
+func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
+	// The {WhoCanUse, Usage, Examples} fields are omitted because this plugin cannot be triggered manually.
+	return &pluginhelp.PluginHelp{
+			Description: fmt.Sprintf("The verify-owners plugin validates the %s and %s files changed by a pull request. It reports files which cannot be parsed, entries which are neither aliases nor collaborators, and directories with 'no_parent_owners' but no approvers, and applies the '%s' label until they are fixed.", ownersFileName, aliasesFileName, labels.InvalidOwners),
+		},
+		nil
+}
+
+type githubClient interface {
+	AddLabel(org, repo string, number int, label string, pr bool) error
+	RemoveLabel(org, repo string, number int, label string, pr bool) error
+	GetIssueLabels(org, repo string, number int, pr bool) ([]*scm.Label, error)
+	CreateComment(org, repo string, number int, pr bool, comment string) error
+	GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error)
+	GetFile(org, repo, filepath, commit string) ([]byte, error)
+	ListCollaborators(org, repo string) ([]scm.User, error)
+}
+
+type pruneClient interface {
+	PruneComments(bool, func(*scm.Comment) bool)
+}
+
+// problem is an issue found in an OWNERS or OWNERS_ALIASES file, line is 0 when unknown.
+type problem struct {
+	path    string
+	line    int
+	message string
+}
+
+func (p problem) String() string {
+	if p.line > 0 {
+		return fmt.Sprintf("`%s` line %d: %s", p.path, p.line, p.message)
+	}
+	return fmt.Sprintf("`%s`: %s", p.path, p.message)
+}
+
+func handlePullRequest(pc plugins.Agent, pre scm.PullRequestHook) error {
+	cp, err := pc.CommentPruner()
+	if err != nil {
+		return err
+	}
+	org := pre.Repo.Namespace
+	repo := pre.Repo.Name
+	return handle(pc.GitHubClient, pc.Logger, cp, pc.PluginConfig.SkipCollaborators(org, repo), pre)
+}
+
+func handle(ghc githubClient, log *logrus.Entry, cp pruneClient, skipCollaborators bool, pre scm.PullRequestHook) error {
+	if pre.Action != scm.ActionOpen && pre.Action != scm.ActionReopen && pre.Action != scm.ActionSync {
+		return nil
+	}
+	org := pre.Repo.Namespace
+	repo := pre.Repo.Name
+	number := pre.PullRequest.Number
+	sha := pre.PullRequest.Head.Sha
+
+	changes, err := ghc.GetPullRequestChanges(org, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get the changes of %s/%s#%d: %v", org, repo, number, err)
+	}
+	var ownersFiles []string
+	aliasesChanged := false
+	for _, change := range changes {
+		if change.Deleted {
+			continue
+		}
+		if change.Path == aliasesFileName {
+			aliasesChanged = true
+		} else if path.Base(change.Path) == ownersFileName {
+			ownersFiles = append(ownersFiles, change.Path)
+		}
+	}
+	sort.Strings(ownersFiles)
+
+	var problems []problem
+	if len(ownersFiles) > 0 || aliasesChanged {
+		problems, err = verify(ghc, log, org, repo, sha, ownersFiles, aliasesChanged, skipCollaborators)
+		if err != nil {
+			return err
+		}
+	}
+
+	issueLabels, err := ghc.GetIssueLabels(org, repo, number, true)
+	if err != nil {
+		return fmt.Errorf("failed to get the labels of %s/%s#%d: %v", org, repo, number, err)
+	}
+	hasLabel := false
+	for _, l := range issueLabels {
+		if l.Name == labels.InvalidOwners {
+			hasLabel = true
+			break
+		}
+	}
+
+	if len(problems) == 0 && !hasLabel {
+		return nil
+	}
+	// Replace any previous report as the problems may have changed with the new commits.
+	cp.PruneComments(true, func(c *scm.Comment) bool {
+		return strings.Contains(c.Body, invalidOwnersResponse)
+	})
+	if len(problems) == 0 {
+		log.Infof("Removing %q label.", labels.InvalidOwners)
+		return ghc.RemoveLabel(org, repo, number, labels.InvalidOwners, true)
+	}
+
+	if !hasLabel {
+		log.Infof("Adding %q label.", labels.InvalidOwners)
+		if err := ghc.AddLabel(org, repo, number, labels.InvalidOwners, true); err != nil {
+			return err
+		}
+	}
+	var lines []string
+	for _, p := range problems {
+		lines = append(lines, "- "+p.String())
+	}
+	msg := fmt.Sprintf("%s:\n\n%s\n\nThe `%s` label will be removed once they are fixed.", invalidOwnersResponse, strings.Join(lines, "\n"), labels.InvalidOwners)
+	return ghc.CreateComment(org, repo, number, true, plugins.FormatSimpleResponse(pre.PullRequest.Author.Login, msg))
+}
+
+// verify checks the OWNERS files and, if it changed, the OWNERS_ALIASES file at the given sha.
+func verify(ghc githubClient, log *logrus.Entry, org, repo, sha string, ownersFiles []string, aliasesChanged, skipCollaborators bool) ([]problem, error) {
+	var problems []problem
+
+	var aliases repoowners.RepoAliases
+	aliasesContent, err := ghc.GetFile(org, repo, aliasesFileName, sha)
+	if err != nil && err.Error() != scm.ErrNotFound.Error() {
+		return nil, fmt.Errorf("failed to get %s: %v", aliasesFileName, err)
+	}
+	if err == nil {
+		aliases, err = repoowners.ParseAliasesConfig(aliasesContent)
+		if err != nil {
+			if !aliasesChanged {
+				// The pull request did not break the aliases so do not blame it.
+				log.WithError(err).Warnf("Failed to parse %s.", aliasesFileName)
+			} else {
+				problems = append(problems, problem{path: aliasesFileName, message: fmt.Sprintf("cannot be parsed: %v", err)})
+			}
+		}
+	}
+
+	// Without collaborators checks, any entry could be a login so only the syntax is checked.
+	var collaborators sets.String
+	if !skipCollaborators {
+		users, err := ghc.ListCollaborators(org, repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the collaborators of %s/%s: %v", org, repo, err)
+		}
+		collaborators = sets.NewString()
+		for _, u := range users {
+			collaborators.Insert(gitprovider.NormLogin(u.Login))
+		}
+	}
+
+	if aliasesChanged && collaborators != nil {
+		var names []string
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, login := range aliases[name].List() {
+				if !collaborators.Has(login) {
+					problems = append(problems, problem{
+						path:    aliasesFileName,
+						line:    lineOf(aliasesContent, login),
+						message: fmt.Sprintf("%s in alias %s is not a collaborator of the repository", login, name),
+					})
+				}
+			}
+		}
+	}
+
+	for _, file := range ownersFiles {
+		content, err := ghc.GetFile(org, repo, file, sha)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %v", file, err)
+		}
+		problems = append(problems, verifyOwnersFile(file, content, aliases, collaborators)...)
+	}
+	return problems, nil
+}
+
+// verifyOwnersFile checks the content of a single OWNERS file, collaborators are
+// not checked if nil.
+func verifyOwnersFile(file string, content []byte, aliases repoowners.RepoAliases, collaborators sets.String) []problem {
+	full, err := repoowners.ParseFullConfig(content)
+	if err != nil {
+		return []problem{{path: file, message: fmt.Sprintf("cannot be parsed: %v", err)}}
+	}
+
+	var problems []problem
+	var configs []repoowners.Config
+	noParentOwners := full.Options.NoParentOwners
+	var approvers []string
+	if len(full.Filters) > 0 {
+		var patterns []string
+		for pattern := range full.Filters {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+		for _, pattern := range patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				problems = append(problems, problem{path: file, line: lineOf(content, pattern), message: fmt.Sprintf("invalid filter %q: %v", pattern, err)})
+			}
+			configs = append(configs, full.Filters[pattern])
+		}
+		approvers = full.Filters[".*"].Approvers
+	} else {
+		simple, err := repoowners.ParseSimpleConfig(content)
+		if err != nil {
+			return []problem{{path: file, message: fmt.Sprintf("cannot be parsed: %v", err)}}
+		}
+		configs = append(configs, simple.Config)
+		noParentOwners = simple.Options.NoParentOwners
+		approvers = simple.Approvers
+	}
+
+	if noParentOwners && len(approvers) == 0 {
+		problems = append(problems, problem{
+			path:    file,
+			line:    lineOf(content, "no_parent_owners"),
+			message: "no_parent_owners is set but there are no approvers, so nobody can approve changes to this directory",
+		})
+	}
+
+	if collaborators == nil {
+		return problems
+	}
+	checked := sets.NewString()
+	for _, config := range configs {
+		var entries []string
+		entries = append(entries, config.Approvers...)
+		entries = append(entries, config.Reviewers...)
+		entries = append(entries, config.RequiredReviewers...)
+		for _, entry := range entries {
+			login := gitprovider.NormLogin(entry)
+			if checked.Has(login) {
+				continue
+			}
+			checked.Insert(login)
+			if _, ok := aliases[login]; ok || collaborators.Has(login) {
+				continue
+			}
+			problems = append(problems, problem{
+				path:    file,
+				line:    lineOf(content, login),
+				message: fmt.Sprintf("%s is neither an alias in %s nor a collaborator of the repository", entry, aliasesFileName),
+			})
+		}
+	}
+	return problems
+}
+
+// lineOf returns the first line of a YAML file which is a key or a list item
+// matching the value, or 0 if there is none.
+func lineOf(content []byte, value string) int {
+	for i, line := range strings.Split(string(content), "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(line, "-"))
+		line = strings.Trim(line, `"'`)
+		if gitprovider.NormLogin(line) == gitprovider.NormLogin(value) || strings.HasPrefix(line, value+":") {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verifyowners
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/commentpruner"
+	"github.com/jenkins-x/lighthouse/pkg/prow/fakegitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/labels"
+)
+
+const (
+	headSHA = "0bd3ed50c88cd53a09316bf7a298f900e9371652"
+
+	aliases = `aliases:
+  sig-approvers:
+  - alice
+  - bob
+`
+)
+
+func TestHandle(t *testing.T) {
+	testCases := []struct {
+		name              string
+		files             map[string]string
+		hasLabel          bool
+		skipCollaborators bool
+
+		expectProblems []string
+		expectAdded    bool
+		expectRemoved  bool
+	}{
+		{
+			name: "valid OWNERS file",
+			files: map[string]string{
+				"pkg/OWNERS": "approvers:\n- alice\nreviewers:\n- sig-approvers\n",
+			},
+		},
+		{
+			name: "syntax error",
+			files: map[string]string{
+				"pkg/OWNERS": "approvers: [alice\n",
+			},
+			expectProblems: []string{"`pkg/OWNERS`: cannot be parsed"},
+			expectAdded:    true,
+		},
+		{
+			name: "unknown alias or login",
+			files: map[string]string{
+				"pkg/OWNERS": "approvers:\n- alice\n- sig-typo\n",
+			},
+			expectProblems: []string{"`pkg/OWNERS` line 3: sig-typo is neither an alias in OWNERS_ALIASES nor a collaborator of the repository"},
+			expectAdded:    true,
+		},
+		{
+			name: "unknown login is ignored when skipping collaborators",
+			files: map[string]string{
+				"pkg/OWNERS": "approvers:\n- alice\n- sig-typo\n",
+			},
+			skipCollaborators: true,
+		},
+		{
+			name: "no_parent_owners without approvers",
+			files: map[string]string{
+				"pkg/OWNERS": "options:\n  no_parent_owners: true\nreviewers:\n- bob\n",
+			},
+			expectProblems: []string{"`pkg/OWNERS` line 2: no_parent_owners is set but there are no approvers"},
+			expectAdded:    true,
+		},
+		{
+			name: "no_parent_owners with filters",
+			files: map[string]string{
+				"pkg/OWNERS": "options:\n  no_parent_owners: true\nfilters:\n  \".*\":\n    approvers:\n    - alice\n",
+			},
+		},
+		{
+			name: "alias member is not a collaborator",
+			files: map[string]string{
+				"OWNERS_ALIASES": aliases + "  sig-reviewers:\n  - mallory\n",
+			},
+			expectProblems: []string{"`OWNERS_ALIASES` line 6: mallory in alias sig-reviewers is not a collaborator of the repository"},
+			expectAdded:    true,
+		},
+		{
+			name: "fixed OWNERS file removes the label",
+			files: map[string]string{
+				"pkg/OWNERS": "approvers:\n- alice\n",
+			},
+			hasLabel:      true,
+			expectRemoved: true,
+		},
+		{
+			name:     "still broken OWNERS file keeps the label",
+			files:    map[string]string{"pkg/OWNERS": "approvers: [alice\n"},
+			hasLabel: true,
+
+			expectProblems: []string{"`pkg/OWNERS`: cannot be parsed"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fc := &fakegitprovider.FakeClient{
+				Collaborators:       []string{"alice", "bob"},
+				PullRequestChanges:  map[int][]*scm.Change{},
+				PullRequestComments: map[int][]*scm.Comment{},
+				IssueComments:       map[int][]*scm.Comment{},
+				RemoteFiles: map[string]map[string]string{
+					"OWNERS_ALIASES": {headSHA: aliases},
+				},
+			}
+			for path, content := range tc.files {
+				fc.PullRequestChanges[1] = append(fc.PullRequestChanges[1], &scm.Change{Path: path})
+				fc.RemoteFiles[path] = map[string]string{headSHA: content}
+			}
+			fc.PullRequestChanges[1] = append(fc.PullRequestChanges[1], &scm.Change{Path: "main.go"})
+			if tc.hasLabel {
+				fc.PullRequestLabelsExisting = []string{"org/repo#1:" + labels.InvalidOwners}
+				fc.PullRequestComments[1] = []*scm.Comment{{ID: 42, Body: invalidOwnersResponse, Author: scm.User{Login: fakegitprovider.Bot}}}
+			}
+			pre := scm.PullRequestHook{
+				Action: scm.ActionSync,
+				Repo:   scm.Repository{Namespace: "org", Name: "repo"},
+				PullRequest: scm.PullRequest{
+					Number: 1,
+					Author: scm.User{Login: "author"},
+					Head:   scm.PullRequestBranch{Sha: headSHA},
+				},
+			}
+			log := logrus.WithField("plugin", pluginName)
+			cp := commentpruner.NewEventClient(fc, log, "org", "repo", 1)
+
+			require.NoError(t, handle(fc, log, cp, tc.skipCollaborators, pre))
+
+			if tc.expectAdded {
+				assert.Equal(t, []string{"org/repo#1:" + labels.InvalidOwners}, fc.PullRequestLabelsAdded)
+			} else {
+				assert.Empty(t, fc.PullRequestLabelsAdded)
+			}
+			if tc.expectRemoved {
+				assert.Equal(t, []string{"org/repo#1:" + labels.InvalidOwners}, fc.PullRequestLabelsRemoved)
+			} else {
+				assert.Empty(t, fc.PullRequestLabelsRemoved)
+			}
+			if tc.hasLabel {
+				assert.Equal(t, []string{"org/repo#42"}, fc.PullRequestCommentsDeleted)
+			}
+			if len(tc.expectProblems) == 0 {
+				assert.Empty(t, fc.PullRequestCommentsAdded)
+				return
+			}
+			require.Len(t, fc.PullRequestCommentsAdded, 1)
+			comment := fc.PullRequestCommentsAdded[0]
+			assert.Equal(t, len(tc.expectProblems), strings.Count(comment, "\n- "))
+			for _, p := range tc.expectProblems {
+				assert.Contains(t, comment, p)
+			}
+		})
+	}
+}
+
+func TestHandleIgnoresOtherActions(t *testing.T) {
+	fc := &fakegitprovider.FakeClient{}
+	pre := scm.PullRequestHook{Action: scm.ActionClose}
+	assert.NoError(t, handle(fc, logrus.WithField("plugin", pluginName), nil, false, pre))
+}
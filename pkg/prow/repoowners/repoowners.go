@@ -300,18 +300,11 @@ func loadAliasesFrom(baseDir string, log *logrus.Entry) RepoAliases {
 		log.WithError(err).Warnf("Failed to read alias file %q. Using empty alias map.", path)
 		return nil
 	}
-	config := &struct {
-		Data map[string][]string `json:"aliases,omitempty"`
-	}{}
-	if err := yaml.Unmarshal(b, config); err != nil {
+	result, err := ParseAliasesConfig(b)
+	if err != nil {
 		log.WithError(err).Errorf("Failed to unmarshal aliases from %q. Using empty alias map.", path)
 		return nil
 	}
-
-	result := make(RepoAliases)
-	for alias, expanded := range config.Data {
-		result[gitprovider.NormLogin(alias)] = normLogins(expanded)
-	}
 	log.Infof("Loaded %d aliases from %q.", len(result), path)
 	return result
 }
@@ -441,6 +434,23 @@ func ParseSimpleConfig(b []byte) (SimpleConfig, error) {
 	return *simple, err
 }
 
+// ParseAliasesConfig will unmarshal an OWNERS_ALIASES file's content into RepoAliases
+// Returns an error if the content cannot be unmarshalled
+func ParseAliasesConfig(b []byte) (RepoAliases, error) {
+	config := &struct {
+		Data map[string][]string `json:"aliases,omitempty"`
+	}{}
+	if err := yaml.Unmarshal(b, config); err != nil {
+		return nil, err
+	}
+
+	result := make(RepoAliases)
+	for alias, expanded := range config.Data {
+		result[gitprovider.NormLogin(alias)] = normLogins(expanded)
+	}
+	return result, nil
+}
+
 var mdStructuredHeaderRegex = regexp.MustCompile("^---\n(.|\n)*\n---")
 
 // decodeOwnersMdConfig will parse the yaml header if it exists and unmarshal it into a singleOwnersConfig.
@@ -0,0 +1,141 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package slack provides a minimal client for posting messages to Slack.
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger provides an interface to log debug messages.
+type Logger interface {
+	Debugf(s string, v ...interface{})
+}
+
+// Client allows you to provide connection to Slack API Server
+// It contains a token that allows to authenticate connection to post and work with channels in the domain
+type Client struct {
+	// If logger is non-nil, log all method calls with it.
+	logger Logger
+
+	token   string
+	baseURL string
+	client  *http.Client
+	fake    bool
+}
+
+const (
+	// DefaultBaseURL is the base URL of the Slack Web API.
+	DefaultBaseURL = "https://slack.com/api"
+
+	chatPostMessage = "/chat.postMessage"
+
+	botName      = "lighthouse"
+	botIconEmoji = ":lighthouse:"
+)
+
+// NewClient creates a slack client with an API token.
+func NewClient(token string) *Client {
+	return NewClientWithBaseURL(token, DefaultBaseURL)
+}
+
+// NewClientWithBaseURL creates a slack client with an API token which talks to the
+// Slack API at the given base URL, e.g. a local stand-in for testing.
+func NewClientWithBaseURL(token, baseURL string) *Client {
+	return &Client{
+		logger:  logrus.WithField("client", "slack"),
+		token:   token,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{},
+	}
+}
+
+// NewFakeClient returns a client that takes no actions.
+func NewFakeClient() *Client {
+	return &Client{
+		fake: true,
+	}
+}
+
+func (sl *Client) log(methodName string, args ...interface{}) {
+	if sl.logger == nil {
+		return
+	}
+	var as []string
+	for _, arg := range args {
+		as = append(as, fmt.Sprintf("%v", arg))
+	}
+	sl.logger.Debugf("%s(%s)", methodName, strings.Join(as, ", "))
+}
+
+func (sl *Client) urlValues() *url.Values {
+	uv := url.Values{}
+	uv.Add("username", botName)
+	uv.Add("icon_emoji", botIconEmoji)
+	uv.Add("token", sl.token)
+	return &uv
+}
+
+func (sl *Client) postMessage(path string, uv *url.Values) error {
+	resp, err := sl.client.PostForm(sl.baseURL+path, *uv)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	apiResponse := struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}{}
+
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return fmt.Errorf("API returned invalid JSON (%q): %v", string(body), err)
+	}
+
+	if resp.StatusCode != 200 || !apiResponse.Ok {
+		return fmt.Errorf("request failed: %s", apiResponse.Error)
+	}
+
+	return nil
+}
+
+// WriteMessage adds text to channel
+func (sl *Client) WriteMessage(text, channel string) error {
+	sl.log("WriteMessage", text, channel)
+	if sl.fake {
+		return nil
+	}
+
+	var uv = sl.urlValues()
+	uv.Add("channel", channel)
+	uv.Add("text", text)
+
+	if err := sl.postMessage(chatPostMessage, uv); err != nil {
+		return fmt.Errorf("failed to post message to %s: %v", channel, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteMessage(t *testing.T) {
+	testCases := []struct {
+		name        string
+		response    string
+		status      int
+		expectedErr bool
+	}{
+		{
+			name:     "message posted",
+			response: `{"ok": true}`,
+			status:   http.StatusOK,
+		},
+		{
+			name:        "API error",
+			response:    `{"ok": false, "error": "channel_not_found"}`,
+			status:      http.StatusOK,
+			expectedErr: true,
+		},
+		{
+			name:        "invalid JSON",
+			response:    `not json`,
+			status:      http.StatusOK,
+			expectedErr: true,
+		},
+		{
+			name:        "HTTP error",
+			response:    `{"ok": true}`,
+			status:      http.StatusInternalServerError,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotChannel, gotText, gotToken, gotPath string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Fatalf("failed to parse form: %v", err)
+				}
+				gotPath = r.URL.Path
+				gotChannel = r.Form.Get("channel")
+				gotText = r.Form.Get("text")
+				gotToken = r.Form.Get("token")
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.response)
+			}))
+			defer server.Close()
+
+			client := NewClientWithBaseURL("secret", server.URL)
+			err := client.WriteMessage("hello", "sig-testing")
+			if tc.expectedErr && err == nil {
+				t.Fatal("expected an error but got none")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotPath != chatPostMessage {
+				t.Errorf("expected path %q, got %q", chatPostMessage, gotPath)
+			}
+			if gotChannel != "sig-testing" || gotText != "hello" || gotToken != "secret" {
+				t.Errorf("unexpected form values: channel=%q text=%q token=%q", gotChannel, gotText, gotToken)
+			}
+		})
+	}
+}
+
+func TestFakeClientWriteMessage(t *testing.T) {
+	if err := NewFakeClient().WriteMessage("hello", "sig-testing"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package stale
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/factory"
+	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/prow/labels"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Options holds the options for the stale command
+type Options struct {
+	ConfigPath    string
+	JobConfigPath string
+	BotName       string
+	DryRun        bool
+}
+
+// NewCmdStale creates the command which marks inactive issues and pull requests as stale,
+// then rotten, and finally closes them
+func NewCmdStale() *cobra.Command {
+	options := Options{}
+
+	cmd := &cobra.Command{
+		Use:   "stale",
+		Short: "Marks inactive issues and pull requests as stale, then rotten, and finally closes them",
+		Long: `Marks inactive issues and pull requests as stale, then rotten, and finally closes them.
+
+The repositories and inactivity thresholds are configured by the stale queries of the
+config.yaml file. Items labelled lifecycle/frozen are left alone. The command is meant to be
+run periodically, e.g. from a CronJob or a periodic job, and uses the git provider configured
+by the $GIT_KIND, $GIT_SERVER and $GIT_TOKEN environment variables.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := options.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	cmd.Flags().StringVar(&options.ConfigPath, "config-path", "", "Path to the config.yaml file.")
+	cmd.Flags().StringVar(&options.JobConfigPath, "job-config-path", "", "Path to a job config file or a directory of job config files.")
+	cmd.Flags().StringVar(&options.BotName, "bot-name", "", "The name of the bot user to run as. Defaults to $GIT_USER if not specified.")
+	cmd.Flags().BoolVar(&options.DryRun, "dry-run", false, "Only log the labels, comments and closures which would be made.")
+
+	return cmd
+}
+
+// Run processes the stale queries of the configuration
+func (o *Options) Run() error {
+	if o.ConfigPath == "" {
+		return errors.New("required flag --config-path was unset")
+	}
+	cfg, err := config.Load(o.ConfigPath, o.JobConfigPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load config %s", o.ConfigPath)
+	}
+
+	botName := o.BotName
+	if botName == "" {
+		botName = os.Getenv("GIT_USER")
+	}
+	scmClient, err := factory.NewClientFromEnvironment()
+	if err != nil {
+		return errors.Wrap(err, "cannot create SCM client")
+	}
+
+	s := &Syncer{
+		GitHubClient: gitprovider.ToClient(scmClient, botName),
+		DryRun:       o.DryRun,
+		Logger:       logrus.WithField("component", "stale"),
+	}
+	return s.Sync(cfg.Stale, time.Now())
+}
+
+type githubClient interface {
+	FindIssues(query, sort string, asc bool) ([]scm.Issue, error)
+	AddLabel(owner, repo string, number int, label string, pr bool) error
+	RemoveLabel(owner, repo string, number int, label string, pr bool) error
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+	CloseIssue(owner, repo string, number int) error
+	ClosePR(owner, repo string, number int) error
+}
+
+// Syncer labels and closes the inactive issues and pull requests matched by stale queries
+type Syncer struct {
+	GitHubClient githubClient
+	DryRun       bool
+	Logger       *logrus.Entry
+}
+
+// stage is a step of the lifecycle of inactive items
+type stage struct {
+	name       string
+	qualifiers string
+	inactive   time.Duration
+	comment    string
+	act        func(org, repo string, number int, pr bool) error
+}
+
+// Sync runs every stage of every stale query. Items are closed first and labelled stale last
+// so that an item only moves through a single stage per run.
+func (s *Syncer) Sync(cfg config.Stale, now time.Time) error {
+	failures := 0
+	for _, sq := range cfg.Queries {
+		for _, st := range s.stages(sq) {
+			for _, fullName := range sq.Repos {
+				for _, kind := range []string{"is:issue", "is:pr"} {
+					n, err := s.syncStage(st, sq, fullName, kind, now)
+					failures += n
+					if err != nil {
+						s.Logger.WithError(err).Errorf("Failed to search %s for the %s stage.", fullName, st.name)
+						failures++
+					}
+				}
+			}
+		}
+	}
+	if failures > 0 {
+		return errors.Errorf("failed to process %d stale queries or items", failures)
+	}
+	return nil
+}
+
+func (s *Syncer) stages(sq config.StaleQuery) []stage {
+	staleComment := sq.StaleComment
+	if staleComment == "" {
+		staleComment = fmt.Sprintf("This has had no activity for %s and is now stale.\n\nMark it as fresh with `/remove-lifecycle stale`. Stale items rot after an additional %s of inactivity and are eventually closed.\n\nTo stop this from happening, mark it as frozen with `/lifecycle frozen`.", days(sq.StaleAfter), days(sq.RottenAfter))
+	}
+	rottenComment := sq.RottenComment
+	if rottenComment == "" {
+		rottenComment = fmt.Sprintf("This has been stale for %s and is now rotten.\n\nMark it as fresh with `/remove-lifecycle rotten`. Rotten items are closed after an additional %s of inactivity.\n\nTo stop this from happening, mark it as frozen with `/lifecycle frozen`.", days(sq.RottenAfter), days(sq.CloseAfter))
+	}
+	closeComment := sq.CloseComment
+	if closeComment == "" {
+		closeComment = fmt.Sprintf("This has been rotten for %s and is now being closed.\n\nIf it is still relevant, reopen it and mark it as fresh with `/remove-lifecycle rotten`.", days(sq.CloseAfter))
+	}
+
+	ghc := s.GitHubClient
+	return []stage{
+		{
+			name:       "close",
+			qualifiers: fmt.Sprintf("label:%s", labels.LifecycleRotten),
+			inactive:   sq.CloseAfter,
+			comment:    closeComment,
+			act: func(org, repo string, number int, pr bool) error {
+				if pr {
+					return ghc.ClosePR(org, repo, number)
+				}
+				return ghc.CloseIssue(org, repo, number)
+			},
+		},
+		{
+			name:       "rotten",
+			qualifiers: fmt.Sprintf("label:%s -label:%s", labels.LifecycleStale, labels.LifecycleRotten),
+			inactive:   sq.RottenAfter,
+			comment:    rottenComment,
+			act: func(org, repo string, number int, pr bool) error {
+				if err := ghc.RemoveLabel(org, repo, number, labels.LifecycleStale, pr); err != nil {
+					return err
+				}
+				return ghc.AddLabel(org, repo, number, labels.LifecycleRotten, pr)
+			},
+		},
+		{
+			name:       "stale",
+			qualifiers: fmt.Sprintf("-label:%s -label:%s", labels.LifecycleStale, labels.LifecycleRotten),
+			inactive:   sq.StaleAfter,
+			comment:    staleComment,
+			act: func(org, repo string, number int, pr bool) error {
+				return ghc.AddLabel(org, repo, number, labels.LifecycleStale, pr)
+			},
+		},
+	}
+}
+
+// syncStage applies a stage to the matching items of a repo, returning the number of
+// items which could not be processed.
+func (s *Syncer) syncStage(st stage, sq config.StaleQuery, fullName, kind string, now time.Time) (int, error) {
+	query := searchQuery(st, sq, fullName, kind, now)
+	issues, err := s.GitHubClient.FindIssues(query, "updated", true)
+	if err != nil {
+		return 0, err
+	}
+	parts := strings.SplitN(fullName, "/", 2)
+	org, repo := parts[0], parts[1]
+	pr := kind == "is:pr"
+
+	failures := 0
+	for _, issue := range issues {
+		l := s.Logger.WithFields(logrus.Fields{"org": org, "repo": repo, "number": issue.Number, "stage": st.name})
+		if s.DryRun {
+			l.Infof("Dry run: skipping the %s stage for %q.", st.name, issue.Title)
+			continue
+		}
+		l.Infof("Running the %s stage for %q.", st.name, issue.Title)
+		// the comment is only made once the lifecycle changed so that it does not
+		// announce a change which failed, and is not repeated on the next sync
+		if err := st.act(org, repo, issue.Number, pr); err != nil {
+			l.WithError(err).Error("Failed to update the lifecycle.")
+			failures++
+			continue
+		}
+		if err := s.GitHubClient.CreateComment(org, repo, issue.Number, pr, st.comment); err != nil {
+			l.WithError(err).Error("Failed to comment.")
+			failures++
+		}
+	}
+	return failures, nil
+}
+
+// searchQuery returns the search query for the open items of a repo and kind which have been
+// inactive for long enough to move past the stage.
+func searchQuery(st stage, sq config.StaleQuery, fullName, kind string, now time.Time) string {
+	parts := []string{
+		"repo:" + fullName,
+		kind,
+		"is:open",
+		"-label:" + labels.LifecycleFrozen,
+		st.qualifiers,
+		"updated:<" + now.Add(-st.inactive).UTC().Format(time.RFC3339),
+	}
+	if sq.Query != "" {
+		parts = append(parts, sq.Query)
+	}
+	return strings.Join(parts, " ")
+}
+
+// days formats a duration as a number of days where possible
+func days(d time.Duration) string {
+	day := 24 * time.Hour
+	if d%day == 0 {
+		n := int(d / day)
+		if n == 1 {
+			return "1 day"
+		}
+		return fmt.Sprintf("%d days", n)
+	}
+	return d.String()
+}
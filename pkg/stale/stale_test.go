@@ -0,0 +1,113 @@
+package stale
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClient struct {
+	// results maps a search qualifier to the issues returned by queries containing it
+	results map[string][]scm.Issue
+	queries []string
+	actions []string
+}
+
+func (f *fakeClient) FindIssues(query, sort string, asc bool) ([]scm.Issue, error) {
+	f.queries = append(f.queries, query)
+	var issues []scm.Issue
+	for qualifier, results := range f.results {
+		if strings.Contains(query, qualifier) {
+			issues = append(issues, results...)
+		}
+	}
+	return issues, nil
+}
+
+func (f *fakeClient) AddLabel(owner, repo string, number int, label string, pr bool) error {
+	f.actions = append(f.actions, fmt.Sprintf("add %s/%s#%d %s pr=%t", owner, repo, number, label, pr))
+	return nil
+}
+
+func (f *fakeClient) RemoveLabel(owner, repo string, number int, label string, pr bool) error {
+	f.actions = append(f.actions, fmt.Sprintf("remove %s/%s#%d %s pr=%t", owner, repo, number, label, pr))
+	return nil
+}
+
+func (f *fakeClient) CreateComment(owner, repo string, number int, pr bool, comment string) error {
+	f.actions = append(f.actions, fmt.Sprintf("comment %s/%s#%d pr=%t %s", owner, repo, number, pr, strings.Split(comment, "\n")[0]))
+	return nil
+}
+
+func (f *fakeClient) CloseIssue(owner, repo string, number int) error {
+	f.actions = append(f.actions, fmt.Sprintf("close issue %s/%s#%d", owner, repo, number))
+	return nil
+}
+
+func (f *fakeClient) ClosePR(owner, repo string, number int) error {
+	f.actions = append(f.actions, fmt.Sprintf("close pr %s/%s#%d", owner, repo, number))
+	return nil
+}
+
+func testConfig() config.Stale {
+	return config.Stale{
+		Queries: []config.StaleQuery{
+			{
+				Repos:        []string{"org/repo"},
+				Query:        "-label:kind/bug",
+				StaleAfter:   90 * 24 * time.Hour,
+				RottenAfter:  30 * 24 * time.Hour,
+				CloseAfter:   30 * 24 * time.Hour,
+				CloseComment: "Closing this.",
+			},
+		},
+	}
+}
+
+func TestSync(t *testing.T) {
+	now := time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)
+	fc := &fakeClient{
+		results: map[string][]scm.Issue{
+			"is:issue is:open -label:lifecycle/frozen label:lifecycle/rotten updated:<2020-01-31T12:00:00Z":                         {{Number: 1}},
+			"is:pr is:open -label:lifecycle/frozen label:lifecycle/stale -label:lifecycle/rotten updated:<2020-01-31T12:00:00Z":     {{Number: 2}},
+			"is:issue is:open -label:lifecycle/frozen -label:lifecycle/stale -label:lifecycle/rotten updated:<2019-12-02T12:00:00Z": {{Number: 3}},
+		},
+	}
+	s := &Syncer{GitHubClient: fc, Logger: logrus.WithField("component", "stale")}
+	require.NoError(t, s.Sync(testConfig(), now))
+
+	assert.Len(t, fc.queries, 6)
+	for _, q := range fc.queries {
+		assert.True(t, strings.HasPrefix(q, "repo:org/repo "), q)
+		assert.True(t, strings.HasSuffix(q, " -label:kind/bug"), q)
+	}
+	assert.Equal(t, []string{
+		"close issue org/repo#1",
+		"comment org/repo#1 pr=false Closing this.",
+		"remove org/repo#2 lifecycle/stale pr=true",
+		"add org/repo#2 lifecycle/rotten pr=true",
+		"comment org/repo#2 pr=true This has been stale for 30 days and is now rotten.",
+		"add org/repo#3 lifecycle/stale pr=false",
+		"comment org/repo#3 pr=false This has had no activity for 90 days and is now stale.",
+	}, fc.actions)
+}
+
+func TestSyncDryRun(t *testing.T) {
+	fc := &fakeClient{
+		results: map[string][]scm.Issue{
+			"label:lifecycle/rotten": {{Number: 1}},
+		},
+	}
+	s := &Syncer{GitHubClient: fc, DryRun: true, Logger: logrus.WithField("component", "stale")}
+	require.NoError(t, s.Sync(testConfig(), time.Now()))
+
+	assert.Len(t, fc.queries, 6)
+	assert.Empty(t, fc.actions)
+}
@@ -0,0 +1,194 @@
+package tide
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/pkg/errors"
+)
+
+// BatchReverted is recorded in the history, in addition to MergeBatch, when a PR
+// reverting the PRs merged from a batch that failed to merge was opened
+const BatchReverted = "MERGE_BATCH_REVERT"
+
+// batchPushError is returned when the git provider refuses the push of a batch,
+// e.g. because of branch protection.
+type batchPushError struct {
+	err error
+}
+
+func (e batchPushError) Error() string {
+	return e.err.Error()
+}
+
+// mergeAtomicBatch merges all the PRs of a batch or none of them. The batch
+// commit which was tested is pushed to the base branch if the git provider
+// allows it. Otherwise the PRs are merged one at a time until one fails, in
+// which case the PRs already merged are reverted by a PR.
+//
+// The outcome of the batch is recorded in the history as a MergeBatch by the caller,
+// only the revert PR is recorded here.
+func (c *DefaultController) mergeAtomicBatch(sp subpool, prs []PullRequest) error {
+	log := sp.log.WithField("merge-targets", prNumbers(prs))
+
+	err := c.pushBatch(sp, prs)
+	if err == nil {
+		log.Info("Pushed the batch.")
+		tideMetrics.merges.WithLabelValues(sp.org, sp.repo, sp.branch).Observe(float64(len(prs)))
+		for _, pr := range prs {
+			c.tracker.merged(sp, pr)
+		}
+		return nil
+	}
+	if _, ok := err.(batchPushError); !ok {
+		return errors.Wrapf(err, "failed to merge batch %v", prNumbers(prs))
+	}
+
+	log.WithError(err).Info("Cannot push the batch, merging its PRs one at a time.")
+	merged, err := c.mergeEach(sp, prs, true)
+	if err == nil || len(merged) == 0 {
+		return err
+	}
+	// mergeEach merges the PRs in order so the merged ones come first
+	reverted := prs[:len(merged)]
+	var revertErr string
+	if number, rerr := c.revertBatch(sp, reverted); rerr != nil {
+		log.WithError(rerr).Error("Failed to revert the partially merged batch.")
+		revertErr = rerr.Error()
+		err = errors.Wrapf(err, "failed to revert %v: %v", merged, rerr)
+	} else {
+		log.Infof("Reverted the partially merged batch with PR #%d.", number)
+	}
+	c.History.Record(poolKey(sp.org, sp.repo, sp.branch), BatchReverted, sp.sha, revertErr, prMeta(reverted...))
+	return err
+}
+
+// pushBatch merges the PRs onto the base SHA of the subpool, as pickBatch does
+// when the batch is triggered, and pushes the result to the base branch. It
+// returns a batchPushError if only the push failed.
+func (c *DefaultController) pushBatch(sp subpool, prs []PullRequest) error {
+	r, err := c.cloneAt(sp)
+	if err != nil {
+		return err
+	}
+	defer r.Clean()
+
+	head, err := r.FetchBranch(sp.branch)
+	if err != nil {
+		return err
+	}
+	if head != sp.sha {
+		return fmt.Errorf("branch %s moved from %s to %s since the batch was tested", sp.branch, sp.sha, head)
+	}
+	for _, pr := range prs {
+		ok, err := r.Merge(string(pr.HeadRefOID))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("PR #%d no longer merges with the batch", int(pr.Number))
+		}
+	}
+	if err := r.PushToBranch("HEAD", sp.branch); err != nil {
+		return batchPushError{err: err}
+	}
+	return nil
+}
+
+// revertBatch reverts the commits the PRs merged from a batch, in the order they
+// were merged, added to the base branch: their merge or squashed commit, or all
+// their commits if they were rebased. It refuses to if the base branch moved by
+// anything else since the batch was tested. As the bot may not push to the base
+// branch, the reverts are pushed to a new branch and proposed in a PR, which is
+// merged if the git provider allows it. It returns the number of the PR.
+func (c *DefaultController) revertBatch(sp subpool, merged []PullRequest) (int, error) {
+	numbers := prNumbers(merged)
+	// mergeSHAs and counts hold the last commit each PR added to the first
+	// parents of the base branch and how many it added
+	var mergeSHAs []string
+	var counts []int
+	total := 0
+	for _, pr := range merged {
+		number := int(pr.Number)
+		merge, err := c.ghc.GetPullRequest(sp.org, sp.repo, number)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to get PR #%d", number)
+		}
+		if merge.MergeSha == "" {
+			return 0, fmt.Errorf("PR #%d has no merge commit", number)
+		}
+		count := 1
+		method, err := c.mergeMethod(sp, pr)
+		if err != nil {
+			return 0, err
+		}
+		if method == gitprovider.MergeRebase {
+			commits, err := c.ghc.ListPRCommits(sp.org, sp.repo, number)
+			if err != nil {
+				return 0, errors.Wrapf(err, "failed to list the commits of PR #%d", number)
+			}
+			count = len(commits)
+		}
+		mergeSHAs = append(mergeSHAs, merge.MergeSha)
+		counts = append(counts, count)
+		total += count
+	}
+
+	r, err := c.cloneAt(sp)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Clean()
+
+	head, err := r.FetchBranch(sp.branch)
+	if err != nil {
+		return 0, err
+	}
+	commits, err := r.FirstParents(sp.sha, head)
+	if err != nil {
+		return 0, err
+	}
+	if len(commits) != total {
+		return 0, fmt.Errorf("branch %s has %d commits since %s where the merges of %v added %d", sp.branch, len(commits), sp.sha, numbers, total)
+	}
+	// the commits are newest first so the first merged PR added the last ones
+	end := len(commits)
+	for i, sha := range mergeSHAs {
+		end -= counts[i]
+		if commits[end] != sha {
+			return 0, fmt.Errorf("branch %s has commits other than the merges of %v since %s, such as %s", sp.branch, numbers, sp.sha, commits[end])
+		}
+	}
+
+	if err := r.Checkout(head); err != nil {
+		return 0, err
+	}
+	for _, commit := range commits {
+		if err := r.Revert(commit); err != nil {
+			return 0, err
+		}
+	}
+	revertSHA, err := r.RevParse("HEAD")
+	if err != nil {
+		return 0, err
+	}
+	branch := "tide-revert-" + head
+	if err := r.PushToBranch("HEAD", branch); err != nil {
+		return 0, err
+	}
+	title := fmt.Sprintf("Revert partial merge of batch %v", numbers)
+	body := fmt.Sprintf("The batch could not be merged atomically so the PRs merged from it, %v, are reverted.", numbers)
+	number, err := c.ghc.CreatePullRequest(sp.org, sp.repo, title, body, branch, sp.branch)
+	if err != nil {
+		return 0, err
+	}
+	details := gitprovider.MergeDetails{
+		SHA:         strings.TrimSpace(revertSHA),
+		MergeMethod: string(c.config().Tide.MergeMethod(sp.org, sp.repo, sp.branch)),
+	}
+	if err := c.ghc.Merge(sp.org, sp.repo, number, details); err != nil {
+		return number, errors.Wrapf(err, "opened revert PR #%d but could not merge it", number)
+	}
+	return number, nil
+}
@@ -0,0 +1,216 @@
+package tide
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/git/localgit"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/tide/history"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeAtomicBatch(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	testCases := []struct {
+		name string
+		// pushAllowed leaves the base branch of the local remote unchecked out
+		// so that git accepts pushes to it
+		pushAllowed bool
+		baseMoved   bool
+		// rebase merges the PRs by rebasing their two commits on the base branch
+		rebase    bool
+		mergeErrs map[int]error
+		// pushedMidway is the PR whose merge someone else pushes to the base
+		// branch just before
+		pushedMidway int
+
+		expectErr         bool
+		expectMerged      int
+		expectFiles       []int
+		expectOpened      int
+		expectActions     []string
+		expectReverted    []int
+		expectRevertError bool
+	}{
+		{
+			name:        "batch pushed",
+			pushAllowed: true,
+			expectFiles: []int{1, 2, 3},
+		},
+		{
+			name:        "base moved",
+			pushAllowed: true,
+			baseMoved:   true,
+			expectErr:   true,
+		},
+		{
+			name:         "push refused, PRs merged",
+			expectMerged: 3,
+			expectFiles:  []int{1, 2, 3},
+		},
+		{
+			name:           "push refused, merge fails and merged PRs are reverted",
+			mergeErrs:      map[int]error{3: gitprovider.UnmergablePRError("test error")},
+			expectErr:      true,
+			expectMerged:   3,
+			expectOpened:   1,
+			expectActions:  []string{BatchReverted},
+			expectReverted: []int{1, 2},
+		},
+		{
+			name:           "push refused, merge fails and rebased PRs are reverted",
+			rebase:         true,
+			mergeErrs:      map[int]error{3: gitprovider.UnmergablePRError("test error")},
+			expectErr:      true,
+			expectMerged:   3,
+			expectOpened:   1,
+			expectActions:  []string{BatchReverted},
+			expectReverted: []int{1, 2},
+		},
+		{
+			name:              "push refused, merge fails and the branch has other commits",
+			mergeErrs:         map[int]error{3: gitprovider.UnmergablePRError("test error")},
+			pushedMidway:      2,
+			expectErr:         true,
+			expectMerged:      2,
+			expectFiles:       []int{1, 2},
+			expectActions:     []string{BatchReverted},
+			expectReverted:    []int{1, 2},
+			expectRevertError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lg, gc, err := localgit.New()
+			require.NoError(t, err)
+			defer gc.Clean()
+			defer lg.Clean()
+			require.NoError(t, lg.MakeFakeRepo("o", "r"))
+
+			var prs []PullRequest
+			prCommits := map[int][]scm.Commit{}
+			prFiles := func(i int) []map[string][]byte {
+				files := []map[string][]byte{{fmt.Sprintf("%d", i): []byte("WOW")}}
+				if tc.rebase {
+					files = append(files, map[string][]byte{fmt.Sprintf("%d-more", i): []byte("WOW")})
+				}
+				return files
+			}
+			for i := 1; i <= 3; i++ {
+				require.NoError(t, lg.CheckoutNewBranch("o", "r", fmt.Sprintf("pr-%d", i)))
+				for _, files := range prFiles(i) {
+					require.NoError(t, lg.AddCommit("o", "r", files))
+					prCommits[i] = append(prCommits[i], scm.Commit{})
+				}
+				require.NoError(t, lg.Checkout("o", "r", "master"))
+				var pr PullRequest
+				pr.Number = githubql.Int(i)
+				pr.HeadRefOID = githubql.String(fmt.Sprintf("origin/pr-%d", i))
+				prs = append(prs, pr)
+			}
+			baseSHA, err := lg.RevParse("o", "r", "master")
+			require.NoError(t, err)
+			if tc.baseMoved {
+				require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{"moved": []byte("WOW")}))
+			}
+			if tc.pushAllowed {
+				require.NoError(t, lg.CheckoutNewBranch("o", "r", "parking"))
+			}
+
+			tideConfig := config.Tide{AtomicBatchMap: map[string]bool{"o": true}}
+			if tc.rebase {
+				tideConfig.MergeType = map[string]gitprovider.PullRequestMergeType{"o": gitprovider.MergeRebase}
+			}
+			ca := &config.Agent{}
+			ca.Set(&config.Config{
+				ProwConfig: config.ProwConfig{Tide: tideConfig},
+			})
+			hist, err := history.New(10, nil, "")
+			require.NoError(t, err)
+			ghc := &fgc{mergeErrs: tc.mergeErrs, pullRequests: map[int]*scm.PullRequest{}, commits: prCommits}
+			// merge the PRs, and the revert PRs opened by tide, in the local remote
+			ghc.mergeFunc = func(number int) error {
+				if number == tc.pushedMidway {
+					require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{"midway": []byte("WOW")}))
+				}
+				branch := fmt.Sprintf("pr-%d", number)
+				if number > 100 {
+					branch = ghc.opened[number-101]
+				}
+				if tc.rebase && number < 100 {
+					for _, files := range prFiles(number) {
+						if err := lg.AddCommit("o", "r", files); err != nil {
+							return err
+						}
+					}
+				} else if err := lg.Merge("o", "r", branch); err != nil {
+					return err
+				}
+				sha, err := lg.RevParse("o", "r", "master")
+				if err != nil {
+					return err
+				}
+				ghc.pullRequests[number] = &scm.PullRequest{Number: number, Merged: true, MergeSha: sha}
+				return nil
+			}
+			c := &DefaultController{
+				logger:  logrus.WithField("component", "tide"),
+				gc:      gc,
+				config:  ca.Config,
+				ghc:     ghc,
+				History: hist,
+			}
+			sp := subpool{
+				log:    logrus.WithField("component", "tide"),
+				org:    "o",
+				repo:   "r",
+				branch: "master",
+				sha:    baseSHA,
+			}
+
+			err = c.mergePRs(sp, prs)
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectMerged, ghc.merged)
+			assert.Len(t, ghc.opened, tc.expectOpened)
+
+			var files []int
+			for i := 1; i <= 3; i++ {
+				if _, err := lg.RevParse("o", "r", fmt.Sprintf("master:%d", i)); err == nil {
+					files = append(files, i)
+				}
+			}
+			assert.Equal(t, tc.expectFiles, files, "files of the PRs on master")
+			if tc.pushedMidway != 0 {
+				_, err := lg.RevParse("o", "r", "master:midway")
+				assert.NoError(t, err, "the commit pushed midway should be kept")
+			}
+
+			var actions []string
+			var reverted []int
+			for _, record := range hist.AllRecords()[poolKey("o", "r", "master")] {
+				actions = append(actions, record.Action)
+				if record.Action == BatchReverted {
+					assert.Equal(t, tc.expectRevertError, record.Err != "", record.Err)
+					for _, pull := range record.Target {
+						reverted = append(reverted, pull.Number)
+					}
+				}
+			}
+			assert.Equal(t, tc.expectActions, actions)
+			assert.Equal(t, tc.expectReverted, reverted)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package tide
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/tide/blockers"
+	"github.com/sirupsen/logrus"
+)
+
+// blockerExpiredMarker identifies the comments tide posts on expired blocker issues
+const blockerExpiredMarker = "<!-- tide: blocker expired -->"
+
+// blockerClient is the subset of gitprovider.Client methods used to comment on expired blockers
+type blockerClient interface {
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+	ListIssueComments(org, repo string, number int) ([]*scm.Comment, error)
+}
+
+// prBlockers returns the blockers of the branch of the PR which apply to it.
+func prBlockers(blocks blockers.Blockers, pr *PullRequest, changedFiles config.ChangedFilesProvider) []blockers.Blocker {
+	return applicableBlockers(blocks.GetApplicable(string(pr.Repository.Owner.Login), string(pr.Repository.Name), string(pr.BaseRef.Name)), pr, changedFiles)
+}
+
+// applicableBlockers returns the blockers which apply to the PR.
+func applicableBlockers(blocks []blockers.Blocker, pr *PullRequest, changedFiles config.ChangedFilesProvider) []blockers.Blocker {
+	var res []blockers.Blocker
+	labels := prLabels(pr)
+	for _, block := range blocks {
+		applies, err := block.AppliesTo(labels, changedFiles)
+		if err != nil {
+			logrus.WithFields(pr.logFields()).WithError(err).Warnf("Cannot list the changed files, assuming blocker %d applies.", block.Number)
+		}
+		if applies {
+			res = append(res, block)
+		}
+	}
+	return res
+}
+
+// blocksPool returns true if a blocker is not scoped to paths or labels, so that
+// it blocks every PR of the pool.
+func blocksPool(blocks []blockers.Blocker) bool {
+	for _, block := range blocks {
+		if !block.Scoped() {
+			return true
+		}
+	}
+	return false
+}
+
+// splitBlocked splits the PRs of the subpool into those the scoped blockers do
+// not apply to and the others.
+func (c *DefaultController) splitBlocked(sp subpool, blocks []blockers.Blocker) ([]PullRequest, []PullRequest) {
+	if len(blocks) == 0 {
+		return sp.prs, nil
+	}
+	var unblocked, blocked []PullRequest
+	for _, pr := range sp.prs {
+		var changedFiles config.ChangedFilesProvider
+		if c.changedFiles != nil {
+			changedFiles = c.changedFiles.prChanges(&pr)
+		}
+		if len(applicableBlockers(blocks, &pr, changedFiles)) > 0 {
+			blocked = append(blocked, pr)
+		} else {
+			unblocked = append(unblocked, pr)
+		}
+	}
+	return unblocked, blocked
+}
+
+// blockedDescription describes the blockers of a PR for its status, e.g.
+// " Merging is blocked by issue 3: release freeze for 2h30m."
+func blockedDescription(blocks []blockers.Blocker, now time.Time) string {
+	var numbers, titles []string
+	// merging is blocked until the last blocker expires, if they all expire
+	var until time.Time
+	expires := true
+	for _, block := range blocks {
+		numbers = append(numbers, strconv.Itoa(block.Number))
+		if title := strings.TrimSpace(block.Title); title != "" {
+			titles = append(titles, title)
+		}
+		if block.Expires == nil {
+			expires = false
+		} else if block.Expires.After(until) {
+			until = *block.Expires
+		}
+	}
+	var s string
+	if len(numbers) > 1 {
+		s = "s"
+	}
+	desc := fmt.Sprintf(" Merging is blocked by issue%s %s", s, strings.Join(numbers, ", "))
+	if len(titles) > 0 {
+		desc += ": " + strings.Join(titles, "; ")
+	}
+	if expires && !until.IsZero() {
+		desc += " for " + remaining(until.Sub(now))
+	}
+	return desc + "."
+}
+
+// remaining formats a duration to the minute, e.g. 2h30m.
+func remaining(d time.Duration) string {
+	if d < time.Minute {
+		d = time.Minute
+	}
+	return strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+}
+
+// expiredBlockerNotifier comments once on the blocker issues which expired.
+type expiredBlockerNotifier struct {
+	ghc    blockerClient
+	logger *logrus.Entry
+	dryRun bool
+
+	lock sync.Mutex
+	// commented holds the expired blockers already commented on, keyed by org/repo#number
+	commented map[string]bool
+}
+
+func newExpiredBlockerNotifier(ghc blockerClient, logger *logrus.Entry, dryRun bool) *expiredBlockerNotifier {
+	return &expiredBlockerNotifier{
+		ghc:       ghc,
+		logger:    logger.WithField("component", "expired-blockers"),
+		dryRun:    dryRun,
+		commented: make(map[string]bool),
+	}
+}
+
+// notify comments on the expired blockers unless tide already did.
+func (n *expiredBlockerNotifier) notify(expired map[blockers.OrgRepo][]blockers.Blocker) {
+	if n == nil {
+		return
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	current := make(map[string]bool)
+	for orgRepo, blocks := range expired {
+		for _, block := range blocks {
+			key := fmt.Sprintf("%s/%s#%d", orgRepo.Org, orgRepo.Repo, block.Number)
+			current[key] = true
+			if n.commented[key] {
+				continue
+			}
+			log := n.logger.WithField("blocker", key)
+			comments, err := n.ghc.ListIssueComments(orgRepo.Org, orgRepo.Repo, block.Number)
+			if err != nil {
+				log.WithError(err).Warn("Failed to list the comments of the expired blocker.")
+				continue
+			}
+			found := false
+			for _, comment := range comments {
+				if strings.Contains(comment.Body, blockerExpiredMarker) {
+					found = true
+				}
+			}
+			if !found {
+				if n.dryRun {
+					log.Info("Dry run: not commenting on the expired blocker.")
+				} else if err := n.ghc.CreateComment(orgRepo.Org, orgRepo.Repo, block.Number, false, expiredBlockerComment(*block.Expires)); err != nil {
+					log.WithError(err).Warn("Failed to comment on the expired blocker.")
+					continue
+				}
+			}
+			n.commented[key] = true
+		}
+	}
+	for key := range n.commented {
+		if !current[key] {
+			delete(n.commented, key)
+		}
+	}
+}
+
+func expiredBlockerComment(expires time.Time) string {
+	return fmt.Sprintf("%s\nThis blocker expired at %s so tide no longer blocks merges on it. Close the issue, or update its `expires:` line to block merges again.\n",
+		blockerExpiredMarker, expires.UTC().Format(time.RFC3339))
+}
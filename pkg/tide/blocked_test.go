@@ -0,0 +1,97 @@
+package tide
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/tide/blockers"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBlockerClient struct {
+	comments map[int][]*scm.Comment
+}
+
+func (f *fakeBlockerClient) CreateComment(owner, repo string, number int, pr bool, comment string) error {
+	f.comments[number] = append(f.comments[number], &scm.Comment{Body: comment})
+	return nil
+}
+
+func (f *fakeBlockerClient) ListIssueComments(org, repo string, number int) ([]*scm.Comment, error) {
+	return f.comments[number], nil
+}
+
+func TestSplitBlocked(t *testing.T) {
+	pr1 := testPR("o", "r", "master", 1, githubql.MergeableStateMergeable)
+	pr2 := testPR("o", "r", "master", 2, githubql.MergeableStateMergeable)
+	pr2.Labels.Nodes = append(pr2.Labels.Nodes, struct{ Name githubql.String }{Name: "hotfix"})
+	sp := subpool{org: "o", repo: "r", branch: "master", prs: []PullRequest{pr1, pr2}}
+	c := &DefaultController{}
+
+	unblocked, blocked := c.splitBlocked(sp, nil)
+	assert.Equal(t, []int{1, 2}, prNumbers(unblocked))
+	assert.Empty(t, blocked)
+
+	freeze := blockers.Blocker{Number: 3, Paths: []string{"**"}, ExceptLabels: []string{"hotfix"}}
+	assert.False(t, blocksPool([]blockers.Blocker{freeze}))
+	assert.True(t, blocksPool([]blockers.Blocker{freeze, {Number: 4}}))
+	unblocked, blocked = c.splitBlocked(sp, []blockers.Blocker{freeze})
+	assert.Equal(t, []int{2}, prNumbers(unblocked))
+	assert.Equal(t, []int{1}, prNumbers(blocked))
+
+	docs := blockers.Blocker{Number: 5, Paths: []string{"docs/**"}}
+	files := func(names ...string) func() ([]string, error) {
+		return func() ([]string, error) { return names, nil }
+	}
+	assert.Empty(t, applicableBlockers([]blockers.Blocker{docs}, &pr1, files("main.go")))
+	assert.Equal(t, []blockers.Blocker{docs}, applicableBlockers([]blockers.Blocker{docs}, &pr1, files("docs/index.md")))
+}
+
+func TestBlockedDescription(t *testing.T) {
+	now := time.Date(2020, 3, 11, 10, 0, 0, 0, time.UTC)
+	soon, later := now.Add(90*time.Minute), now.Add(150*time.Minute)
+
+	assert.Equal(t, " Merging is blocked by issues 1, 2.", blockedDescription([]blockers.Blocker{{Number: 1}, {Number: 2}}, now))
+	assert.Equal(t, " Merging is blocked by issue 3: release freeze for 2h30m.",
+		blockedDescription([]blockers.Blocker{{Number: 3, Title: " release freeze ", Expires: &later}}, now))
+	assert.Equal(t, " Merging is blocked by issues 3, 4: release freeze; docs move for 2h30m.",
+		blockedDescription([]blockers.Blocker{{Number: 3, Title: "release freeze", Expires: &later}, {Number: 4, Title: "docs move", Expires: &soon}}, now))
+	// merging stays blocked if a blocker never expires
+	assert.Equal(t, " Merging is blocked by issues 3, 5.",
+		blockedDescription([]blockers.Blocker{{Number: 3, Expires: &later}, {Number: 5}}, now))
+}
+
+func TestExpiredBlockerNotifier(t *testing.T) {
+	expires := time.Date(2020, 3, 11, 10, 0, 0, 0, time.UTC)
+	key := blockers.OrgRepo{Org: "o", Repo: "r"}
+	expired := map[blockers.OrgRepo][]blockers.Blocker{
+		key: {{Number: 1, Expires: &expires}, {Number: 2, Expires: &expires}},
+	}
+	ghc := &fakeBlockerClient{comments: map[int][]*scm.Comment{
+		2: {{Body: expiredBlockerComment(expires)}},
+	}}
+	n := newExpiredBlockerNotifier(ghc, logrus.WithField("component", "tide"), false)
+
+	n.notify(expired)
+	require.Len(t, ghc.comments[1], 1)
+	assert.Contains(t, ghc.comments[1][0].Body, blockerExpiredMarker)
+	assert.Contains(t, ghc.comments[1][0].Body, "2020-03-11T10:00:00Z")
+	assert.Len(t, ghc.comments[2], 1, "the blocker was already commented on")
+
+	// blockers are only commented on once
+	n.notify(expired)
+	assert.Len(t, ghc.comments[1], 1)
+
+	// blockers which are no longer expired are forgotten
+	n.notify(nil)
+	assert.Empty(t, n.commented)
+
+	// nothing is commented in dry-run mode
+	dryRun := newExpiredBlockerNotifier(ghc, logrus.WithField("component", "tide"), true)
+	dryRun.notify(map[blockers.OrgRepo][]blockers.Blocker{key: {{Number: 3, Expires: &expires}}})
+	assert.Empty(t, ghc.comments[3])
+}
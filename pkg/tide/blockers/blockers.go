@@ -31,6 +31,10 @@ import (
 
 var (
 	branchRE = regexp.MustCompile(`(?im)\bbranch:[^\w-]*([\w-./]+)\b`)
+	// bodyFieldRE matches the "key: values" lines of the body of a blocker issue
+	bodyFieldRE = regexp.MustCompile(`(?im)^[ \t]*(branches|paths|expires|except-labels)[ \t]*:[ \t]*(.*?)[ \t]*$`)
+	// listSeparatorRE splits the values of a body field
+	listSeparatorRE = regexp.MustCompile(`[\s,]+`)
 )
 
 type githubClient interface {
@@ -41,7 +45,72 @@ type githubClient interface {
 type Blocker struct {
 	Number     int
 	Title, URL string
-	// TODO: time blocked? (when blocker label was added)
+	// Paths are the globs of the files the blocker applies to, e.g. docs/** or
+	// *.md. The blocker applies to every PR if empty.
+	Paths []string
+	// ExceptLabels are the labels of the PRs the blocker does not apply to.
+	ExceptLabels []string
+	// Expires is when the blocker stops blocking merges, never if nil.
+	Expires *time.Time
+}
+
+// Scoped returns true if the blocker only applies to some of the PRs of a branch.
+func (b Blocker) Scoped() bool {
+	return len(b.Paths) > 0 || len(b.ExceptLabels) > 0
+}
+
+// AppliesTo returns true if the blocker applies to a PR with the given labels.
+// The files changed by the PR are only listed if the blocker is restricted to
+// paths; the blocker applies if they cannot be listed.
+func (b Blocker) AppliesTo(labels []string, changedFiles func() ([]string, error)) (bool, error) {
+	for _, label := range labels {
+		for _, except := range b.ExceptLabels {
+			if label == except {
+				return false, nil
+			}
+		}
+	}
+	if len(b.Paths) == 0 || changedFiles == nil {
+		return true, nil
+	}
+	files, err := changedFiles()
+	if err != nil {
+		return true, err
+	}
+	for _, file := range files {
+		for _, glob := range b.Paths {
+			if matchGlob(glob, file) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// matchGlob returns true if the path matches the glob, where * matches within a
+// directory and ** matches any number of directories.
+func matchGlob(glob, path string) bool {
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			re.WriteString("[^/]*")
+		case glob[i] == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	matched, err := regexp.MatchString(re.String(), strings.TrimPrefix(path, "/"))
+	return err == nil && matched
 }
 
 // OrgRepo the org + repo
@@ -58,6 +127,8 @@ type OrgRepoBranch struct {
 type Blockers struct {
 	Repo   map[OrgRepo][]Blocker       `json:"repo,omitempty"`
 	Branch map[OrgRepoBranch][]Blocker `json:"branch,omitempty"`
+	// Expired holds the blockers which expired and no longer block any branch.
+	Expired map[OrgRepo][]Blocker `json:"expired,omitempty"`
 }
 
 // GetApplicable returns the subset of blockers applicable to the specified branch.
@@ -84,7 +155,39 @@ func FindAll(ghc githubClient, log *logrus.Entry, label, orgRepoTokens string) (
 		return Blockers{}, fmt.Errorf("error searching for blocker issues: %v", err)
 	}
 
-	return fromIssues(issues, log), nil
+	return fromIssues(issues, log).expire(time.Now()), nil
+}
+
+// expire moves the blockers which expired before now to Expired.
+func (b Blockers) expire(now time.Time) Blockers {
+	res := Blockers{Repo: make(map[OrgRepo][]Blocker), Branch: make(map[OrgRepoBranch][]Blocker), Expired: make(map[OrgRepo][]Blocker)}
+	expired := func(key OrgRepo, block Blocker) bool {
+		if block.Expires == nil || block.Expires.After(now) {
+			return false
+		}
+		for _, other := range res.Expired[key] {
+			if other.Number == block.Number {
+				return true
+			}
+		}
+		res.Expired[key] = append(res.Expired[key], block)
+		return true
+	}
+	for key, blocks := range b.Repo {
+		for _, block := range blocks {
+			if !expired(key, block) {
+				res.Repo[key] = append(res.Repo[key], block)
+			}
+		}
+	}
+	for key, blocks := range b.Branch {
+		for _, block := range blocks {
+			if !expired(OrgRepo{Org: key.Org, Repo: key.Repo}, block) {
+				res.Branch[key] = append(res.Branch[key], block)
+			}
+		}
+	}
+	return res
 }
 
 func fromIssues(issues []Issue, log *logrus.Entry) Blockers {
@@ -98,7 +201,8 @@ func fromIssues(issues []Issue, log *logrus.Entry) Blockers {
 			Title:  strippedTitle,
 			URL:    string(issue.URL),
 		}
-		if branches := parseBranches(string(issue.Title)); len(branches) > 0 {
+		bodyBranches := parseBody(string(issue.Body), &block, logger)
+		if branches := append(parseBranches(string(issue.Title)), bodyBranches...); len(branches) > 0 {
 			for _, branch := range branches {
 				key := OrgRepoBranch{
 					Org:    string(issue.Repository.Owner.Login),
@@ -138,6 +242,46 @@ func parseBranches(str string) []string {
 	return res
 }
 
+// parseBody sets the paths, label exceptions and expiry of the blocker from the
+// "key: values" lines of the issue body, e.g.:
+//
+//	branches: master, release-1.2
+//	paths: docs/**, *.md
+//	expires: 2020-03-12T00:00:00Z
+//	except-labels: hotfix
+//
+// The branches are returned.
+func parseBody(body string, block *Blocker, log *logrus.Entry) []string {
+	var branches []string
+	for _, match := range bodyFieldRE.FindAllStringSubmatch(body, -1) {
+		var values []string
+		for _, value := range listSeparatorRE.Split(match[2], -1) {
+			if value = strings.Trim(value, "\"'`"); value != "" {
+				values = append(values, value)
+			}
+		}
+		switch strings.ToLower(match[1]) {
+		case "branches":
+			branches = append(branches, values...)
+		case "paths":
+			block.Paths = append(block.Paths, values...)
+		case "except-labels":
+			block.ExceptLabels = append(block.ExceptLabels, values...)
+		case "expires":
+			if len(values) == 0 {
+				continue
+			}
+			expires, err := time.Parse(time.RFC3339, values[0])
+			if err != nil {
+				log.WithError(err).Warnf("Ignoring the invalid expiry %q of the blocker, expected a time like %s.", values[0], time.RFC3339)
+				continue
+			}
+			block.Expires = &expires
+		}
+	}
+	return branches
+}
+
 func search(ctx context.Context, ghc githubClient, log *logrus.Entry, q string) ([]Issue, error) {
 	requestStart := time.Now()
 	var ret []Issue
@@ -172,6 +316,7 @@ func search(ctx context.Context, ghc githubClient, log *logrus.Entry, q string)
 type Issue struct {
 	Number     githubql.Int
 	Title      githubql.String
+	Body       githubql.String
 	URL        githubql.String
 	Repository struct {
 		Name  githubql.String
@@ -17,10 +17,12 @@ limitations under the License.
 package blockers
 
 import (
+	"errors"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	githubql "github.com/shurcooL/githubv4"
 
@@ -430,3 +432,131 @@ func TestBlockers(t *testing.T) {
 		}
 	}
 }
+
+func TestParseBody(t *testing.T) {
+	expires := time.Date(2020, 3, 12, 0, 0, 0, 0, time.UTC)
+	tcs := []struct {
+		name             string
+		body             string
+		expectedBranches []string
+		expected         Blocker
+	}{
+		{
+			name: "no fields",
+			body: "The CI is broken, please hold merges.",
+		},
+		{
+			name:             "all fields",
+			body:             "The docs are being reorganised.\n\nbranches: master, release-1.2\npaths: docs/** *.md\nexpires: 2020-03-12T00:00:00Z\nexcept-labels: `hotfix`\n",
+			expectedBranches: []string{"master", "release-1.2"},
+			expected: Blocker{
+				Paths:        []string{"docs/**", "*.md"},
+				ExceptLabels: []string{"hotfix"},
+				Expires:      &expires,
+			},
+		},
+		{
+			name: "fields are only read at the start of lines",
+			body: "Please do not add paths: docs/** to this issue.\n  Paths: \"api/*\"",
+			expected: Blocker{
+				Paths: []string{"api/*"},
+			},
+		},
+		{
+			name: "invalid expiry ignored",
+			body: "expires: tomorrow",
+		},
+	}
+
+	for _, tc := range tcs {
+		var block Blocker
+		branches := parseBody(tc.body, &block, logrus.WithField("test", tc.name))
+		if !reflect.DeepEqual(branches, tc.expectedBranches) {
+			t.Errorf("%s: expected branches %q, but got %q.", tc.name, tc.expectedBranches, branches)
+		}
+		if !reflect.DeepEqual(block, tc.expected) {
+			t.Errorf("%s: expected blocker %+v, but got %+v.", tc.name, tc.expected, block)
+		}
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	files := func(names ...string) func() ([]string, error) {
+		return func() ([]string, error) {
+			return names, nil
+		}
+	}
+	tcs := []struct {
+		name         string
+		block        Blocker
+		labels       []string
+		changedFiles func() ([]string, error)
+		expected     bool
+	}{
+		{
+			name:     "unscoped blocker",
+			expected: true,
+		},
+		{
+			name:         "changed file under the blocked directory",
+			block:        Blocker{Paths: []string{"docs/**"}},
+			changedFiles: files("main.go", "docs/guide/intro.md"),
+			expected:     true,
+		},
+		{
+			name:         "no changed file matches",
+			block:        Blocker{Paths: []string{"docs/*.md", "api/?.go"}},
+			changedFiles: files("docs/guide/intro.md", "api/v1.go"),
+			expected:     false,
+		},
+		{
+			name:         "excepted label",
+			block:        Blocker{Paths: []string{"**"}, ExceptLabels: []string{"hotfix"}},
+			labels:       []string{"lgtm", "hotfix"},
+			changedFiles: files("main.go"),
+			expected:     false,
+		},
+		{
+			name:  "changed files cannot be listed",
+			block: Blocker{Paths: []string{"docs/**"}},
+			changedFiles: func() ([]string, error) {
+				return nil, errors.New("injected error")
+			},
+			expected: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		if got, _ := tc.block.AppliesTo(tc.labels, tc.changedFiles); got != tc.expected {
+			t.Errorf("%s: expected AppliesTo to return %t, but got %t.", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Date(2020, 3, 11, 10, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	expired := Blocker{Number: 1, Expires: &past}
+	active := Blocker{Number: 2, Expires: &future}
+	permanent := Blocker{Number: 3}
+	key := OrgRepo{Org: "k", Repo: "k"}
+
+	b := Blockers{
+		Repo: map[OrgRepo][]Blocker{key: {expired, permanent}},
+		Branch: map[OrgRepoBranch][]Blocker{
+			{Org: "k", Repo: "k", Branch: "master"}:    {expired, active},
+			{Org: "k", Repo: "k", Branch: "release-1"}: {expired},
+		},
+	}.expire(now)
+
+	nums := sets.NewInt()
+	for _, block := range b.GetApplicable("k", "k", "master") {
+		nums.Insert(block.Number)
+	}
+	if expected := sets.NewInt(2, 3); !reflect.DeepEqual(nums, expected) {
+		t.Errorf("expected blockers %v, but got %v", expected, nums)
+	}
+	if expected := []Blocker{expired}; !reflect.DeepEqual(b.Expired[key], expected) {
+		t.Errorf("expected expired blockers %+v, but got %+v", expected, b.Expired[key])
+	}
+}
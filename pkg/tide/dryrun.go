@@ -0,0 +1,133 @@
+package tide
+
+import (
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/jx/pkg/tekton/metapipeline"
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/sirupsen/logrus"
+)
+
+// dryRunPrefix is prepended to the actions recorded in the history when tide
+// runs in dry-run mode, e.g. DRY-MERGE or DRY-TRIGGER.
+const dryRunPrefix = "DRY-"
+
+// DryRunStatus is the history action recorded when tide would have updated the
+// status context of a PR in dry-run mode.
+const DryRunStatus = dryRunPrefix + "STATUS"
+
+// dryRunGitHubClient wraps a githubClient, passing read operations through
+// while logging and swallowing any operation that would mutate the git provider.
+type dryRunGitHubClient struct {
+	githubClient
+	logger *logrus.Entry
+}
+
+func newDryRunGitHubClient(ghc githubClient, logger *logrus.Entry) *dryRunGitHubClient {
+	return &dryRunGitHubClient{
+		githubClient: ghc,
+		logger:       logger.WithField("dry-run", true),
+	}
+}
+
+// CreateGraphQLStatus logs the status that would have been created.
+func (c *dryRunGitHubClient) CreateGraphQLStatus(org, repo, ref string, s *gitprovider.Status) (*scm.Status, error) {
+	c.logger.WithFields(logrus.Fields{
+		"org":         org,
+		"repo":        repo,
+		"ref":         ref,
+		"context":     s.Context,
+		"state":       s.State,
+		"description": s.Description,
+	}).Info("Dry run: not setting status.")
+	return &scm.Status{
+		State:  scm.ToState(s.State),
+		Label:  s.Context,
+		Desc:   s.Description,
+		Target: s.TargetURL,
+	}, nil
+}
+
+// CreateStatus logs the status that would have been created.
+func (c *dryRunGitHubClient) CreateStatus(org, repo, ref string, s *scm.StatusInput) (*scm.Status, error) {
+	c.logger.WithFields(logrus.Fields{
+		"org":         org,
+		"repo":        repo,
+		"ref":         ref,
+		"context":     s.Label,
+		"state":       s.State.String(),
+		"description": s.Desc,
+	}).Info("Dry run: not setting status.")
+	return scm.ConvertStatusInputToStatus(s), nil
+}
+
+// Merge logs the pull request that would have been merged.
+func (c *dryRunGitHubClient) Merge(org, repo string, number int, details gitprovider.MergeDetails) error {
+	c.logger.WithFields(logrus.Fields{
+		"org":          org,
+		"repo":         repo,
+		"number":       number,
+		"sha":          details.SHA,
+		"merge-method": details.MergeMethod,
+	}).Info("Dry run: not merging.")
+	return nil
+}
+
+// UpdatePullRequestBranch logs the pull request whose branch would have been updated.
+func (c *dryRunGitHubClient) UpdatePullRequestBranch(org, repo string, number int, expectedHeadSHA string) error {
+	c.logger.WithFields(logrus.Fields{
+		"org":    org,
+		"repo":   repo,
+		"number": number,
+		"sha":    expectedHeadSHA,
+	}).Info("Dry run: not updating the branch.")
+	return nil
+}
+
+// CreatePullRequest logs the pull request that would have been opened.
+func (c *dryRunGitHubClient) CreatePullRequest(org, repo, title, body, head, base string) (int, error) {
+	c.logger.WithFields(logrus.Fields{
+		"org":   org,
+		"repo":  repo,
+		"title": title,
+		"head":  head,
+		"base":  base,
+	}).Info("Dry run: not opening the pull request.")
+	return 0, nil
+}
+
+// dryRunProwJobClient wraps a prowJobClient, listing pipelines as usual but only
+// logging the pipelines that would have been created.
+type dryRunProwJobClient struct {
+	prowJobClient
+	logger *logrus.Entry
+}
+
+func newDryRunProwJobClient(pjc prowJobClient, logger *logrus.Entry) *dryRunProwJobClient {
+	return &dryRunProwJobClient{
+		prowJobClient: pjc,
+		logger:        logger.WithField("dry-run", true),
+	}
+}
+
+// Create logs the pipeline that would have been created.
+func (c *dryRunProwJobClient) Create(po *plumber.PipelineOptions, mpClient metapipeline.Client, repo scm.Repository) (*plumber.PipelineOptions, error) {
+	fields := logrus.Fields{
+		"job":     po.Spec.Job,
+		"type":    po.Spec.Type,
+		"context": po.Spec.Context,
+	}
+	if refs := po.Spec.Refs; refs != nil {
+		fields["org"] = refs.Org
+		fields["repo"] = refs.Repo
+		fields["base-ref"] = refs.BaseRef
+		fields["base-sha"] = refs.BaseSHA
+		var prs []int
+		for _, pull := range refs.Pulls {
+			prs = append(prs, pull.Number)
+		}
+		fields["prs"] = prs
+	}
+	c.logger.WithFields(fields).Info("Dry run: not triggering pipeline.")
+	return po, nil
+}
@@ -0,0 +1,125 @@
+package tide
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/jenkins-x/lighthouse/pkg/plumber/fake"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	github "github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/jenkins-x/lighthouse/pkg/tide/history"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	tektonfake "github.com/tektoncd/pipeline/pkg/client/clientset/versioned/fake"
+)
+
+func TestDryRunClientsDoNotMutate(t *testing.T) {
+	log := logrus.WithField("test", "dry-run")
+	fgc := &fgc{}
+	ghc := newDryRunGitHubClient(fgc, log)
+
+	err := ghc.Merge("org", "repo", 1, github.MergeDetails{SHA: "abc"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, fgc.merged, "merged PRs")
+
+	_, err = ghc.CreatePullRequest("org", "repo", "title", "body", "head", "master")
+	require.NoError(t, err)
+	assert.Empty(t, fgc.opened, "opened PRs")
+
+	_, err = ghc.CreateGraphQLStatus("org", "repo", "abc", &github.Status{Context: statusContext, State: github.StatusPending})
+	require.NoError(t, err)
+	assert.False(t, fgc.setStatus, "status set")
+
+	fakePlumberClient := fake.NewPlumber()
+	pjc := newDryRunProwJobClient(fakePlumberClient, log)
+	_, err = pjc.Create(&plumber.PipelineOptions{Spec: plumber.PipelineOptionsSpec{Job: "job", Refs: &plumber.Refs{Org: "org", Repo: "repo"}}}, nil, scm.Repository{})
+	require.NoError(t, err)
+	assert.Empty(t, fakePlumberClient.Pipelines, "created pipelines")
+}
+
+func TestSyncDryRun(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	pr := testPR("org", "repo", "A", 5, githubql.MergeableStateMergeable)
+	fgc := &fgc{prs: []PullRequest{pr}}
+	fakePlumberClient := fake.NewPlumber()
+	log := logrus.WithField("test", "dry-run")
+	ca := &config.Agent{}
+	ca.Set(&config.Config{
+		ProwConfig: config.ProwConfig{
+			Tide: config.Tide{
+				Queries:            []config.TideQuery{{}},
+				MaxGoroutines:      4,
+				StatusUpdatePeriod: time.Second * 0,
+			},
+		},
+	})
+	hist, err := history.New(100, nil, "")
+	require.NoError(t, err)
+	ghc := newDryRunGitHubClient(fgc, log)
+	sc := &statusController{
+		logger:         log.WithField("controller", "status-update"),
+		ghc:            ghc,
+		config:         ca.Config,
+		newPoolPending: make(chan bool, 1),
+		shutDown:       make(chan bool),
+		dryRunHistory:  hist,
+		dryRunStatuses: map[string]string{},
+	}
+	go sc.run()
+	defer sc.shutdown()
+	c := &DefaultController{
+		config:        ca.Config,
+		ghc:           ghc,
+		prowJobClient: newDryRunProwJobClient(fakePlumberClient, log),
+		tektonClient:  tektonfake.NewSimpleClientset(),
+		ns:            "jx",
+		dryRun:        true,
+		logger:        log.WithField("controller", "sync"),
+		sc:            sc,
+		changedFiles: &changedFilesAgent{
+			ghc:             ghc,
+			nextChangeCache: make(map[changeCacheKey][]string),
+		},
+		History: hist,
+	}
+
+	require.NoError(t, c.Sync())
+	assert.Equal(t, 0, fgc.merged, "merged PRs")
+	require.Len(t, c.pools, 1)
+	assert.Equal(t, Action(Merge), c.pools[0].Action)
+
+	records := hist.AllRecords()[poolKey("org", "repo", "A")]
+	require.NotEmpty(t, records)
+	assert.Equal(t, dryRunPrefix+Merge, records[0].Action)
+	require.Len(t, records[0].Target, 1)
+	assert.Equal(t, 5, records[0].Target[0].Number)
+}
+
+func TestRecordDryRunStatus(t *testing.T) {
+	hist, err := history.New(100, nil, "")
+	require.NoError(t, err)
+	sc := &statusController{
+		dryRunHistory:  hist,
+		dryRunStatuses: map[string]string{},
+	}
+	pr := testPR("org", "repo", "A", 5, githubql.MergeableStateMergeable)
+
+	sc.recordDryRunStatus(&pr, github.StatusPending, statusInPool)
+	sc.recordDryRunStatus(&pr, github.StatusPending, statusInPool)
+	records := hist.AllRecords()[poolKey("org", "repo", "A")]
+	require.Len(t, records, 1, "repeated status updates should only be recorded once")
+	assert.Equal(t, DryRunStatus, records[0].Action)
+
+	sc.recordDryRunStatus(&pr, github.StatusSuccess, statusInPool)
+	records = hist.AllRecords()[poolKey("org", "repo", "A")]
+	assert.Len(t, records, 2)
+
+	sc = &statusController{}
+	sc.recordDryRunStatus(&pr, github.StatusSuccess, statusInPool)
+}
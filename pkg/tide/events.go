@@ -0,0 +1,295 @@
+package tide
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/tide/blockers"
+	"github.com/pkg/errors"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SyncPath is the path of the tide endpoint which is notified of the events affecting a pool
+	SyncPath = "/sync"
+
+	// SyncSignatureHeader is the header of the HMAC-SHA256 signature of the sync requests,
+	// keyed with the webhook HMAC secret shared by lighthouse and tide
+	SyncSignatureHeader = "X-Lighthouse-Signature"
+
+	// DefaultSyncDebounce is how long tide waits for more events on a pool before syncing it
+	DefaultSyncDebounce = 5 * time.Second
+)
+
+// SyncRequest asks tide to sync the pools of a repository, or of a single branch of it
+// if Branch is not empty, without waiting for the next sync period.
+type SyncRequest struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// SyncPool syncs the subpools of a repository, or of a single branch of it if branch
+// is not empty. Only the PRs of the repository are searched, and the other pools are
+// left as they were after the last sync.
+func (c *DefaultController) SyncPool(org, repo, branch string) error {
+	c.syncLock.Lock()
+	defer c.syncLock.Unlock()
+
+	queries := c.config().Tide.Queries.QueryMap().ForRepo(org, repo)
+	if len(queries) == 0 {
+		return nil
+	}
+	start := time.Now()
+	log := c.logger.WithFields(logrus.Fields{"org": org, "repo": repo, "branch": branch})
+	defer func() {
+		log.WithField("duration", time.Since(start).String()).Info("Synced pool")
+	}()
+
+	prs := make(map[string]PullRequest)
+	for _, query := range queries {
+		tq, ok := narrowQuery(query, org, repo, branch)
+		if !ok {
+			continue
+		}
+		q := tq.Query()
+		results, err := search(c.ghc.Query, log, q, time.Time{}, time.Now())
+		if err != nil && len(results) == 0 {
+			return fmt.Errorf("query %q, err: %v", q, err)
+		}
+		if err != nil {
+			log.WithError(err).WithField("query", q).Warning("found partial results")
+		}
+		for _, pr := range results {
+			prs[prKey(&pr)] = pr
+		}
+	}
+
+	var pjs []plumber.PipelineOptions
+	var blocks blockers.Blockers
+	if len(prs) > 0 {
+		var err error
+		pjs, err = c.listRepoPipelines(org, repo)
+		if err != nil {
+			return err
+		}
+
+		if label := c.config().Tide.BlockerLabel; label != "" {
+			blocks, err = blockers.FindAll(c.ghc, log, label, orgRepoQueryString(nil, []string{org + "/" + repo}, nil))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	rawPools, err := c.dividePool(prs, pjs)
+	if err != nil {
+		return err
+	}
+	filteredPools := c.filterSubpools(c.config().Tide.MaxGoroutines, rawPools)
+
+	matches := func(o, r, b string) bool {
+		return o == org && r == repo && (branch == "" || b == branch)
+	}
+
+	c.sc.Lock()
+	for key, pr := range c.sc.poolPRs {
+		if matches(string(pr.Repository.Owner.Login), string(pr.Repository.Name), string(pr.BaseRef.Name)) {
+			delete(c.sc.poolPRs, key)
+		}
+	}
+	if c.sc.poolPRs == nil {
+		c.sc.poolPRs = make(map[string]PullRequest)
+	}
+	for key, pr := range poolPRMap(filteredPools) {
+		c.sc.poolPRs[key] = pr
+	}
+	select {
+	case c.sc.newPoolPending <- true:
+	default:
+	}
+	c.sc.Unlock()
+
+	var synced []Pool
+	for _, sp := range filteredPools {
+		pool, err := c.syncSubpool(*sp, blocks.GetApplicable(sp.org, sp.repo, sp.branch))
+		if err != nil {
+			sp.log.WithError(err).Errorf("Error syncing subpool.")
+		}
+		synced = append(synced, pool)
+	}
+
+	c.m.Lock()
+	pools := synced
+	for _, pool := range c.pools {
+		if !matches(pool.Org, pool.Repo, pool.Branch) {
+			pools = append(pools, pool)
+		}
+	}
+	sortPools(pools)
+	c.pools = pools
+	c.m.Unlock()
+
+	c.History.Flush()
+	return nil
+}
+
+// narrowQuery restricts a tide query to a repository and optionally a branch. It
+// returns false if the query excludes the branch.
+func narrowQuery(query config.TideQuery, org, repo, branch string) (config.TideQuery, bool) {
+	query.Orgs = nil
+	query.ExcludedRepos = nil
+	query.Repos = []string{org + "/" + repo}
+	if branch == "" {
+		return query, true
+	}
+	pr := &PullRequest{}
+	pr.BaseRef.Name = githubql.String(branch)
+	if branchForbidden(pr, &query) {
+		return query, false
+	}
+	query.ExcludedBranches = nil
+	query.IncludedBranches = []string{branch}
+	return query, true
+}
+
+// SyncHandler serves SyncPath, syncing the pools which events were received for.
+// The events received for a pool within the debounce period are coalesced into a
+// single sync at the end of the period. Only the requests signed with the secret
+// are accepted.
+type SyncHandler struct {
+	c        Controller
+	secret   []byte
+	debounce time.Duration
+	logger   *logrus.Entry
+
+	// afterFunc schedules the syncs, it is replaced in tests
+	afterFunc func(time.Duration, func()) *time.Timer
+
+	lock    sync.Mutex
+	pending map[string]bool
+}
+
+// NewSyncHandler creates a handler syncing the pools of the controller on request.
+// All the requests are refused if the secret is empty.
+func NewSyncHandler(c Controller, secret []byte, debounce time.Duration, logger *logrus.Entry) *SyncHandler {
+	return &SyncHandler{
+		c:         c,
+		secret:    secret,
+		debounce:  debounce,
+		logger:    logger,
+		afterFunc: time.AfterFunc,
+		pending:   make(map[string]bool),
+	}
+}
+
+// ServeHTTP accepts a JSON SyncRequest posted to SyncPath
+func (h *SyncHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSyncRequestSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid sync request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(h.secret) == 0 || !hmac.Equal([]byte(r.Header.Get(SyncSignatureHeader)), []byte(signSyncRequest(h.secret, body))) {
+		http.Error(w, "the signature of the sync request is invalid", http.StatusUnauthorized)
+		return
+	}
+	req := SyncRequest{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid sync request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Org == "" || req.Repo == "" {
+		http.Error(w, "the org and repo of the sync request are required", http.StatusBadRequest)
+		return
+	}
+	h.Schedule(req.Org, req.Repo, req.Branch)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Schedule syncs the pools of the repository, or of the branch if not empty, once the
+// debounce period has elapsed. It does nothing if a sync of the same pools is pending.
+func (h *SyncHandler) Schedule(org, repo, branch string) {
+	key := poolKey(org, repo, branch)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.pending[key] {
+		return
+	}
+	h.pending[key] = true
+	h.afterFunc(h.debounce, func() {
+		// events received while syncing schedule another sync
+		h.lock.Lock()
+		delete(h.pending, key)
+		h.lock.Unlock()
+
+		if err := h.c.SyncPool(org, repo, branch); err != nil {
+			h.logger.WithError(err).WithField("pool", key).Error("Error syncing pool.")
+		}
+	})
+}
+
+// maxSyncRequestSize is the size above which sync requests are refused
+const maxSyncRequestSize = 64 * 1024
+
+// signSyncRequest returns the signature of the body of a sync request
+func signSyncRequest(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SyncClient notifies tide of the events affecting its pools
+type SyncClient struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewSyncClient creates a client for the tide server at the URL, signing its requests
+// with the secret
+func NewSyncClient(tideURL string, secret []byte) *SyncClient {
+	return &SyncClient{
+		url:    strings.TrimSuffix(tideURL, "/") + SyncPath,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify asks tide to sync the pools of the repository, or of the branch if not empty
+func (c *SyncClient) Notify(org, repo, branch string) error {
+	body, err := json.Marshal(SyncRequest{Org: org, Repo: repo, Branch: branch})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SyncSignatureHeader, signSyncRequest(c.secret, body))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to notify tide at %s", c.url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return errors.Errorf("tide at %s responded %s", c.url, resp.Status)
+	}
+	return nil
+}
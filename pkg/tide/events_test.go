@@ -0,0 +1,134 @@
+package tide
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/tide/history"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSyncController struct {
+	sync.Mutex
+	synced []SyncRequest
+}
+
+func (f *fakeSyncController) Sync() error { return nil }
+
+func (f *fakeSyncController) SyncPool(org, repo, branch string) error {
+	f.Lock()
+	defer f.Unlock()
+	f.synced = append(f.synced, SyncRequest{Org: org, Repo: repo, Branch: branch})
+	return nil
+}
+
+func (f *fakeSyncController) Shutdown() {}
+
+func (f *fakeSyncController) GetPools() []Pool { return nil }
+
+func (f *fakeSyncController) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func (f *fakeSyncController) GetHistory() *history.History { return nil }
+
+func (f *fakeSyncController) GetPRStatuses(q PRStatusQuery) ([]PRStatus, error) { return nil, nil }
+
+func TestSyncHandlerDebounce(t *testing.T) {
+	c := &fakeSyncController{}
+	h := NewSyncHandler(c, []byte("secret"), time.Minute, logrus.WithField("handler", "sync"))
+	var scheduled []func()
+	h.afterFunc = func(d time.Duration, f func()) *time.Timer {
+		assert.Equal(t, time.Minute, d)
+		scheduled = append(scheduled, f)
+		return nil
+	}
+
+	h.Schedule("org", "repo", "master")
+	h.Schedule("org", "repo", "master")
+	h.Schedule("org", "repo", "")
+	assert.Len(t, scheduled, 2, "events on a pool pending a sync should be coalesced")
+
+	scheduled[0]()
+	assert.Equal(t, []SyncRequest{{Org: "org", Repo: "repo", Branch: "master"}}, c.synced)
+
+	h.Schedule("org", "repo", "master")
+	assert.Len(t, scheduled, 3, "events after a sync should schedule another one")
+}
+
+func TestSyncHandlerAndClient(t *testing.T) {
+	c := &fakeSyncController{}
+	h := NewSyncHandler(c, []byte("secret"), time.Minute, logrus.WithField("handler", "sync"))
+	var scheduled []func()
+	h.afterFunc = func(d time.Duration, f func()) *time.Timer {
+		scheduled = append(scheduled, f)
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle(SyncPath, h)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewSyncClient(server.URL+"/", []byte("secret"))
+	assert.NoError(t, client.Notify("org", "repo", "master"))
+	assert.Error(t, client.Notify("", "repo", "master"))
+	assert.Error(t, NewSyncClient(server.URL, []byte("other")).Notify("org", "repo", "master"), "requests signed with another secret should be refused")
+	assert.Len(t, scheduled, 1)
+	scheduled[0]()
+	assert.Equal(t, []SyncRequest{{Org: "org", Repo: "repo", Branch: "master"}}, c.synced)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+SyncPath, strings.NewReader("{"))
+	assert.NoError(t, err)
+	req.Header.Set(SyncSignatureHeader, signSyncRequest([]byte("secret"), []byte("{")))
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = http.Post(server.URL+SyncPath, "application/json", strings.NewReader(`{"org":"org","repo":"repo"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "unsigned requests should be refused")
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + SyncPath)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestSyncHandlerWithoutSecret(t *testing.T) {
+	h := NewSyncHandler(&fakeSyncController{}, nil, time.Minute, logrus.WithField("handler", "sync"))
+	h.afterFunc = func(d time.Duration, f func()) *time.Timer {
+		t.Errorf("no sync should be scheduled")
+		return nil
+	}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	assert.Error(t, NewSyncClient(server.URL, nil).Notify("org", "repo", "master"))
+}
+
+func TestNarrowQuery(t *testing.T) {
+	query := config.TideQuery{
+		Orgs:             []string{"org"},
+		ExcludedRepos:    []string{"org/other"},
+		ExcludedBranches: []string{"wip"},
+		Labels:           []string{"approved"},
+	}
+
+	narrowed, ok := narrowQuery(query, "org", "repo", "")
+	assert.True(t, ok)
+	assert.Equal(t, `is:pr state:open repo:"org/repo" -base:"wip" label:"approved"`, narrowed.Query())
+
+	narrowed, ok = narrowQuery(query, "org", "repo", "master")
+	assert.True(t, ok)
+	assert.Equal(t, `is:pr state:open repo:"org/repo" base:"master" label:"approved"`, narrowed.Query())
+
+	_, ok = narrowQuery(query, "org", "repo", "wip")
+	assert.False(t, ok)
+	assert.Equal(t, []string{"org"}, query.Orgs, "the original query should not be changed")
+}
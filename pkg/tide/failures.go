@@ -0,0 +1,315 @@
+package tide
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// mergeFailureMarker identifies the comments tide posts on the PRs it fails to merge
+const mergeFailureMarker = "<!-- tide: merge failure -->"
+
+// mergeFailureClient is the subset of gitprovider.Client methods used to comment on merge failures
+type mergeFailureClient interface {
+	BotName() (string, error)
+	CreateComment(owner, repo string, number int, pr bool, comment string) error
+	DeleteStaleComments(org, repo string, number int, comments []*scm.Comment, pr bool, isStale func(*scm.Comment) bool) error
+	ListPullRequestComments(owner, repo string, number int) ([]*scm.Comment, error)
+	ListCollaborators(owner, repo string) ([]scm.User, error)
+	GetUserPermission(org, repo, user string) (string, error)
+}
+
+// MergeFailure describes a PR tide failed to merge. It is posted as JSON to the
+// merge failure webhook.
+type MergeFailure struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Number int    `json:"number"`
+	URL    string `json:"url,omitempty"`
+	Error  string `json:"error"`
+	// Explanation tells what caused the failure and how to fix it.
+	Explanation string `json:"explanation"`
+	// ConfigError is true if the repository or tide configuration has to be fixed
+	// by an admin rather than the PR by its author.
+	ConfigError bool `json:"configError"`
+}
+
+// adminsCachePeriod is how long the admins of a repository are cached
+const adminsCachePeriod = 6 * time.Hour
+
+// mergeFailureState is what the merge failure notifier remembers across controllers.
+// When PRs were last commented on is read from their comments instead.
+type mergeFailureState struct {
+	lock sync.Mutex
+	// notified holds when the webhook was last notified of each PR, keyed by prKey
+	notified map[string]time.Time
+	// admins caches the admins of each repository, keyed by org/repo
+	admins map[string]cachedAdmins
+}
+
+type cachedAdmins struct {
+	logins []string
+	listed time.Time
+}
+
+func newMergeFailureState() *mergeFailureState {
+	return &mergeFailureState{
+		notified: make(map[string]time.Time),
+		admins:   make(map[string]cachedAdmins),
+	}
+}
+
+// mergeFailureNotifier comments on the PRs tide fails to merge and notifies the
+// merge failure webhook.
+type mergeFailureNotifier struct {
+	ghc        mergeFailureClient
+	config     config.Getter
+	logger     *logrus.Entry
+	dryRun     bool
+	httpClient *http.Client
+	now        func() time.Time
+	state      *mergeFailureState
+}
+
+func newMergeFailureNotifier(ghc mergeFailureClient, cfg config.Getter, logger *logrus.Entry, dryRun bool, state *mergeFailureState) *mergeFailureNotifier {
+	return &mergeFailureNotifier{
+		ghc:        ghc,
+		config:     cfg,
+		logger:     logger.WithField("component", "merge-failures"),
+		dryRun:     dryRun,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+		state:      state,
+	}
+}
+
+// explainMergeFailure tells what caused the merge error and whether an admin has
+// to fix the configuration.
+func explainMergeFailure(err error) (string, bool) {
+	switch errors.Cause(err).(type) {
+	case gitprovider.MergeCommitsForbiddenError:
+		return "The repository does not allow the merge method tide uses. A repository admin needs to allow it in the repository settings, or change the tide `merge_method` of the repository.", true
+	case gitprovider.UnauthorizedToPushError:
+		return "The bot is not allowed to push to the base branch. A repository admin needs to allow it in the branch protection settings.", true
+	case gitprovider.ModifiedHeadError:
+		return "The pull request was modified while tide was merging it. It will be merged once it passes its tests again.", false
+	case gitprovider.UnmergablePRBaseChangedError:
+		return "The base branch changed while tide was merging the pull request. Tide will try again.", false
+	case gitprovider.UnmergablePRError:
+		return "The git provider refused to merge the pull request. It may conflict with the base branch, or the requirements of the branch protection may differ from the tide requirements. Rebasing the pull request usually fixes it.", false
+	}
+	return "Tide could not merge the pull request. Check the error below and fix the pull request, e.g. by rebasing it.", false
+}
+
+// transientMergeFailure returns true if the merge error goes away by itself as tide
+// merges the PR again, so that there is nothing to comment on.
+func transientMergeFailure(err error) bool {
+	switch errors.Cause(err).(type) {
+	case gitprovider.ModifiedHeadError, gitprovider.UnmergablePRBaseChangedError:
+		return true
+	}
+	return false
+}
+
+// mergeFailed reports that the PR could not be merged. The webhook is notified and
+// the PR commented on at most once per comment period, and transient failures are
+// not commented on. A PR whose merge failure comment is more recent than the period
+// is not reported again, whichever controller posted it.
+func (n *mergeFailureNotifier) mergeFailed(sp subpool, pr PullRequest, mergeErr error) {
+	if n == nil {
+		return
+	}
+	cfg := n.config().Tide.MergeFailures
+	explanation, configError := explainMergeFailure(mergeErr)
+	failure := MergeFailure{
+		Org:         sp.org,
+		Repo:        sp.repo,
+		Branch:      sp.branch,
+		Number:      int(pr.Number),
+		URL:         string(pr.Repository.URL) + fmt.Sprintf("/pull/%d", int(pr.Number)),
+		Error:       mergeErr.Error(),
+		Explanation: explanation,
+		ConfigError: configError,
+	}
+	log := n.logger.WithFields(pr.logFields())
+	if n.dryRun {
+		if cfg.Comment || cfg.WebhookURL != "" {
+			log.WithField("error", failure.Error).Info("Dry run: not reporting the merge failure.")
+		}
+		return
+	}
+
+	var comments []*scm.Comment
+	if cfg.Comment {
+		var err error
+		comments, err = n.failureComments(sp.org, sp.repo, failure.Number)
+		if err != nil {
+			log.WithError(err).Warn("Failed to list the merge failure comments.")
+			return
+		}
+		for _, comment := range comments {
+			if n.now().Sub(comment.Created) < cfg.CommentPeriod {
+				return
+			}
+		}
+	}
+
+	if cfg.WebhookURL != "" && n.allowNotify(prKey(&pr), cfg.CommentPeriod) {
+		if err := n.notify(cfg.WebhookURL, failure); err != nil {
+			log.WithError(err).Warn("Failed to notify the merge failure webhook.")
+		}
+	}
+	if !cfg.Comment || transientMergeFailure(mergeErr) {
+		return
+	}
+
+	var admins []string
+	if configError {
+		admins = n.admins(sp.org, sp.repo)
+	}
+	if len(comments) > 0 {
+		if err := n.ghc.DeleteStaleComments(sp.org, sp.repo, failure.Number, comments, true, func(*scm.Comment) bool { return true }); err != nil {
+			log.WithError(err).Warn("Failed to delete the previous merge failure comments.")
+		}
+	}
+	if err := n.ghc.CreateComment(sp.org, sp.repo, failure.Number, true, mergeFailureComment(failure, admins)); err != nil {
+		log.WithError(err).Warn("Failed to comment on the merge failure.")
+	}
+}
+
+// merged removes the merge failure comment of a PR once it merges.
+func (n *mergeFailureNotifier) merged(sp subpool, pr PullRequest) {
+	if n == nil {
+		return
+	}
+	n.state.lock.Lock()
+	delete(n.state.notified, prKey(&pr))
+	n.state.lock.Unlock()
+	if n.dryRun || !n.config().Tide.MergeFailures.Comment {
+		return
+	}
+	log := n.logger.WithFields(pr.logFields())
+	comments, err := n.failureComments(sp.org, sp.repo, int(pr.Number))
+	if err != nil {
+		log.WithError(err).Warn("Failed to list the merge failure comments.")
+		return
+	}
+	if len(comments) == 0 {
+		return
+	}
+	if err := n.ghc.DeleteStaleComments(sp.org, sp.repo, int(pr.Number), comments, true, func(*scm.Comment) bool { return true }); err != nil {
+		log.WithError(err).Warn("Failed to delete the merge failure comments.")
+	}
+}
+
+// allowNotify records that the webhook is notified of the PR now and returns true,
+// unless it was already notified within the period.
+func (n *mergeFailureNotifier) allowNotify(key string, period time.Duration) bool {
+	n.state.lock.Lock()
+	defer n.state.lock.Unlock()
+	if last, ok := n.state.notified[key]; ok && n.now().Sub(last) < period {
+		return false
+	}
+	n.state.notified[key] = n.now()
+	return true
+}
+
+// failureComments returns the merge failure comments the bot posted on the PR.
+func (n *mergeFailureNotifier) failureComments(org, repo string, number int) ([]*scm.Comment, error) {
+	botName, err := n.ghc.BotName()
+	if err != nil {
+		return nil, err
+	}
+	comments, err := n.ghc.ListPullRequestComments(org, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	var failures []*scm.Comment
+	for _, c := range comments {
+		if c.Author.Login == botName && strings.Contains(c.Body, mergeFailureMarker) {
+			failures = append(failures, c)
+		}
+	}
+	return failures, nil
+}
+
+// admins returns the logins of the admins of the repository, which are cached for
+// adminsCachePeriod.
+func (n *mergeFailureNotifier) admins(org, repo string) []string {
+	key := org + "/" + repo
+	n.state.lock.Lock()
+	cached, ok := n.state.admins[key]
+	n.state.lock.Unlock()
+	if ok && n.now().Sub(cached.listed) < adminsCachePeriod {
+		return cached.logins
+	}
+
+	users, err := n.ghc.ListCollaborators(org, repo)
+	if err != nil {
+		n.logger.WithError(err).Warnf("Failed to list the collaborators of %s/%s.", org, repo)
+		return nil
+	}
+	var admins []string
+	for _, user := range users {
+		perm, err := n.ghc.GetUserPermission(org, repo, user.Login)
+		if err != nil {
+			n.logger.WithError(err).Warnf("Failed to get the permission of %s on %s/%s.", user.Login, org, repo)
+			continue
+		}
+		if perm == "admin" {
+			admins = append(admins, user.Login)
+		}
+	}
+	sort.Strings(admins)
+	n.state.lock.Lock()
+	n.state.admins[key] = cachedAdmins{logins: admins, listed: n.now()}
+	n.state.lock.Unlock()
+	return admins
+}
+
+func (n *mergeFailureNotifier) notify(url string, failure MergeFailure) error {
+	body, err := json.Marshal(failure)
+	if err != nil {
+		return err
+	}
+	resp, err := n.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("%s responded %s", url, resp.Status)
+	}
+	return nil
+}
+
+// mergeFailureComment formats the comment explaining a merge failure, mentioning
+// the admins if the configuration has to be fixed.
+func mergeFailureComment(failure MergeFailure, admins []string) string {
+	var b strings.Builder
+	b.WriteString(mergeFailureMarker + "\n")
+	b.WriteString("Tide failed to merge this pull request. " + failure.Explanation + "\n")
+	if len(admins) > 0 {
+		// the admins are mentioned in prose as a line starting with /cc would
+		// request their review through the assign plugin
+		mentions := make([]string, 0, len(admins))
+		for _, admin := range admins {
+			mentions = append(mentions, "@"+admin)
+		}
+		b.WriteString("\nThe configuration can be fixed by the repository admins: " + strings.Join(mentions, ", ") + ".\n")
+	}
+	b.WriteString("\n<details>\n<summary>Error</summary>\n\n```\n" + failure.Error + "\n```\n</details>\n")
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package tide
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFailureClient struct {
+	comments    []*scm.Comment
+	nextID      int
+	permissions map[string]string
+	now         func() time.Time
+	// permissionCalls counts the calls to GetUserPermission
+	permissionCalls int
+}
+
+func (f *fakeFailureClient) BotName() (string, error) {
+	return "bot", nil
+}
+
+func (f *fakeFailureClient) CreateComment(owner, repo string, number int, pr bool, comment string) error {
+	f.nextID++
+	f.comments = append(f.comments, &scm.Comment{ID: f.nextID, Body: comment, Author: scm.User{Login: "bot"}, Created: f.now()})
+	return nil
+}
+
+func (f *fakeFailureClient) ListPullRequestComments(owner, repo string, number int) ([]*scm.Comment, error) {
+	return f.comments, nil
+}
+
+func (f *fakeFailureClient) DeleteStaleComments(org, repo string, number int, comments []*scm.Comment, pr bool, isStale func(*scm.Comment) bool) error {
+	stale := map[int]bool{}
+	for _, c := range comments {
+		stale[c.ID] = isStale(c)
+	}
+	var kept []*scm.Comment
+	for _, c := range f.comments {
+		if !stale[c.ID] {
+			kept = append(kept, c)
+		}
+	}
+	f.comments = kept
+	return nil
+}
+
+func (f *fakeFailureClient) ListCollaborators(owner, repo string) ([]scm.User, error) {
+	var users []scm.User
+	for login := range f.permissions {
+		users = append(users, scm.User{Login: login})
+	}
+	return users, nil
+}
+
+func (f *fakeFailureClient) GetUserPermission(org, repo, user string) (string, error) {
+	f.permissionCalls++
+	return f.permissions[user], nil
+}
+
+func TestMergeFailureNotifier(t *testing.T) {
+	var notified []MergeFailure
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failure := MergeFailure{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&failure))
+		notified = append(notified, failure)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		ProwConfig: config.ProwConfig{
+			Tide: config.Tide{
+				MergeFailures: config.TideMergeFailures{
+					Comment:       true,
+					CommentPeriod: time.Hour,
+					WebhookURL:    server.URL,
+				},
+			},
+		},
+	}
+	now := time.Date(2020, 3, 11, 10, 0, 0, 0, time.UTC)
+	ghc := &fakeFailureClient{
+		comments:    []*scm.Comment{{ID: 100, Body: "lgtm", Author: scm.User{Login: "someone"}}},
+		nextID:      100,
+		permissions: map[string]string{"boss": "admin", "dev": "write"},
+		now:         func() time.Time { return now },
+	}
+	state := newMergeFailureState()
+	// notifiers are recreated with the controllers, keeping the state
+	newNotifier := func() *mergeFailureNotifier {
+		n := newMergeFailureNotifier(ghc, func() *config.Config { return cfg }, logrus.WithField("component", "tide"), false, state)
+		n.now = func() time.Time { return now }
+		return n
+	}
+	n := newNotifier()
+
+	sp := subpool{org: "org", repo: "repo", branch: "master"}
+	pr := testPR("org", "repo", "master", 5, githubql.MergeableStateMergeable)
+
+	n.mergeFailed(sp, pr, gitprovider.UnmergablePRError("conflict"))
+	require.Len(t, ghc.comments, 2)
+	assert.Contains(t, ghc.comments[1].Body, mergeFailureMarker)
+	assert.Contains(t, ghc.comments[1].Body, "Rebasing the pull request")
+	assert.NotContains(t, ghc.comments[1].Body, "@boss")
+	require.Len(t, notified, 1)
+	assert.Equal(t, 5, notified[0].Number)
+	assert.False(t, notified[0].ConfigError)
+
+	// comments and notifications are rate limited from the existing comment, even
+	// by another notifier
+	newNotifier().mergeFailed(sp, pr, gitprovider.MergeCommitsForbiddenError("forbidden"))
+	assert.Len(t, ghc.comments, 2)
+	assert.Len(t, notified, 1)
+
+	// the comment is replaced once the period elapses, mentioning the admins
+	now = now.Add(2 * time.Hour)
+	n.mergeFailed(sp, pr, gitprovider.MergeCommitsForbiddenError("forbidden"))
+	require.Len(t, ghc.comments, 2)
+	assert.Equal(t, 102, ghc.comments[1].ID)
+	assert.Contains(t, ghc.comments[1].Body, "repository admins: @boss.")
+	assert.Equal(t, 2, ghc.permissionCalls)
+	for _, line := range strings.Split(ghc.comments[1].Body, "\n") {
+		assert.False(t, strings.HasPrefix(line, "/"), "the comment should not contain commands: %q", line)
+	}
+	require.Len(t, notified, 2)
+	assert.True(t, notified[1].ConfigError)
+
+	// the admins are cached
+	now = now.Add(2 * time.Hour)
+	n.mergeFailed(sp, pr, gitprovider.MergeCommitsForbiddenError("forbidden"))
+	require.Len(t, ghc.comments, 2)
+	assert.Equal(t, 103, ghc.comments[1].ID)
+	assert.Equal(t, 2, ghc.permissionCalls)
+	require.Len(t, notified, 3)
+
+	// the comment is removed once the PR merges, even by another notifier
+	newNotifier().merged(sp, pr)
+	require.Len(t, ghc.comments, 1)
+	assert.Equal(t, "lgtm", ghc.comments[0].Body)
+
+	// transient failures are notified but not commented on
+	n.mergeFailed(sp, testPR("org", "repo", "master", 6, githubql.MergeableStateMergeable), gitprovider.ModifiedHeadError("modified"))
+	n.mergeFailed(sp, testPR("org", "repo", "master", 7, githubql.MergeableStateMergeable), gitprovider.UnmergablePRBaseChangedError("base changed"))
+	assert.Len(t, ghc.comments, 1)
+	assert.Len(t, notified, 5)
+
+	// nothing is reported in dry-run mode
+	dryRun := newMergeFailureNotifier(ghc, func() *config.Config { return cfg }, logrus.WithField("component", "tide"), true, state)
+	dryRun.mergeFailed(sp, pr, gitprovider.UnmergablePRError("conflict"))
+	assert.Len(t, ghc.comments, 1)
+	assert.Len(t, notified, 5)
+}
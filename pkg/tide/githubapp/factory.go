@@ -16,11 +16,13 @@ import (
 )
 
 // NewTideController creates a new controller; either regular or a GitHub App flavour
-// depending on the $GITHUB_APP_SECRET_DIR environment variable
-func NewTideController(configAgent *config.Agent, botName string, gitKind string, gitToken string, serverURL string, maxRecordsPerPool int, opener io.Opener, historyURI string, statusURI string) (tide.Controller, error) {
+// depending on the $GITHUB_APP_SECRET_DIR environment variable. In dry-run mode the
+// controller only logs and records the merges, triggers and status updates it would make.
+// The pipelines are listed from the activity cache once it has synced, if one is given.
+func NewTideController(configAgent *config.Agent, botName string, gitKind string, gitToken string, serverURL string, maxRecordsPerPool int, opener io.Opener, historyURI string, statusURI string, dryRun bool, activityCache *plumber.ActivityCache) (tide.Controller, error) {
 	githubAppSecretDir := os.Getenv("GITHUB_APP_SECRET_DIR")
 	if githubAppSecretDir != "" {
-		return NewGitHubAppTideController(githubAppSecretDir, configAgent, botName, gitKind, maxRecordsPerPool, opener, historyURI, statusURI)
+		return NewGitHubAppTideController(githubAppSecretDir, configAgent, botName, gitKind, maxRecordsPerPool, opener, historyURI, statusURI, dryRun, activityCache)
 	}
 
 	scmClient, err := factory.NewClientFromEnvironment()
@@ -40,7 +42,7 @@ func NewTideController(configAgent *config.Agent, botName string, gitKind string
 	if err != nil {
 		return nil, errors.Wrap(err, "Error creating kubernetes resource clients.")
 	}
-	plumberClient, err := plumber.NewPlumber(jxClient, ns)
+	plumberClient, err := plumber.NewPlumberWithCache(jxClient, ns, activityCache)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error getting Plumber client.")
 	}
@@ -49,6 +51,6 @@ func NewTideController(configAgent *config.Agent, botName string, gitKind string
 	if err != nil {
 		return nil, errors.Wrap(err, "Error getting Kubernetes client.")
 	}
-	c, err := tide.NewController(gitproviderClient, gitproviderClient, plumberClient, mpClient, tektonClient, ns, configAgent.Config, gitClient, maxRecordsPerPool, opener, historyURI, statusURI, nil)
+	c, err := tide.NewController(gitproviderClient, gitproviderClient, plumberClient, mpClient, tektonClient, ns, configAgent.Config, gitClient, maxRecordsPerPool, opener, historyURI, statusURI, dryRun, nil, nil)
 	return c, err
 }
@@ -28,7 +28,12 @@ const (
 )
 
 type gitHubAppTideController struct {
-	controllers        []tide.Controller
+	// controllers holds the controller of each owner, using its installation token
+	controllers map[string]tide.Controller
+	// states holds the state of the controller of each owner, which is given to the
+	// controller recreated on the next sync
+	states map[string]*tide.State
+
 	ownerTokenFinder   *OwnerTokensDir
 	gitServer          string
 	githubAppSecretDir string
@@ -39,16 +44,19 @@ type gitHubAppTideController struct {
 	opener             io.Opener
 	historyURI         string
 	statusURI          string
+	dryRun             bool
+	activityCache      *plumber.ActivityCache
 	logger             *logrus.Entry
 	m                  sync.Mutex
 }
 
 // NewGitHubAppTideController creates a GitHub App style controller which needs to process each github owner
 // using a separate git provider client due to the way GitHub App tokens work
-func NewGitHubAppTideController(githubAppSecretDir string, configAgent *config.Agent, botName string, gitKind string, maxRecordsPerPool int, opener io.Opener, historyURI string, statusURI string) (tide.Controller, error) {
+func NewGitHubAppTideController(githubAppSecretDir string, configAgent *config.Agent, botName string, gitKind string, maxRecordsPerPool int, opener io.Opener, historyURI string, statusURI string, dryRun bool, activityCache *plumber.ActivityCache) (tide.Controller, error) {
 
 	gitServer := GithubServer
 	return &gitHubAppTideController{
+		states:            map[string]*tide.State{},
 		ownerTokenFinder:  NewOwnerTokensDir(gitServer, githubAppSecretDir),
 		gitServer:         gitServer,
 		configAgent:       configAgent,
@@ -58,6 +66,8 @@ func NewGitHubAppTideController(githubAppSecretDir string, configAgent *config.A
 		opener:            opener,
 		historyURI:        historyURI,
 		statusURI:         statusURI,
+		dryRun:            dryRun,
+		activityCache:     activityCache,
 		logger:            logrus.NewEntry(logrus.StandardLogger()),
 	}, nil
 
@@ -65,13 +75,17 @@ func NewGitHubAppTideController(githubAppSecretDir string, configAgent *config.A
 
 func (g *gitHubAppTideController) Sync() error {
 	// lets iterate through the config and create a controller for each
+	// while no pool sync is using the old ones
+	g.m.Lock()
 	err := g.createOwnerControllers()
+	controllers := g.controllers
+	g.m.Unlock()
 	if err != nil {
 		return err
 	}
 	// now lets sync them all
 	errs := []error{}
-	for _, c := range g.controllers {
+	for _, c := range controllers {
 		err := c.Sync()
 		if err != nil {
 			errs = append(errs, err)
@@ -80,6 +94,22 @@ func (g *gitHubAppTideController) Sync() error {
 	return util.CombineErrors(errs...)
 }
 
+func (g *gitHubAppTideController) SyncPool(org, repo, branch string) error {
+	// the controllers are replaced rather than modified on sync so the pool can be
+	// synced without holding the lock
+	g.m.Lock()
+	controllers := g.controllers
+	g.m.Unlock()
+	errs := []error{}
+	for _, c := range controllers {
+		err := c.SyncPool(org, repo, branch)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return util.CombineErrors(errs...)
+}
+
 func (g *gitHubAppTideController) Shutdown() {
 	for _, c := range g.controllers {
 		c.Shutdown()
@@ -122,10 +152,22 @@ func (g *gitHubAppTideController) GetHistory() *history.History {
 	return answer
 }
 
+// GetPRStatuses explains the PRs of the query with the controller of its org,
+// which uses the installation token of the org.
+func (g *gitHubAppTideController) GetPRStatuses(q tide.PRStatusQuery) ([]tide.PRStatus, error) {
+	g.m.Lock()
+	c := g.controllers[q.Org]
+	g.m.Unlock()
+	if c == nil {
+		return nil, nil
+	}
+	return c.GetPRStatuses(q)
+}
+
 func (g *gitHubAppTideController) createOwnerControllers() error {
 	// lets zap any old controllers
 	g.Shutdown()
-	g.controllers = nil
+	g.controllers = map[string]tide.Controller{}
 
 	errs := []error{}
 
@@ -147,7 +189,7 @@ func (g *gitHubAppTideController) createOwnerControllers() error {
 		if err != nil {
 			errs = append(errs, err)
 		} else {
-			g.controllers = append(g.controllers, c)
+			g.controllers[owner] = c
 		}
 	}
 	return util.CombineErrors(errs...)
@@ -178,7 +220,7 @@ func (g *gitHubAppTideController) createOwnerController(owner string, configGett
 	if err != nil {
 		return nil, errors.Wrap(err, "Error creating kubernetes resource clients.")
 	}
-	plumberClient, err := plumber.NewPlumber(jxClient, ns)
+	plumberClient, err := plumber.NewPlumberWithCache(jxClient, ns, g.activityCache)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error getting Plumber client.")
 	}
@@ -187,7 +229,12 @@ func (g *gitHubAppTideController) createOwnerController(owner string, configGett
 	if err != nil {
 		return nil, errors.Wrap(err, "Error getting Kubernetes client.")
 	}
-	c, err := tide.NewController(gitproviderClient, gitproviderClient, plumberClient, mpClient, tektonClient, ns, configGetter, gitClient, g.maxRecordsPerPool, g.opener, g.historyURI, g.statusURI, nil)
+	state := g.states[owner]
+	if state == nil {
+		state = tide.NewState()
+		g.states[owner] = state
+	}
+	c, err := tide.NewController(gitproviderClient, gitproviderClient, plumberClient, mpClient, tektonClient, ns, configGetter, gitClient, g.maxRecordsPerPool, g.opener, g.historyURI, g.statusURI, g.dryRun, state, nil)
 	return c, err
 }
 
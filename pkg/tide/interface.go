@@ -10,8 +10,11 @@ import (
 // whether regular or the GitHub App flavour which has to handle tokens differently
 type Controller interface {
 	Sync() error
+	// SyncPool syncs the pools of a repository, or of a single branch of it if branch is not empty
+	SyncPool(org, repo, branch string) error
 	Shutdown()
 	GetPools() []Pool
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
 	GetHistory() *history.History
+	GetPRStatuses(q PRStatusQuery) ([]PRStatus, error)
 }
@@ -0,0 +1,102 @@
+package tide
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+)
+
+var (
+	// mergeMethodDirectiveRe matches a "/merge-method <method>" line of a PR body
+	mergeMethodDirectiveRe = regexp.MustCompile(`(?m)^/merge-method\s+(\S+)\s*$`)
+	// linkedIssueRe matches the references to the issues a PR closes, e.g. "fixes #12"
+	linkedIssueRe = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?)\s+#(\d+)\b`)
+	// coAuthorRe matches the Co-authored-by trailers of a commit message
+	coAuthorRe = regexp.MustCompile(`(?mi)^co-authored-by:\s*(.+?)\s*$`)
+)
+
+// MergeCommitTemplateData is passed to the merge commit templates. The fields of
+// the PullRequest can be used directly, e.g. {{ .Number }}.
+type MergeCommitTemplateData struct {
+	PullRequest
+	// CoAuthors are the "Name <email>" of the commit authors and co-authors other
+	// than the PR author, for Co-authored-by trailers.
+	CoAuthors []string
+	// LinkedIssues are the numbers of the issues the PR body says it closes.
+	LinkedIssues []int
+	// LabelNames are the names of the labels of the PR.
+	LabelNames []string
+}
+
+// mergeMethod returns the merge method of the PR: the method configured for its
+// branch unless the PR body picks an allowed one with a "/merge-method" line, and
+// the merge labels override both.
+func (c *DefaultController) mergeMethod(sp subpool, pr PullRequest) (gitprovider.PullRequestMergeType, error) {
+	tideConfig := c.config().Tide
+	method := tideConfig.MergeMethod(sp.org, sp.repo, sp.branch)
+	if m := mergeMethodDirectiveRe.FindStringSubmatch(string(pr.Body)); m != nil {
+		allowed := tideConfig.AllowedMergeMethods(sp.org, sp.repo, sp.branch)
+		if len(allowed) > 0 {
+			directive := gitprovider.PullRequestMergeType(m[1])
+			ok := false
+			for _, a := range allowed {
+				if a == directive {
+					ok = true
+				}
+			}
+			if !ok {
+				return "", fmt.Errorf("merge method %q requested by the PR body is not one of the allowed merge methods %v", directive, allowed)
+			}
+			method = directive
+		}
+	}
+	if tideConfig.SquashLabel != "" || tideConfig.RebaseLabel != "" || tideConfig.MergeLabel != "" {
+		return checkMergeLabels(pr, tideConfig.SquashLabel, tideConfig.RebaseLabel, tideConfig.MergeLabel, method)
+	}
+	return method, nil
+}
+
+// mergeCommitTemplateData gathers the data of the merge commit templates. The
+// commits of the PR are only listed if a template is configured.
+func (c *DefaultController) mergeCommitTemplateData(pr PullRequest) MergeCommitTemplateData {
+	data := MergeCommitTemplateData{PullRequest: pr}
+	for _, label := range pr.Labels.Nodes {
+		data.LabelNames = append(data.LabelNames, string(label.Name))
+	}
+	seenIssues := map[int]bool{}
+	for _, m := range linkedIssueRe.FindAllStringSubmatch(string(pr.Body), -1) {
+		n, err := strconv.Atoi(m[1])
+		if err == nil && !seenIssues[n] {
+			seenIssues[n] = true
+			data.LinkedIssues = append(data.LinkedIssues, n)
+		}
+	}
+
+	commits, err := c.ghc.ListPRCommits(string(pr.Repository.Owner.Login), string(pr.Repository.Name), int(pr.Number))
+	if err != nil {
+		c.logger.WithFields(pr.logFields()).WithError(err).Warn("Cannot list the commits of the PR for the merge commit template.")
+		return data
+	}
+	author := string(pr.Author.Login)
+	seenAuthors := map[string]bool{}
+	addCoAuthor := func(coAuthor string) {
+		if !seenAuthors[strings.ToLower(coAuthor)] {
+			seenAuthors[strings.ToLower(coAuthor)] = true
+			data.CoAuthors = append(data.CoAuthors, coAuthor)
+		}
+	}
+	for _, commit := range commits {
+		if commit.Author.Login != author && commit.Author.Email != "" {
+			addCoAuthor(fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email))
+		}
+		for _, m := range coAuthorRe.FindAllStringSubmatch(commit.Message, -1) {
+			addCoAuthor(m[1])
+		}
+	}
+	sort.Strings(data.CoAuthors)
+	return data
+}
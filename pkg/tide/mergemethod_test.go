@@ -0,0 +1,132 @@
+package tide
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeMethod(t *testing.T) {
+	testCases := []struct {
+		name   string
+		branch string
+		body   string
+		labels []string
+
+		expected  gitprovider.PullRequestMergeType
+		expectErr bool
+	}{
+		{
+			name:     "repo method",
+			branch:   "master",
+			expected: gitprovider.MergeRebase,
+		},
+		{
+			name:     "branch method",
+			branch:   "release",
+			expected: gitprovider.MergeSquash,
+		},
+		{
+			name:     "allowed directive",
+			branch:   "master",
+			body:     "Some change\n/merge-method squash\n",
+			expected: gitprovider.MergeSquash,
+		},
+		{
+			name:      "directive not allowed",
+			branch:    "master",
+			body:      "/merge-method merge",
+			expectErr: true,
+		},
+		{
+			name:     "directive ignored without allowed methods",
+			branch:   "release",
+			body:     "/merge-method rebase",
+			expected: gitprovider.MergeSquash,
+		},
+		{
+			name:     "label overrides directive",
+			branch:   "master",
+			body:     "/merge-method squash",
+			labels:   []string{"tide/merge-method-merge"},
+			expected: gitprovider.MergeMerge,
+		},
+	}
+	cfg := &config.Config{
+		ProwConfig: config.ProwConfig{
+			Tide: config.Tide{
+				MergeType: map[string]gitprovider.PullRequestMergeType{
+					"o/r":         gitprovider.MergeRebase,
+					"o/r:release": gitprovider.MergeSquash,
+				},
+				AllowedMergeTypes: map[string][]gitprovider.PullRequestMergeType{
+					"o/r:master": {gitprovider.MergeRebase, gitprovider.MergeSquash},
+				},
+				MergeLabel: "tide/merge-method-merge",
+			},
+		},
+	}
+	c := &DefaultController{
+		logger: logrus.WithField("component", "tide"),
+		config: func() *config.Config { return cfg },
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr := testPR("o", "r", tc.branch, 1, githubql.MergeableStateMergeable)
+			pr.Body = githubql.String(tc.body)
+			for _, label := range tc.labels {
+				pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(label)})
+			}
+			method, err := c.mergeMethod(subpool{org: "o", repo: "r", branch: tc.branch}, pr)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, method)
+		})
+	}
+}
+
+func TestMergeCommitTemplateData(t *testing.T) {
+	pr := testPR("o", "r", "master", 3, githubql.MergeableStateMergeable)
+	pr.Author.Login = "author"
+	pr.Title = "Fix the thing"
+	pr.Body = "Fixes #12 and closes #7, see #5. Also fixes #12."
+	pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: "kind/bug"})
+
+	ghc := &fgc{
+		commits: map[int][]scm.Commit{
+			3: {
+				{Message: "first", Author: scm.Signature{Login: "author", Name: "Author", Email: "author@example.com"}},
+				{Message: "second", Author: scm.Signature{Login: "helper", Name: "Helper", Email: "helper@example.com"}},
+				{Message: "third\n\nCo-authored-by: Pair <pair@example.com>\nco-authored-by: Helper <helper@example.com>", Author: scm.Signature{Login: "author", Name: "Author", Email: "author@example.com"}},
+			},
+		},
+	}
+	c := &DefaultController{
+		logger: logrus.WithField("component", "tide"),
+		ghc:    ghc,
+	}
+
+	data := c.mergeCommitTemplateData(pr)
+	assert.Equal(t, []string{"Helper <helper@example.com>", "Pair <pair@example.com>"}, data.CoAuthors)
+	assert.Equal(t, []int{12, 7}, data.LinkedIssues)
+	assert.Equal(t, []string{"kind/bug"}, data.LabelNames)
+
+	tpl := config.TideMergeCommitTemplate{
+		Title: template.Must(template.New("title").Parse("{{ .Title }} (#{{ .Number }})")),
+		Body:  template.Must(template.New("body").Parse("{{ range .LinkedIssues }}Closes #{{ . }}\n{{ end }}{{ range .CoAuthors }}Co-authored-by: {{ . }}\n{{ end }}")),
+	}
+	details := c.prepareMergeDetails(tpl, pr, gitprovider.MergeSquash)
+	assert.Equal(t, "Fix the thing (#3)", details.CommitTitle)
+	assert.Equal(t, "Closes #12\nCloses #7\nCo-authored-by: Helper <helper@example.com>\nCo-authored-by: Pair <pair@example.com>\n", details.CommitMessage)
+	assert.Equal(t, "squash", details.MergeMethod)
+}
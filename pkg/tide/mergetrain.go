@@ -0,0 +1,291 @@
+package tide
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/git"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Actions of the merge trains
+const (
+	// TriggerTrain means the tests of the cars of the merge train were triggered
+	TriggerTrain = "TRIGGER_TRAIN"
+	// MergeTrain means the PRs of the longest passing car of the merge train were merged
+	MergeTrain = "MERGE_TRAIN"
+)
+
+// trainCar is a speculative batch of a merge train: the PRs of the previous car
+// plus the next PR, merged onto the base SHA of the subpool.
+type trainCar struct {
+	prs   []PullRequest
+	state simpleState
+	// missing holds the required presubmits of the car which have not run, by PR
+	missing map[int][]config.Presubmit
+}
+
+// newTrainCar gathers the state of the presubmits of a car. Cars of a single PR
+// are its presubmits, longer cars are batch jobs testing exactly their PRs. The
+// state is empty if presubmits are missing and none failed.
+func newTrainCar(sp subpool, prs []PullRequest) trainCar {
+	car := trainCar{prs: prs, missing: map[int][]config.Presubmit{}}
+	jobType := plumber.BatchJob
+	if len(prs) == 1 {
+		jobType = plumber.PresubmitJob
+	}
+	jobStates := make(map[string]simpleState)
+	for _, pj := range sp.pjs {
+		if pj.Spec.Type != jobType || !carRefs(pj.Spec.Refs, prs) {
+			continue
+		}
+		// Store the best result for each context.
+		context := pj.Spec.Context
+		jobState := toSimpleState(pj.Status.State)
+		if s, ok := jobStates[context]; !ok || s == failureState || jobState == successState {
+			jobStates[context] = jobState
+		}
+	}
+
+	car.state = successState
+	seen := sets.NewString()
+	for _, pr := range prs {
+		for _, ps := range sp.presubmits[int(pr.Number)] {
+			if seen.Has(ps.Context) {
+				continue
+			}
+			seen.Insert(ps.Context)
+			switch s, ok := jobStates[ps.Context]; {
+			case !ok:
+				car.missing[int(pr.Number)] = append(car.missing[int(pr.Number)], ps)
+			case s == failureState:
+				car.state = failureState
+			case s == pendingState && car.state == successState:
+				car.state = pendingState
+			}
+		}
+	}
+	if len(car.missing) > 0 && car.state != failureState {
+		car.state = ""
+	}
+	return car
+}
+
+// carRefs returns true if the refs test exactly the PRs of a car, in order.
+func carRefs(refs *plumber.Refs, prs []PullRequest) bool {
+	if refs == nil || len(refs.Pulls) != len(prs) {
+		return false
+	}
+	for i, pull := range refs.Pulls {
+		if pull.Number != int(prs[i].Number) || pull.SHA != string(prs[i].HeadRefOID) {
+			return false
+		}
+	}
+	return true
+}
+
+// trainCache remembers whether the head of a PR merges cleanly with the heads of
+// the PRs ahead of it in the merge train, on the base SHA of its subpool. As these
+// are all commits the answer never changes, so the repository is only cloned when
+// the heads of the train or the base SHA change. The merges of a subpool are
+// forgotten when its base SHA moves.
+type trainCache struct {
+	lock  sync.Mutex
+	pools map[string]trainEntry
+}
+
+type trainEntry struct {
+	baseSHA string
+	// merges holds whether the last of the heads merges with the others, keyed by
+	// the heads joined in the order of the train
+	merges map[string]bool
+}
+
+func newTrainCache() *trainCache {
+	return &trainCache{pools: make(map[string]trainEntry)}
+}
+
+// get returns whether the last head merges cleanly with the others on the base SHA
+// of the subpool, and false if it is not known. It is a no-op on a nil cache.
+func (tc *trainCache) get(sp subpool, heads []string) (merges bool, known bool) {
+	if tc == nil {
+		return false, false
+	}
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	entry, ok := tc.pools[poolKey(sp.org, sp.repo, sp.branch)]
+	if !ok || entry.baseSHA != sp.sha {
+		return false, false
+	}
+	merges, known = entry.merges[strings.Join(heads, ",")]
+	return merges, known
+}
+
+// set records whether the last head merges cleanly with the others on the base SHA
+// of the subpool. It is a no-op on a nil cache.
+func (tc *trainCache) set(sp subpool, heads []string, merges bool) {
+	if tc == nil {
+		return
+	}
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	key := poolKey(sp.org, sp.repo, sp.branch)
+	entry, ok := tc.pools[key]
+	if !ok || entry.baseSHA != sp.sha {
+		entry = trainEntry{baseSHA: sp.sha, merges: make(map[string]bool)}
+		tc.pools[key] = entry
+	}
+	entry.merges[strings.Join(heads, ",")] = merges
+}
+
+// buildTrain builds the merge train of the subpool: up to depth cars made of its
+// passing PRs in order of priority. PRs which do not merge cleanly with the
+// previous ones are skipped, and PRs whose car failed are evicted so that the
+// train is rebuilt without them. Since the jobs of the subpool are those of its
+// base SHA, a new train is built whenever the base branch moves. The repository is
+// only cloned to merge the heads which were not merged in this order yet.
+func (c *DefaultController) buildTrain(sp subpool, depth int) ([]trainCar, error) {
+	prs := append([]PullRequest(nil), sp.prs...)
+	sortByPriority(&c.config().Tide, prs, c.tracker, time.Now())
+	var candidates []PullRequest
+	for _, pr := range prs {
+		if isPassingTests(sp.log, c.ghc, pr, sp.cc) {
+			candidates = append(candidates, pr)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	var r *git.Repo
+	defer func() {
+		if r != nil {
+			r.Clean()
+		}
+	}()
+
+	var cars []trainCar
+	var train []PullRequest
+	var heads []string
+	for _, pr := range candidates {
+		car := newTrainCar(sp, append(append([]PullRequest(nil), train...), pr))
+		if car.state == failureState {
+			sp.log.WithFields(pr.logFields()).WithField("train", prNumbers(train)).Info("Evicted the PR from the merge train as its car failed.")
+			continue
+		}
+		carHeads := append(append([]string(nil), heads...), string(pr.HeadRefOID))
+		ok, known := c.trains.get(sp, carHeads)
+		// once cloned, the repository has to follow the train
+		if !known || r != nil {
+			var err error
+			if r == nil {
+				if r, err = c.cloneTrain(sp, heads); err != nil {
+					return nil, err
+				}
+			}
+			if ok, err = r.Merge(string(pr.HeadRefOID)); err != nil {
+				// we failed to abort the merge and our git client is
+				// in a bad state; it must be cleaned before we try again
+				return nil, err
+			}
+			c.trains.set(sp, carHeads, ok)
+		}
+		if !ok {
+			sp.log.WithFields(pr.logFields()).Debug("PR does not merge with the merge train.")
+			continue
+		}
+		train = car.prs
+		heads = carHeads
+		cars = append(cars, car)
+		if len(cars) >= depth {
+			break
+		}
+	}
+	return cars, nil
+}
+
+// cloneTrain clones the repository at the base SHA of the subpool and merges the
+// heads of the train into it.
+func (c *DefaultController) cloneTrain(sp subpool, heads []string) (*git.Repo, error) {
+	r, err := c.cloneAt(sp)
+	if err != nil {
+		return nil, err
+	}
+	for _, head := range heads {
+		ok, err := r.Merge(head)
+		if err == nil && !ok {
+			err = fmt.Errorf("%s no longer merges with the merge train", head)
+		}
+		if err != nil {
+			r.Clean()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// takeTrainAction merges the longest passing car of the merge train once no
+// longer car is pending, and triggers the tests of the cars which have not run.
+func (c *DefaultController) takeTrainAction(sp subpool, depth int, mergeAllowed func([]PullRequest) bool) (Action, []PullRequest, error) {
+	cars, err := c.buildTrain(sp, depth)
+	if err != nil {
+		return Wait, nil, err
+	}
+	sp.log.WithField("train", trainStates(cars)).Debug("Built the merge train.")
+
+	wait := Wait
+	longest := -1
+	for i, car := range cars {
+		if car.state == successState {
+			longest = i
+		}
+	}
+	if longest >= 0 {
+		// a longer car which is still pending may merge more PRs at once
+		pending := false
+		for _, car := range cars[longest+1:] {
+			if car.state == pendingState {
+				pending = true
+			}
+		}
+		if !pending {
+			prs := cars[longest].prs
+			if mergeAllowed(prs) {
+				return MergeTrain, prs, c.mergePRs(sp, prs)
+			}
+			wait = MergeWindowClosed
+		}
+	}
+
+	var triggered []PullRequest
+	for _, car := range cars {
+		if car.state != "" {
+			continue
+		}
+		if err := c.trigger(sp, car.missing, car.prs); err != nil {
+			return TriggerTrain, car.prs, err
+		}
+		triggered = car.prs
+	}
+	if len(triggered) > 0 {
+		return TriggerTrain, triggered, nil
+	}
+	return wait, nil, nil
+}
+
+// trainStates describes the cars of a merge train for the logs.
+func trainStates(cars []trainCar) []string {
+	var states []string
+	for _, car := range cars {
+		state := string(car.state)
+		if state == "" {
+			state = "missing"
+		}
+		states = append(states, state)
+	}
+	return states
+}
@@ -0,0 +1,258 @@
+package tide
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/jenkins-x/lighthouse/pkg/plumber/fake"
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/git"
+	"github.com/jenkins-x/lighthouse/pkg/prow/git/localgit"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeTrainAction(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	type car struct {
+		prs   []int
+		state plumber.PipelineState
+	}
+	testCases := []struct {
+		name         string
+		depth        int
+		cars         []car
+		windowClosed bool
+
+		expectAction  Action
+		expectTargets []int
+		// expectTriggered holds the PRs of the triggered cars
+		expectTriggered [][]int
+		expectMerged    int
+	}{
+		{
+			name:            "all cars missing",
+			depth:           3,
+			expectAction:    TriggerTrain,
+			expectTargets:   []int{1, 2, 3},
+			expectTriggered: [][]int{{1}, {1, 2}, {1, 2, 3}},
+		},
+		{
+			name:            "train limited to its depth",
+			depth:           2,
+			expectAction:    TriggerTrain,
+			expectTargets:   []int{1, 2},
+			expectTriggered: [][]int{{1}, {1, 2}},
+		},
+		{
+			name:  "longer cars pending",
+			depth: 3,
+			cars: []car{
+				{prs: []int{1}, state: plumber.SuccessState},
+				{prs: []int{1, 2}, state: plumber.PendingState},
+				{prs: []int{1, 2, 3}, state: plumber.RunningState},
+			},
+			expectAction: Wait,
+		},
+		{
+			name:  "longest passing prefix merged",
+			depth: 3,
+			cars: []car{
+				{prs: []int{1}, state: plumber.SuccessState},
+				{prs: []int{1, 2}, state: plumber.SuccessState},
+				{prs: []int{1, 2, 3}, state: plumber.FailureState},
+			},
+			expectAction:  MergeTrain,
+			expectTargets: []int{1, 2},
+			expectMerged:  2,
+		},
+		{
+			name:  "failing PR evicted and the train rebuilt",
+			depth: 3,
+			cars: []car{
+				{prs: []int{1}, state: plumber.PendingState},
+				{prs: []int{1, 2}, state: plumber.FailureState},
+				{prs: []int{1, 2, 3}, state: plumber.SuccessState},
+			},
+			expectAction:    TriggerTrain,
+			expectTargets:   []int{1, 3},
+			expectTriggered: [][]int{{1, 3}},
+		},
+		{
+			name:  "merge window closed",
+			depth: 3,
+			cars: []car{
+				{prs: []int{1}, state: plumber.SuccessState},
+				{prs: []int{1, 2}, state: plumber.SuccessState},
+				{prs: []int{1, 2, 3}, state: plumber.SuccessState},
+			},
+			windowClosed: true,
+			expectAction: MergeWindowClosed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lg, gc, err := localgit.New()
+			require.NoError(t, err)
+			defer gc.Clean()
+			defer lg.Clean()
+			require.NoError(t, lg.MakeFakeRepo("o", "r"))
+
+			sp := subpool{
+				log:        logrus.WithField("component", "tide"),
+				presubmits: map[int][]config.Presubmit{},
+				cc:         &config.TideContextPolicy{},
+				org:        "o",
+				repo:       "r",
+				branch:     "master",
+				sha:        "master",
+			}
+			prs := map[int]PullRequest{}
+			for i := 1; i <= 3; i++ {
+				require.NoError(t, lg.CheckoutNewBranch("o", "r", fmt.Sprintf("pr-%d", i)))
+				require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{fmt.Sprintf("%d", i): []byte("WOW")}))
+				require.NoError(t, lg.Checkout("o", "r", "master"))
+				oid := githubql.String(fmt.Sprintf("origin/pr-%d", i))
+				var pr PullRequest
+				pr.Number = githubql.Int(i)
+				pr.HeadRefOID = oid
+				pr.Commits.Nodes = []struct {
+					Commit Commit
+				}{{Commit: Commit{OID: oid}}}
+				sp.prs = append(sp.prs, pr)
+				sp.presubmits[i] = []config.Presubmit{{Reporter: config.Reporter{Context: "foo"}}}
+				prs[i] = pr
+			}
+			for _, car := range tc.cars {
+				refs := &plumber.Refs{Org: "o", Repo: "r", BaseRef: "master", BaseSHA: "master"}
+				for _, n := range car.prs {
+					refs.Pulls = append(refs.Pulls, plumber.Pull{Number: n, SHA: string(prs[n].HeadRefOID)})
+				}
+				jobType := plumber.BatchJob
+				if len(car.prs) == 1 {
+					jobType = plumber.PresubmitJob
+				}
+				sp.pjs = append(sp.pjs, plumber.PipelineOptions{
+					Spec:   plumber.PipelineOptionsSpec{Type: jobType, Context: "foo", Refs: refs},
+					Status: plumber.PipelineStatus{State: car.state},
+				})
+			}
+
+			ghc := &fgc{}
+			fakePlumberClient := fake.NewPlumber()
+			cfg := &config.Config{}
+			c := &DefaultController{
+				logger:        logrus.WithField("component", "tide"),
+				gc:            gc,
+				config:        func() *config.Config { return cfg },
+				ghc:           ghc,
+				prowJobClient: fakePlumberClient,
+			}
+
+			act, targets, err := c.takeTrainAction(sp, tc.depth, func([]PullRequest) bool { return !tc.windowClosed })
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectAction, act)
+			assert.Equal(t, tc.expectTargets, prNumbers(targets))
+			assert.Equal(t, tc.expectMerged, ghc.merged)
+
+			var triggered [][]int
+			for _, pj := range fakePlumberClient.Pipelines {
+				var pulls []int
+				for _, pull := range pj.Spec.Refs.Pulls {
+					pulls = append(pulls, pull.Number)
+				}
+				if len(pulls) == 1 {
+					assert.Equal(t, plumber.PresubmitJob, pj.Spec.Type)
+				} else {
+					assert.Equal(t, plumber.BatchJob, pj.Spec.Type)
+				}
+				triggered = append(triggered, pulls)
+			}
+			assert.Equal(t, tc.expectTriggered, triggered)
+		})
+	}
+}
+
+// countingGitClient counts the clones of a git client
+type countingGitClient struct {
+	git.Client
+	clones int
+}
+
+func (c *countingGitClient) Clone(repo string) (*git.Repo, error) {
+	c.clones++
+	return c.Client.Clone(repo)
+}
+
+func TestBuildTrainCache(t *testing.T) {
+	lg, gc, err := localgit.New()
+	require.NoError(t, err)
+	defer gc.Clean()
+	defer lg.Clean()
+	require.NoError(t, lg.MakeFakeRepo("o", "r"))
+
+	sp := subpool{
+		log:        logrus.WithField("component", "tide"),
+		presubmits: map[int][]config.Presubmit{},
+		cc:         &config.TideContextPolicy{},
+		org:        "o",
+		repo:       "r",
+		branch:     "master",
+		sha:        "master",
+	}
+	// PRs 1 and 2 change the same file so that PR 2 does not merge after PR 1
+	for i, content := range []string{"one", "two", "three"} {
+		file := "conflict"
+		if i == 2 {
+			file = "other"
+		}
+		require.NoError(t, lg.CheckoutNewBranch("o", "r", fmt.Sprintf("pr-%d", i+1)))
+		require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{file: []byte(content)}))
+		require.NoError(t, lg.Checkout("o", "r", "master"))
+		oid := githubql.String(fmt.Sprintf("origin/pr-%d", i+1))
+		var pr PullRequest
+		pr.Number = githubql.Int(i + 1)
+		pr.HeadRefOID = oid
+		pr.Commits.Nodes = []struct {
+			Commit Commit
+		}{{Commit: Commit{OID: oid}}}
+		sp.prs = append(sp.prs, pr)
+	}
+
+	counting := &countingGitClient{Client: gc}
+	cfg := &config.Config{}
+	c := &DefaultController{
+		logger: logrus.WithField("component", "tide"),
+		gc:     counting,
+		config: func() *config.Config { return cfg },
+		ghc:    &fgc{},
+		trains: newTrainCache(),
+	}
+
+	cars, err := c.buildTrain(sp, 3)
+	require.NoError(t, err)
+	require.Len(t, cars, 2)
+	assert.Equal(t, []int{1, 3}, prNumbers(cars[1].prs))
+	assert.Equal(t, 1, counting.clones)
+
+	// the same train is built again without cloning
+	cars, err = c.buildTrain(sp, 3)
+	require.NoError(t, err)
+	require.Len(t, cars, 2)
+	assert.Equal(t, []int{1, 3}, prNumbers(cars[1].prs))
+	assert.Equal(t, 1, counting.clones)
+
+	// the repository is cloned again once the train changes
+	sp.prs = sp.prs[1:]
+	cars, err = c.buildTrain(sp, 3)
+	require.NoError(t, err)
+	require.Len(t, cars, 2)
+	assert.Equal(t, []int{2, 3}, prNumbers(cars[1].prs))
+	assert.Equal(t, 2, counting.clones)
+}
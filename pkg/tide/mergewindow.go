@@ -0,0 +1,49 @@
+package tide
+
+import (
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+)
+
+const (
+	// statusMergeWindowClosed is used when a PR is in a tide pool but the merge
+	// window of its branch is closed.
+	statusMergeWindowClosed = "In merge pool. Merge window closed"
+	// maxStatusDescriptionLength is the longest status description git providers accept.
+	maxStatusDescriptionLength = 140
+)
+
+// exemptPRs returns the PRs which may be merged outside the merge windows.
+func exemptPRs(windows *config.TideMergeWindows, prs []PullRequest) []PullRequest {
+	var answer []PullRequest
+	for i := range prs {
+		if windows.Exempt(prLabels(&prs[i])) {
+			answer = append(answer, prs[i])
+		}
+	}
+	return answer
+}
+
+// mergeWindowDescription returns the status description of a PR in the pool
+// if the merge window of its branch is closed, or the empty string if the PR
+// may be merged now.
+func mergeWindowDescription(t *config.Tide, pr *PullRequest, now time.Time) string {
+	windows := t.MergeWindowsFor(string(pr.Repository.Owner.Login), string(pr.Repository.Name), string(pr.BaseRef.Name))
+	open, until, reason := windows.Check(now)
+	if open || windows.Exempt(prLabels(pr)) {
+		return ""
+	}
+	desc := statusMergeWindowClosed
+	if !until.IsZero() {
+		desc += " until " + until.UTC().Format("Mon Jan 2 15:04 MST")
+	}
+	if reason != "" {
+		desc += ": " + reason
+	}
+	desc += "."
+	if len(desc) > maxStatusDescriptionLength {
+		desc = desc[:maxStatusDescriptionLength-3] + "..."
+	}
+	return desc
+}
@@ -0,0 +1,47 @@
+package tide
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/tide/blockers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeWindowDescription(t *testing.T) {
+	now := time.Date(2020, 3, 11, 10, 0, 0, 0, time.UTC)
+	freeze := config.TideMergeFreeze{
+		Start:  now.Add(-24 * time.Hour),
+		End:    time.Date(2020, 3, 12, 9, 0, 0, 0, time.UTC),
+		Reason: "release 1.2",
+	}
+	tide := &config.Tide{
+		MergeWindows: map[string]config.TideMergeWindows{
+			"org/repo": {Freezes: []config.TideMergeFreeze{freeze}, ExemptLabel: "hotfix"},
+		},
+	}
+
+	pr := newPriorityTestPR(1, time.Hour, true, now)
+	assert.Equal(t, "In merge pool. Merge window closed until Thu Mar 12 09:00 UTC: release 1.2.", mergeWindowDescription(tide, &pr, now))
+	assert.Equal(t, "", mergeWindowDescription(tide, &pr, freeze.End))
+
+	state, desc := expectedStatus(tide.Queries.QueryMap(), &pr, map[string]PullRequest{prKey(&pr): pr}, &config.TideContextPolicy{}, blockers.Blockers{}, nil, tide, nil, now)
+	assert.Equal(t, "pending", state)
+	assert.Equal(t, "In merge pool. Merge window closed until Thu Mar 12 09:00 UTC: release 1.2.", desc)
+
+	hotfix := newPriorityTestPR(2, time.Hour, true, now, "hotfix")
+	assert.Equal(t, "", mergeWindowDescription(tide, &hotfix, now))
+	assert.Equal(t, []PullRequest{hotfix}, exemptPRs(tide.MergeWindowsFor("org", "repo", "master"), []PullRequest{pr, hotfix}))
+
+	other := newPriorityTestPR(3, time.Hour, true, now)
+	other.Repository.Name = "other"
+	assert.Equal(t, "", mergeWindowDescription(tide, &other, now))
+
+	freeze.Reason = strings.Repeat("a very long reason ", 10)
+	tide.MergeWindows["org/repo"] = config.TideMergeWindows{Freezes: []config.TideMergeFreeze{freeze}}
+	desc = mergeWindowDescription(tide, &pr, now)
+	assert.Len(t, desc, maxStatusDescriptionLength)
+	assert.True(t, strings.HasSuffix(desc, "..."))
+}
@@ -0,0 +1,163 @@
+package tide
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+)
+
+// poolTracker remembers when the PRs entered their pool and which failed batches
+// were counted, so that the time to merge can be observed and each batch failure
+// is only counted once.
+type poolTracker struct {
+	now func() time.Time
+
+	lock sync.Mutex
+	// entered holds when each PR entered its pool, keyed by prKey
+	entered map[string]time.Time
+	// failedBatches holds the refs of the batches whose failure was counted
+	failedBatches map[string]bool
+}
+
+func newPoolTracker() *poolTracker {
+	return &poolTracker{
+		now:           time.Now,
+		entered:       make(map[string]time.Time),
+		failedBatches: make(map[string]bool),
+	}
+}
+
+// observe updates the metrics of a synced subpool.
+func (t *poolTracker) observe(sp subpool, successes, pendings, missings, blocked []PullRequest) {
+	gauge := func(state string, count int) {
+		tideMetrics.pooledPRsByState.WithLabelValues(sp.org, sp.repo, sp.branch, state).Set(float64(count))
+	}
+	gauge("success", len(successes))
+	gauge("pending", len(pendings))
+	gauge("missing", len(missings))
+	gauge("blocked", len(blocked))
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := t.now()
+	for _, prs := range [][]PullRequest{sp.prs, blocked} {
+		for i := range prs {
+			key := prKey(&prs[i])
+			if _, ok := t.entered[key]; !ok {
+				t.entered[key] = poolEntryTime(&prs[i], now)
+			}
+		}
+	}
+	for _, pj := range sp.pjs {
+		if pj.Spec.Type != plumber.BatchJob || toSimpleState(pj.Status.State) != failureState {
+			continue
+		}
+		ref := pj.Spec.Refs.String()
+		if !t.failedBatches[ref] {
+			t.failedBatches[ref] = true
+			tideMetrics.batches.WithLabelValues(sp.org, sp.repo, sp.branch, "test_failed").Inc()
+		}
+	}
+}
+
+// poolEntryTime estimates when a PR seen in its pool for the first time entered it
+// from the last time it was updated, e.g. pushed to or labelled, so that the wait
+// of PRs survives tide restarting. Later updates, such as comments, do not move
+// the entry time of the PRs which are tracked already.
+func poolEntryTime(pr *PullRequest, now time.Time) time.Time {
+	updated := pr.UpdatedAt.Time
+	if updated.IsZero() || updated.After(now) {
+		return now
+	}
+	return updated
+}
+
+// enteredAt returns when the PR entered its pool, or the zero time if it has not
+// been seen yet.
+func (t *poolTracker) enteredAt(pr *PullRequest) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.entered[prKey(pr)]
+}
+
+// merged observes how long the PR waited in its pool before merging. The PRs which
+// were not tracked yet, e.g. as tide restarted, are observed from their estimated
+// entry time too.
+func (t *poolTracker) merged(sp subpool, pr PullRequest) {
+	if t == nil {
+		return
+	}
+	key := prKey(&pr)
+	t.lock.Lock()
+	now := t.now()
+	entered, ok := t.entered[key]
+	if !ok {
+		entered = poolEntryTime(&pr, now)
+	}
+	delete(t.entered, key)
+	t.lock.Unlock()
+	tideMetrics.timeToMerge.WithLabelValues(sp.org, sp.repo, sp.branch).Observe(now.Sub(entered).Seconds())
+}
+
+// prune forgets the PRs and batches which are no longer in any pool.
+func (t *poolTracker) prune(pools map[string]*subpool) {
+	if t == nil {
+		return
+	}
+	prs := make(map[string]bool)
+	refs := make(map[string]bool)
+	for _, sp := range pools {
+		for i := range sp.prs {
+			prs[prKey(&sp.prs[i])] = true
+		}
+		for _, pj := range sp.pjs {
+			if pj.Spec.Type == plumber.BatchJob {
+				refs[pj.Spec.Refs.String()] = true
+			}
+		}
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for key := range t.entered {
+		if !prs[key] {
+			delete(t.entered, key)
+		}
+	}
+	for ref := range t.failedBatches {
+		if !refs[ref] {
+			delete(t.failedBatches, ref)
+		}
+	}
+}
+
+// countAction counts the retests and batch outcomes of the action taken on a subpool.
+func countAction(sp subpool, act Action, err error) {
+	switch act {
+	case Trigger:
+		if err == nil {
+			tideMetrics.retests.WithLabelValues(sp.org, sp.repo, sp.branch, "single").Inc()
+		}
+	case TriggerBatch:
+		if err == nil {
+			tideMetrics.retests.WithLabelValues(sp.org, sp.repo, sp.branch, "batch").Inc()
+			tideMetrics.batches.WithLabelValues(sp.org, sp.repo, sp.branch, "triggered").Inc()
+		}
+	case TriggerTrain:
+		if err == nil {
+			tideMetrics.retests.WithLabelValues(sp.org, sp.repo, sp.branch, "train").Inc()
+		}
+	case MergeBatch, MergeTrain:
+		if err == nil {
+			tideMetrics.batches.WithLabelValues(sp.org, sp.repo, sp.branch, "merged").Inc()
+		} else {
+			tideMetrics.batches.WithLabelValues(sp.org, sp.repo, sp.branch, "merge_failed").Inc()
+		}
+	}
+}
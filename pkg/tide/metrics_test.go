@@ -0,0 +1,95 @@
+package tide
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/plumber"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolTracker(t *testing.T) {
+	sp := subpool{org: "metrics-org", repo: "repo", branch: "master"}
+	pr1 := testPR("metrics-org", "repo", "master", 1, githubql.MergeableStateMergeable)
+	pr2 := testPR("metrics-org", "repo", "master", 2, githubql.MergeableStateMergeable)
+	sp.prs = []PullRequest{pr1, pr2}
+	failedBatch := plumber.PipelineOptions{
+		Spec: plumber.PipelineOptionsSpec{
+			Type: plumber.BatchJob,
+			Refs: &plumber.Refs{Org: "metrics-org", Repo: "repo", BaseRef: "master", BaseSHA: "base", Pulls: []plumber.Pull{{Number: 1, SHA: "SHA"}, {Number: 2, SHA: "SHA"}}},
+		},
+		Status: plumber.PipelineStatus{State: plumber.FailureState},
+	}
+	sp.pjs = []plumber.PipelineOptions{failedBatch, failedBatch}
+
+	tracker := newPoolTracker()
+	now := time.Date(2020, 3, 11, 10, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	tracker.observe(sp, []PullRequest{pr1}, []PullRequest{pr2}, nil, nil)
+	assert.Equal(t, float64(1), testutil.ToFloat64(tideMetrics.pooledPRsByState.WithLabelValues("metrics-org", "repo", "master", "success")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(tideMetrics.pooledPRsByState.WithLabelValues("metrics-org", "repo", "master", "pending")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(tideMetrics.pooledPRsByState.WithLabelValues("metrics-org", "repo", "master", "blocked")))
+
+	// a failed batch is only counted once however many syncs see it
+	now = now.Add(time.Hour)
+	tracker.observe(sp, nil, nil, nil, sp.prs)
+	assert.Equal(t, float64(2), testutil.ToFloat64(tideMetrics.pooledPRsByState.WithLabelValues("metrics-org", "repo", "master", "blocked")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(tideMetrics.pooledPRsByState.WithLabelValues("metrics-org", "repo", "master", "success")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(tideMetrics.batches.WithLabelValues("metrics-org", "repo", "master", "test_failed")))
+
+	// the time to merge is measured from the first sync which saw the PR
+	tracker.merged(sp, pr1)
+	assert.NotContains(t, tracker.entered, prKey(&pr1))
+
+	// PRs and batches which left the pools are forgotten
+	tracker.prune(map[string]*subpool{})
+	assert.Empty(t, tracker.entered)
+	assert.Empty(t, tracker.failedBatches)
+
+	countAction(sp, TriggerBatch, nil)
+	countAction(sp, Trigger, nil)
+	countAction(sp, MergeBatch, nil)
+	assert.Equal(t, float64(1), testutil.ToFloat64(tideMetrics.retests.WithLabelValues("metrics-org", "repo", "master", "batch")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(tideMetrics.retests.WithLabelValues("metrics-org", "repo", "master", "single")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(tideMetrics.batches.WithLabelValues("metrics-org", "repo", "master", "merged")))
+}
+
+func TestPoolEntryTime(t *testing.T) {
+	now := time.Date(2020, 3, 11, 10, 0, 0, 0, time.UTC)
+	pr := testPR("o", "r", "master", 1, githubql.MergeableStateMergeable)
+	assert.Equal(t, now, poolEntryTime(&pr, now), "PRs which were never updated enter the pool now")
+
+	pr.UpdatedAt = githubql.DateTime{Time: now.Add(-3 * time.Hour)}
+	assert.Equal(t, now.Add(-3*time.Hour), poolEntryTime(&pr, now))
+	pr.UpdatedAt = githubql.DateTime{Time: now.Add(time.Minute)}
+	assert.Equal(t, now, poolEntryTime(&pr, now), "updates in the future should not be trusted")
+
+	// a new tracker, e.g. after a restart, keeps the wait of PRs updated long ago
+	pr.UpdatedAt = githubql.DateTime{Time: now.Add(-3 * time.Hour)}
+	tracker := newPoolTracker()
+	tracker.now = func() time.Time { return now }
+	tracker.observe(subpool{org: "o", repo: "r", branch: "master", prs: []PullRequest{pr}}, nil, nil, nil, nil)
+	assert.Equal(t, now.Add(-3*time.Hour), tracker.enteredAt(&pr))
+
+	// later updates do not move the entry time of tracked PRs
+	pr.UpdatedAt = githubql.DateTime{Time: now}
+	tracker.observe(subpool{org: "o", repo: "r", branch: "master", prs: []PullRequest{pr}}, nil, nil, nil, nil)
+	assert.Equal(t, now.Add(-3*time.Hour), tracker.enteredAt(&pr))
+
+	// the time to merge of PRs merged before a sync saw them is measured from their
+	// estimated entry time too
+	sp := subpool{org: "entry-org", repo: "r", branch: "master"}
+	pr.UpdatedAt = githubql.DateTime{Time: now.Add(-2 * time.Hour)}
+	tracker = newPoolTracker()
+	tracker.now = func() time.Time { return now }
+	tracker.merged(sp, pr)
+	metric := &dto.Metric{}
+	assert.NoError(t, tideMetrics.timeToMerge.WithLabelValues("entry-org", "r", "master").(prometheus.Histogram).Write(metric))
+	assert.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount())
+	assert.Equal(t, (2 * time.Hour).Seconds(), metric.GetHistogram().GetSampleSum())
+}
@@ -0,0 +1,72 @@
+package tide
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+)
+
+// statusInPoolPriority is a format string used when a PR is in a tide pool and
+// priority labels are configured, so that authors can see the priority of their PR.
+// Its position in the pool is only given by /pr-status, which knows the whole pool.
+const statusInPoolPriority = "In merge pool (%s)."
+
+// prLabels returns the names of the labels of the PR.
+func prLabels(pr *PullRequest) []string {
+	var labels []string
+	for _, l := range pr.Labels.Nodes {
+		labels = append(labels, string(l.Name))
+	}
+	return labels
+}
+
+// prPriority returns the priority of the PR, lower values merging first. PRs
+// which entered their pool longer than the max wait ago come before every
+// priority label.
+func prPriority(t *config.Tide, pr *PullRequest, tracker *poolTracker, now time.Time) int {
+	if t.MaxWait > 0 {
+		if entered := tracker.enteredAt(pr); !entered.IsZero() && now.Sub(entered) >= t.MaxWait {
+			return -1
+		}
+	}
+	return t.Priority(prLabels(pr))
+}
+
+// priorityDescription describes the priority of the PR for humans.
+func priorityDescription(t *config.Tide, pr *PullRequest, tracker *poolTracker, now time.Time) string {
+	priority := prPriority(t, pr, tracker, now)
+	switch {
+	case priority < 0:
+		return "waited longer than " + t.MaxWait.String()
+	case priority < len(t.PriorityLabels):
+		return t.PriorityLabels[priority]
+	default:
+		return "no priority"
+	}
+}
+
+// sortByPriority sorts the PRs by priority, then age, oldest first, then number.
+func sortByPriority(t *config.Tide, prs []PullRequest, tracker *poolTracker, now time.Time) {
+	sort.SliceStable(prs, func(i, j int) bool {
+		pi, pj := prPriority(t, &prs[i], tracker, now), prPriority(t, &prs[j], tracker, now)
+		if pi != pj {
+			return pi < pj
+		}
+		ci, cj := prs[i].CreatedAt.Time, prs[j].CreatedAt.Time
+		if !ci.Equal(cj) {
+			return ci.Before(cj)
+		}
+		return prs[i].Number < prs[j].Number
+	})
+}
+
+// inPoolDescription returns the tide status description of a PR in the pool,
+// including its priority when priority labels are configured.
+func inPoolDescription(t *config.Tide, pr *PullRequest, tracker *poolTracker, now time.Time) string {
+	if len(t.PriorityLabels) == 0 {
+		return statusInPool
+	}
+	return fmt.Sprintf(statusInPoolPriority, priorityDescription(t, pr, tracker, now))
+}
@@ -0,0 +1,147 @@
+package tide
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPriorityTestPR(number int, age time.Duration, passing bool, now time.Time, labels ...string) PullRequest {
+	var pr PullRequest
+	pr.Number = githubql.Int(number)
+	pr.Repository.Owner.Login = "org"
+	pr.Repository.Name = "repo"
+	pr.Repository.NameWithOwner = "org/repo"
+	pr.BaseRef.Name = "master"
+	pr.CreatedAt = githubql.DateTime{Time: now.Add(-age)}
+	pr.HeadRefOID = githubql.String(fmt.Sprintf("sha%d", number))
+	for _, label := range labels {
+		pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(label)})
+	}
+	state := githubql.StatusStateSuccess
+	if !passing {
+		state = githubql.StatusStateFailure
+	}
+	pr.Commits.Nodes = []struct {
+		Commit Commit
+	}{{Commit: Commit{OID: pr.HeadRefOID}}}
+	pr.Commits.Nodes[0].Commit.Status.Contexts = []Context{{Context: "unit", State: state}}
+	return pr
+}
+
+func prNumbersOf(prs []PullRequest) []int {
+	var numbers []int
+	for _, pr := range prs {
+		numbers = append(numbers, int(pr.Number))
+	}
+	return numbers
+}
+
+func TestSortByPriority(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name     string
+		tide     config.Tide
+		prs      []PullRequest
+		entered  map[int]time.Duration
+		expected []int
+	}{
+		{
+			name: "no priority labels sorts by age",
+			prs: []PullRequest{
+				newPriorityTestPR(1, time.Hour, true, now),
+				newPriorityTestPR(2, 2*time.Hour, true, now, "priority/critical"),
+				newPriorityTestPR(3, time.Hour, true, now),
+			},
+			expected: []int{2, 1, 3},
+		},
+		{
+			name: "priority then age",
+			tide: config.Tide{PriorityLabels: []string{"priority/critical", "priority/high"}},
+			prs: []PullRequest{
+				newPriorityTestPR(1, 5*time.Hour, true, now),
+				newPriorityTestPR(2, time.Hour, true, now, "priority/high"),
+				newPriorityTestPR(3, 2*time.Hour, true, now, "priority/high"),
+				newPriorityTestPR(4, time.Minute, true, now, "priority/critical"),
+			},
+			expected: []int{4, 3, 2, 1},
+		},
+		{
+			name: "max wait overrides priority",
+			tide: config.Tide{PriorityLabels: []string{"priority/critical"}, MaxWait: 24 * time.Hour},
+			prs: []PullRequest{
+				newPriorityTestPR(1, time.Minute, true, now, "priority/critical"),
+				newPriorityTestPR(2, 48*time.Hour, true, now),
+				newPriorityTestPR(3, time.Hour, true, now),
+			},
+			entered:  map[int]time.Duration{1: time.Minute, 2: 25 * time.Hour, 3: time.Hour},
+			expected: []int{2, 1, 3},
+		},
+		{
+			name: "max wait is measured from pool entry",
+			tide: config.Tide{PriorityLabels: []string{"priority/critical"}, MaxWait: 24 * time.Hour},
+			prs: []PullRequest{
+				newPriorityTestPR(1, time.Minute, true, now, "priority/critical"),
+				newPriorityTestPR(2, 48*time.Hour, true, now),
+				newPriorityTestPR(3, 72*time.Hour, true, now),
+			},
+			entered:  map[int]time.Duration{1: time.Minute, 2: time.Hour},
+			expected: []int{1, 3, 2},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := newPoolTracker()
+			for i := range tc.prs {
+				if d, ok := tc.entered[int(tc.prs[i].Number)]; ok {
+					tracker.entered[prKey(&tc.prs[i])] = now.Add(-d)
+				}
+			}
+			sortByPriority(&tc.tide, tc.prs, tracker, now)
+			assert.Equal(t, tc.expected, prNumbersOf(tc.prs))
+		})
+	}
+}
+
+func TestPickHighestPriorityPassing(t *testing.T) {
+	now := time.Now()
+	tide := &config.Tide{PriorityLabels: []string{"priority/critical", "priority/high"}}
+	log := logrus.WithField("component", "tide")
+	cc := &config.TideContextPolicy{}
+
+	prs := []PullRequest{
+		newPriorityTestPR(1, 3*time.Hour, true, now),
+		newPriorityTestPR(2, time.Hour, false, now, "priority/critical"),
+		newPriorityTestPR(3, time.Hour, true, now, "priority/high"),
+	}
+	ok, pr := pickHighestPriorityPassing(log, nil, prs, cc, tide, nil, now)
+	assert.True(t, ok)
+	assert.Equal(t, githubql.Int(3), pr.Number)
+	assert.Equal(t, []int{1, 2, 3}, prNumbersOf(prs), "the candidates should not be reordered")
+
+	ok, _ = pickHighestPriorityPassing(log, nil, prs[1:2], cc, tide, nil, now)
+	assert.False(t, ok)
+}
+
+func TestInPoolDescription(t *testing.T) {
+	now := time.Now()
+	low := newPriorityTestPR(1, 2*time.Hour, true, now)
+	high := newPriorityTestPR(2, time.Hour, true, now, "priority/high")
+	tracker := newPoolTracker()
+	tracker.entered[prKey(&low)] = now.Add(-time.Hour)
+
+	assert.Equal(t, statusInPool, inPoolDescription(&config.Tide{}, &low, tracker, now))
+
+	tide := &config.Tide{PriorityLabels: []string{"priority/high"}}
+	assert.Equal(t, "In merge pool (priority/high).", inPoolDescription(tide, &high, tracker, now))
+	assert.Equal(t, "In merge pool (no priority).", inPoolDescription(tide, &low, tracker, now))
+
+	tide.MaxWait = time.Hour
+	assert.Equal(t, "In merge pool (waited longer than 1h0m0s).", inPoolDescription(tide, &low, tracker, now))
+	assert.Equal(t, "In merge pool (priority/high).", inPoolDescription(tide, &high, tracker, now), "the PR has not been seen in its pool")
+}
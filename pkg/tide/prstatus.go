@@ -0,0 +1,348 @@
+package tide
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/tide/blockers"
+	"github.com/jenkins-x/lighthouse/pkg/tide/history"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+)
+
+// prStatusHistoryLimit is the maximum number of pool history records included in a PRStatus.
+const prStatusHistoryLimit = 10
+
+// PRStatus explains where a pull request stands with tide: which queries it
+// matches, where it sits in its pool and what is holding it back.
+type PRStatus struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	SHA    string `json:"sha"`
+
+	// State and Description are the tide status context expected on the PR.
+	State       string `json:"state"`
+	Description string `json:"description"`
+
+	// Queries lists every tide query configured for the repository and how the
+	// PR differs from it.
+	Queries []QueryStatus `json:"queries"`
+
+	// InPool is true if the PR matches a query and is a merge candidate.
+	InPool bool `json:"inPool"`
+	// PoolPosition is the 1-based position of the PR in the merge order of its
+	// pool: passing PRs first, then pending, then missing, each by priority then age.
+	PoolPosition int `json:"poolPosition,omitempty"`
+	// PoolSize is the number of PRs in the pool.
+	PoolSize int `json:"poolSize,omitempty"`
+	// PoolAction is the action tide took for the pool during the last sync.
+	PoolAction Action `json:"poolAction,omitempty"`
+	// InBatch is true if the PR is part of the batch currently being tested or merged.
+	InBatch bool `json:"inBatch"`
+	// Priority describes the merge priority of the PR if priority labels are configured.
+	Priority string `json:"priority,omitempty"`
+
+	Blockers []blockers.Blocker `json:"blockers,omitempty"`
+
+	PendingContexts []string `json:"pendingContexts,omitempty"`
+	FailedContexts  []string `json:"failedContexts,omitempty"`
+	MissingContexts []string `json:"missingContexts,omitempty"`
+
+	// History holds the most recent actions tide took for the pool of the PR.
+	History []*history.Record `json:"history,omitempty"`
+}
+
+// QueryStatus describes how a PR compares to a single tide query.
+type QueryStatus struct {
+	Query       string   `json:"query"`
+	Matches     bool     `json:"matches"`
+	Differences []string `json:"differences,omitempty"`
+}
+
+// queryDifferences lists every requirement of the query that the PR does not meet.
+// Unlike requirementDiff the result is not truncated to fit a status description.
+func queryDifferences(pr *PullRequest, q *config.TideQuery, cc contextChecker) []string {
+	var diffs []string
+	if branchForbidden(pr, q) {
+		diffs = append(diffs, fmt.Sprintf("Merging to branch %s is forbidden.", pr.BaseRef.Name))
+	}
+	if milestoneMismatch(pr, q) {
+		diffs = append(diffs, fmt.Sprintf("Must be in milestone %s.", q.Milestone))
+	}
+	for _, label := range missingQueryLabels(pr, q) {
+		diffs = append(diffs, fmt.Sprintf("Needs %s label.", label))
+	}
+	for _, label := range presentQueryMissingLabels(pr, q) {
+		diffs = append(diffs, fmt.Sprintf("Should not have %s label.", label))
+	}
+	for _, ctx := range failedHeadContexts(pr, cc) {
+		diffs = append(diffs, fmt.Sprintf("Job %s has not succeeded.", ctx))
+	}
+	return diffs
+}
+
+// PRStatusQuery selects the open PRs of a repository whose tide status is
+// explained, optionally only those of an author or head branch.
+type PRStatusQuery struct {
+	Org    string
+	Repo   string
+	Author string
+	Head   string
+}
+
+var (
+	// orgRepoRE matches the names of orgs and repositories
+	orgRepoRE = regexp.MustCompile(`^[\w.-]+$`)
+	// searchValueRE matches the values which cannot add terms to a search query
+	searchValueRE = regexp.MustCompile(`^[^\s"':\\()]+$`)
+)
+
+// ParsePRStatusQuery parses the search query of the links built by targetURL,
+// e.g. "is:pr repo:org/repo author:user head:branch". Other search terms are
+// rejected so that the PR status page cannot run arbitrary searches.
+func ParsePRStatusQuery(query string) (PRStatusQuery, error) {
+	var q PRStatusQuery
+	for _, token := range strings.Fields(query) {
+		parts := strings.SplitN(token, ":", 2)
+		if len(parts) != 2 {
+			return q, fmt.Errorf("unsupported search term %q", token)
+		}
+		switch key, value := parts[0], parts[1]; key {
+		case "is":
+			if value != "pr" {
+				return q, fmt.Errorf("unsupported search term %q", token)
+			}
+		case "repo":
+			orgRepo := strings.SplitN(value, "/", 2)
+			if len(orgRepo) != 2 {
+				return q, fmt.Errorf("search term %q is not of the form repo:org/repo", token)
+			}
+			q.Org, q.Repo = orgRepo[0], orgRepo[1]
+		case "author":
+			q.Author = value
+		case "head":
+			q.Head = value
+		default:
+			return q, fmt.Errorf("unsupported search term %q", token)
+		}
+	}
+	return q, q.Validate()
+}
+
+// Validate checks the query names a repository and only holds plain values.
+func (q PRStatusQuery) Validate() error {
+	if !orgRepoRE.MatchString(q.Org) || !orgRepoRE.MatchString(q.Repo) {
+		return fmt.Errorf("invalid repository %q", q.Org+"/"+q.Repo)
+	}
+	if q.Author != "" && !searchValueRE.MatchString(q.Author) {
+		return fmt.Errorf("invalid author %q", q.Author)
+	}
+	if q.Head != "" && !searchValueRE.MatchString(q.Head) {
+		return fmt.Errorf("invalid head branch %q", q.Head)
+	}
+	return nil
+}
+
+// searchQuery builds the search for the open PRs selected by the query.
+func (q PRStatusQuery) searchQuery() string {
+	tokens := []string{"is:pr", "state:open", fmt.Sprintf("repo:%s/%s", q.Org, q.Repo)}
+	if q.Author != "" {
+		tokens = append(tokens, "author:"+q.Author)
+	}
+	if q.Head != "" {
+		tokens = append(tokens, "head:"+q.Head)
+	}
+	return strings.Join(tokens, " ")
+}
+
+// GetPRStatuses explains the state of the open PRs selected by the query. It
+// returns nil if no tide query is configured for the repository.
+func (c *DefaultController) GetPRStatuses(q PRStatusQuery) ([]PRStatus, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	queries := c.config().Tide.Queries.QueryMap().ForRepo(q.Org, q.Repo)
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	query := q.searchQuery()
+	prs, err := search(c.ghc.Query, c.logger, query, time.Time{}, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("searching for %q: %v", query, err)
+	}
+
+	c.m.Lock()
+	pools := c.pools
+	c.m.Unlock()
+	c.sc.Lock()
+	poolPRs := c.sc.poolPRs
+	blocks := c.sc.blocks
+	c.sc.Unlock()
+	records := c.History.AllRecords()
+
+	var answer []PRStatus
+	for i := range prs {
+		status, err := c.prStatus(&prs[i], queries, pools, poolPRs, blocks, records)
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, *status)
+	}
+	return answer, nil
+}
+
+func (c *DefaultController) prStatus(pr *PullRequest, queries config.TideQueries, pools []Pool, poolPRs map[string]PullRequest, blocks blockers.Blockers, records map[string][]*history.Record) (*PRStatus, error) {
+	org := string(pr.Repository.Owner.Login)
+	repo := string(pr.Repository.Name)
+	branch := string(pr.BaseRef.Name)
+	log := c.logger.WithFields(pr.logFields())
+	cc, err := c.config().GetTideContextPolicy(org, repo, branch)
+	if err != nil {
+		return nil, fmt.Errorf("setting up context register for %s: %v", prKey(pr), err)
+	}
+	contexts, err := headContexts(log, c.ghc, pr)
+	if err != nil {
+		return nil, fmt.Errorf("getting head contexts for %s: %v", prKey(pr), err)
+	}
+
+	var changedFiles config.ChangedFilesProvider
+	if c.changedFiles != nil {
+		changedFiles = c.changedFiles.prChanges(pr)
+	}
+
+	status := &PRStatus{
+		Org:      org,
+		Repo:     repo,
+		Branch:   branch,
+		Number:   int(pr.Number),
+		Title:    string(pr.Title),
+		Author:   string(pr.Author.Login),
+		SHA:      string(pr.HeadRefOID),
+		Blockers: prBlockers(blocks, pr, changedFiles),
+	}
+	tideConfig := &c.config().Tide
+	now := time.Now()
+	status.State, status.Description = expectedStatus(queries.QueryMap(), pr, poolPRs, cc, blocks, changedFiles, tideConfig, c.tracker, now)
+	if len(tideConfig.PriorityLabels) > 0 {
+		status.Priority = priorityDescription(tideConfig, pr, c.tracker, now)
+	}
+	for i := range queries {
+		diffs := queryDifferences(pr, &queries[i], cc)
+		status.Queries = append(status.Queries, QueryStatus{
+			Query:       queries[i].Query(),
+			Matches:     len(diffs) == 0,
+			Differences: diffs,
+		})
+	}
+
+	for _, ctx := range contexts {
+		name := string(ctx.Context)
+		if name == statusContext || cc.IsOptional(name) {
+			continue
+		}
+		switch ctx.State {
+		case githubql.StatusStateSuccess:
+		case githubql.StatusStatePending, githubql.StatusStateExpected:
+			status.PendingContexts = append(status.PendingContexts, name)
+		default:
+			status.FailedContexts = append(status.FailedContexts, name)
+		}
+	}
+	status.MissingContexts = cc.MissingRequiredContexts(contextsToStrings(contexts))
+
+	for _, pool := range pools {
+		if pool.Org != org || pool.Repo != repo || pool.Branch != branch {
+			continue
+		}
+		setPoolPosition(status, pool, tideConfig, c.tracker, now)
+	}
+
+	poolRecords := records[poolKey(org, repo, branch)]
+	if len(poolRecords) > prStatusHistoryLimit {
+		poolRecords = poolRecords[:prStatusHistoryLimit]
+	}
+	status.History = poolRecords
+	return status, nil
+}
+
+// setPoolPosition fills in where the PR of the status sits in the pool.
+func setPoolPosition(status *PRStatus, pool Pool, t *config.Tide, tracker *poolTracker, now time.Time) {
+	var ordered []PullRequest
+	for _, prs := range [][]PullRequest{pool.SuccessPRs, pool.PendingPRs, pool.MissingPRs} {
+		sorted := append([]PullRequest(nil), prs...)
+		sortByPriority(t, sorted, tracker, now)
+		ordered = append(ordered, sorted...)
+	}
+	status.PoolSize = len(ordered)
+	status.PoolAction = pool.Action
+	for i, pr := range ordered {
+		if int(pr.Number) == status.Number {
+			status.InPool = true
+			status.PoolPosition = i + 1
+			break
+		}
+	}
+
+	batch := pool.BatchPending
+	if pool.Action == TriggerBatch || pool.Action == MergeBatch || pool.Action == TriggerTrain || pool.Action == MergeTrain {
+		batch = append(batch, pool.Target...)
+	}
+	for _, pr := range batch {
+		if int(pr.Number) == status.Number {
+			status.InBatch = true
+			break
+		}
+	}
+}
+
+// NewPRStatusHandler returns a handler serving the JSON PRStatus of the PRs
+// selected by the "org", "repo", "author" and "head" parameters, or by the
+// "query" parameter of the page linked from the tide status context when
+// pr_status_base_url is configured.
+func NewPRStatusHandler(c Controller, logger *logrus.Entry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		q := PRStatusQuery{
+			Org:    params.Get("org"),
+			Repo:   params.Get("repo"),
+			Author: params.Get("author"),
+			Head:   params.Get("head"),
+		}
+		var err error
+		if query := params.Get("query"); query != "" {
+			q, err = ParsePRStatusQuery(query)
+		} else {
+			err = q.Validate()
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		statuses, err := c.GetPRStatuses(q)
+		if err != nil {
+			logger.WithError(err).WithField("query", q.searchQuery()).Error("Getting PR statuses.")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if statuses == nil {
+			statuses = []PRStatus{}
+		}
+		b, err := json.Marshal(statuses)
+		if err != nil {
+			logger.WithError(err).Error("Encoding JSON.")
+			b = []byte("[]")
+		}
+		if _, err = w.Write(b); err != nil {
+			logger.WithError(err).Error("Writing JSON response.")
+		}
+	})
+}
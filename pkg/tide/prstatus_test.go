@@ -0,0 +1,165 @@
+package tide
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/tide/history"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryDifferences(t *testing.T) {
+	pr := testPR("org", "repo", "master", 1, githubql.MergeableStateMergeable)
+	pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: "do-not-merge/hold"})
+	q := &config.TideQuery{
+		Labels:           []string{"lgtm", "approved"},
+		MissingLabels:    []string{"do-not-merge/hold"},
+		IncludedBranches: []string{"release"},
+	}
+	cc := &config.TideContextPolicy{}
+
+	assert.Equal(t, []string{
+		"Merging to branch master is forbidden.",
+		"Needs approved label.",
+		"Needs lgtm label.",
+		"Should not have do-not-merge/hold label.",
+	}, queryDifferences(&pr, q, cc))
+	assert.Empty(t, queryDifferences(&pr, &config.TideQuery{}, cc))
+}
+
+func TestParsePRStatusQuery(t *testing.T) {
+	testcases := []struct {
+		query     string
+		expected  PRStatusQuery
+		expectErr bool
+	}{
+		{query: "is:pr repo:org/repo author:user head:branch", expected: PRStatusQuery{Org: "org", Repo: "repo", Author: "user", Head: "branch"}},
+		{query: "is:pr repo:org/repo head:feature/foo", expected: PRStatusQuery{Org: "org", Repo: "repo", Head: "feature/foo"}},
+		{query: "is:pr author:user", expectErr: true},
+		{query: "is:pr repo:org", expectErr: true},
+		{query: "is:pr repo:org/repo is:private", expectErr: true},
+		{query: "is:pr repo:org/repo label:secret", expectErr: true},
+		{query: "is:pr repo:org/repo OR repo:other/repo", expectErr: true},
+		{query: "is:pr repo:org/repo author:\"user\"", expectErr: true},
+	}
+	for _, tc := range testcases {
+		q, err := ParsePRStatusQuery(tc.query)
+		if tc.expectErr {
+			assert.Error(t, err, tc.query)
+			continue
+		}
+		require.NoError(t, err, tc.query)
+		assert.Equal(t, tc.expected, q, tc.query)
+		assert.Contains(t, q.searchQuery(), "state:open", tc.query)
+	}
+}
+
+func TestGetPRStatuses(t *testing.T) {
+	pr5 := testPR("org", "repo", "master", 5, githubql.MergeableStateMergeable)
+	pr3 := testPR("org", "repo", "master", 3, githubql.MergeableStateMergeable)
+	ca := &config.Agent{}
+	ca.Set(&config.Config{
+		ProwConfig: config.ProwConfig{
+			Tide: config.Tide{
+				Queries: []config.TideQuery{{Repos: []string{"org/repo"}}},
+			},
+		},
+	})
+	hist, err := history.New(100, nil, "")
+	require.NoError(t, err)
+	hist.Record(poolKey("org", "repo", "master"), Merge, "baseSHA", "", prMeta(pr3))
+	c := &DefaultController{
+		config: ca.Config,
+		ghc:    &fgc{prs: []PullRequest{pr5}},
+		logger: logrus.WithField("controller", "sync"),
+		sc: &statusController{
+			poolPRs: byRepoAndNumber([]PullRequest{pr3, pr5}),
+		},
+		pools: []Pool{{
+			Org:          "org",
+			Repo:         "repo",
+			Branch:       "master",
+			SuccessPRs:   []PullRequest{pr5, pr3},
+			BatchPending: []PullRequest{pr5},
+			Action:       Wait,
+		}},
+		History: hist,
+	}
+
+	statuses, err := c.GetPRStatuses(PRStatusQuery{Org: "org", Repo: "repo", Author: "user", Head: "branch"})
+	require.NoError(t, err)
+	require.Len(t, statuses, 1)
+	status := statuses[0]
+	assert.Equal(t, 5, status.Number)
+	assert.Equal(t, statusInPool, status.Description)
+	assert.True(t, status.InPool)
+	assert.True(t, status.InBatch)
+	assert.Equal(t, 2, status.PoolPosition)
+	assert.Equal(t, 2, status.PoolSize)
+	require.Len(t, status.Queries, 1)
+	assert.True(t, status.Queries[0].Matches)
+	require.Len(t, status.History, 1)
+	assert.Equal(t, Merge, status.History[0].Action)
+
+	statuses, err = c.GetPRStatuses(PRStatusQuery{Org: "other", Repo: "repo"})
+	require.NoError(t, err)
+	assert.Nil(t, statuses)
+
+	_, err = c.GetPRStatuses(PRStatusQuery{Author: "user"})
+	assert.Error(t, err)
+}
+
+func TestPRStatusHandler(t *testing.T) {
+	ca := &config.Agent{}
+	ca.Set(&config.Config{
+		ProwConfig: config.ProwConfig{
+			Tide: config.Tide{
+				Queries: []config.TideQuery{{Repos: []string{"org/repo"}}},
+			},
+		},
+	})
+	hist, err := history.New(100, nil, "")
+	require.NoError(t, err)
+	c := &DefaultController{
+		config:  ca.Config,
+		ghc:     &fgc{prs: []PullRequest{testPR("org", "repo", "master", 5, githubql.MergeableStateMergeable)}},
+		logger:  logrus.WithField("controller", "sync"),
+		sc:      &statusController{},
+		History: hist,
+	}
+	s := httptest.NewServer(NewPRStatusHandler(c, logrus.WithField("handler", "pr-status")))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Get(s.URL + "?query=" + url.QueryEscape("is:pr repo:org/repo"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var statuses []PRStatus
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&statuses))
+	require.Len(t, statuses, 1)
+	assert.Equal(t, 5, statuses[0].Number)
+	assert.False(t, statuses[0].InPool)
+
+	resp, err = http.Get(s.URL + "?org=org&repo=repo&author=user")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// raw search terms are not passed through
+	resp, err = http.Get(s.URL + "?query=" + url.QueryEscape("is:pr repo:org/repo is:private"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
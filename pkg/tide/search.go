@@ -69,6 +69,8 @@ func search(query querier, log *logrus.Entry, q string, start, end time.Time) ([
 		}
 		totalCost += int(sq.RateLimit.Cost)
 		remaining = int(sq.RateLimit.Remaining)
+		tideMetrics.searchCost.Add(float64(sq.RateLimit.Cost))
+		tideMetrics.searchRemaining.Set(float64(sq.RateLimit.Remaining))
 		for _, n := range sq.Search.Nodes {
 			ret = append(ret, n.PullRequest)
 		}
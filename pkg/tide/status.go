@@ -22,7 +22,6 @@ import (
 	"io/ioutil"
 	"net/url"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -38,6 +37,7 @@ import (
 	"github.com/jenkins-x/lighthouse/pkg/prow/config"
 	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
 	"github.com/jenkins-x/lighthouse/pkg/tide/blockers"
+	"github.com/jenkins-x/lighthouse/pkg/tide/history"
 )
 
 const (
@@ -76,9 +76,23 @@ type statusController struct {
 	poolPRs map[string]PullRequest
 	blocks  blockers.Blockers
 
+	// changedFiles caches the names of files changed by PRs, used to check
+	// which blockers scoped to paths apply to them.
+	changedFiles *changedFilesAgent
+
+	// tracker tells when PRs entered their pool, to describe their priority.
+	tracker *poolTracker
+
 	storedState
 	opener io.Opener
 	path   string
+
+	// dryRunHistory records the status updates skipped in dry-run mode.
+	// It is nil when tide is not running in dry-run mode.
+	dryRunHistory *history.History
+	// dryRunStatuses remembers the last status recorded per PR in dry-run mode
+	// so the same skipped update is not recorded on every loop.
+	dryRunStatuses map[string]string
 }
 
 func (sc *statusController) shutdown() {
@@ -119,22 +133,7 @@ func requirementDiff(pr *PullRequest, q *config.TideQuery, cc contextChecker) (s
 
 	// Weight incorrect branches with very high diff so that we select the query
 	// for the correct branch.
-	targetBranchBlacklisted := false
-	for _, excludedBranch := range q.ExcludedBranches {
-		if string(pr.BaseRef.Name) == excludedBranch {
-			targetBranchBlacklisted = true
-			break
-		}
-	}
-	// if no whitelist is configured, the target is OK by default
-	targetBranchWhitelisted := len(q.IncludedBranches) == 0
-	for _, includedBranch := range q.IncludedBranches {
-		if string(pr.BaseRef.Name) == includedBranch {
-			targetBranchWhitelisted = true
-			break
-		}
-	}
-	if targetBranchBlacklisted || !targetBranchWhitelisted {
+	if branchForbidden(pr, q) {
 		diff += 1000
 		if desc == "" {
 			desc = fmt.Sprintf(" Merging to branch %s is forbidden.", pr.BaseRef.Name)
@@ -143,7 +142,7 @@ func requirementDiff(pr *PullRequest, q *config.TideQuery, cc contextChecker) (s
 
 	// Weight incorrect milestone with relatively high diff so that we select the
 	// query for the correct milestone (but choose favor query for correct branch).
-	if q.Milestone != "" && (pr.Milestone == nil || string(pr.Milestone.Title) != q.Milestone) {
+	if milestoneMismatch(pr, q) {
 		diff += 100
 		if desc == "" {
 			desc = fmt.Sprintf(" Must be in milestone %s.", q.Milestone)
@@ -151,22 +150,9 @@ func requirementDiff(pr *PullRequest, q *config.TideQuery, cc contextChecker) (s
 	}
 
 	// Weight incorrect labels and statues with low (normal) diff values.
-	var missingLabels []string
-	for _, l1 := range q.Labels {
-		var found bool
-		for _, l2 := range pr.Labels.Nodes {
-			if string(l2.Name) == l1 {
-				found = true
-				break
-			}
-		}
-		if !found {
-			missingLabels = append(missingLabels, l1)
-		}
-	}
+	missingLabels := missingQueryLabels(pr, q)
 	diff += len(missingLabels)
 	if desc == "" && len(missingLabels) > 0 {
-		sort.Strings(missingLabels)
 		trunced := truncate(missingLabels)
 		if len(trunced) == 1 {
 			desc = fmt.Sprintf(" Needs %s label.", trunced[0])
@@ -175,18 +161,9 @@ func requirementDiff(pr *PullRequest, q *config.TideQuery, cc contextChecker) (s
 		}
 	}
 
-	var presentLabels []string
-	for _, l1 := range q.MissingLabels {
-		for _, l2 := range pr.Labels.Nodes {
-			if string(l2.Name) == l1 {
-				presentLabels = append(presentLabels, l1)
-				break
-			}
-		}
-	}
+	presentLabels := presentQueryMissingLabels(pr, q)
 	diff += len(presentLabels)
 	if desc == "" && len(presentLabels) > 0 {
-		sort.Strings(presentLabels)
 		trunced := truncate(presentLabels)
 		if len(trunced) == 1 {
 			desc = fmt.Sprintf(" Should not have %s label.", trunced[0])
@@ -196,17 +173,9 @@ func requirementDiff(pr *PullRequest, q *config.TideQuery, cc contextChecker) (s
 	}
 
 	// fixing label issues takes precedence over status contexts
-	var contexts []string
-	for _, commit := range pr.Commits.Nodes {
-		if commit.Commit.OID == pr.HeadRefOID {
-			for _, ctx := range unsuccessfulContexts(commit.Commit.Status.Contexts, cc, logrus.New().WithFields(pr.logFields())) {
-				contexts = append(contexts, string(ctx.Context))
-			}
-		}
-	}
+	contexts := failedHeadContexts(pr, cc)
 	diff += len(contexts)
 	if desc == "" && len(contexts) > 0 {
-		sort.Strings(contexts)
 		trunced := truncate(contexts)
 		if len(trunced) == 1 {
 			desc = fmt.Sprintf(" Job %s has not succeeded.", trunced[0])
@@ -221,26 +190,99 @@ func requirementDiff(pr *PullRequest, q *config.TideQuery, cc contextChecker) (s
 	return desc, diff
 }
 
+// branchForbidden tells whether the query excludes the base branch of the PR.
+func branchForbidden(pr *PullRequest, q *config.TideQuery) bool {
+	for _, excludedBranch := range q.ExcludedBranches {
+		if string(pr.BaseRef.Name) == excludedBranch {
+			return true
+		}
+	}
+	// if no whitelist is configured, the target is OK by default
+	if len(q.IncludedBranches) == 0 {
+		return false
+	}
+	for _, includedBranch := range q.IncludedBranches {
+		if string(pr.BaseRef.Name) == includedBranch {
+			return false
+		}
+	}
+	return true
+}
+
+// milestoneMismatch tells whether the query requires a milestone the PR is not in.
+func milestoneMismatch(pr *PullRequest, q *config.TideQuery) bool {
+	return q.Milestone != "" && (pr.Milestone == nil || string(pr.Milestone.Title) != q.Milestone)
+}
+
+// missingQueryLabels returns the sorted labels required by the query that the PR lacks.
+func missingQueryLabels(pr *PullRequest, q *config.TideQuery) []string {
+	var missing []string
+	for _, l1 := range q.Labels {
+		var found bool
+		for _, l2 := range pr.Labels.Nodes {
+			if string(l2.Name) == l1 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, l1)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// presentQueryMissingLabels returns the sorted labels forbidden by the query that the PR has.
+func presentQueryMissingLabels(pr *PullRequest, q *config.TideQuery) []string {
+	var present []string
+	for _, l1 := range q.MissingLabels {
+		for _, l2 := range pr.Labels.Nodes {
+			if string(l2.Name) == l1 {
+				present = append(present, l1)
+				break
+			}
+		}
+	}
+	sort.Strings(present)
+	return present
+}
+
+// failedHeadContexts returns the sorted names of the contexts on the head commit
+// of the PR that have not succeeded.
+func failedHeadContexts(pr *PullRequest, cc contextChecker) []string {
+	var contexts []string
+	for _, commit := range pr.Commits.Nodes {
+		if commit.Commit.OID == pr.HeadRefOID {
+			for _, ctx := range unsuccessfulContexts(commit.Commit.Status.Contexts, cc, logrus.New().WithFields(pr.logFields())) {
+				contexts = append(contexts, string(ctx.Context))
+			}
+		}
+	}
+	sort.Strings(contexts)
+	return contexts
+}
+
 // Returns expected status state and description.
 // If a PR is not mergeable, we have to select a TideQuery to compare it against
 // in order to generate a diff for the status description. We choose the query
 // for the repo that the PR is closest to meeting (as determined by the number
 // of unmet/violated requirements).
-func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]PullRequest, cc contextChecker, blocks blockers.Blockers) (string, string) {
-	if _, ok := pool[prKey(pr)]; !ok {
-		// if the branch is blocked forget checking for a diff
-		blockingIssues := blocks.GetApplicable(string(pr.Repository.Owner.Login), string(pr.Repository.Name), string(pr.BaseRef.Name))
-		var numbers []string
-		for _, issue := range blockingIssues {
-			numbers = append(numbers, strconv.Itoa(issue.Number))
-		}
-		if len(numbers) > 0 {
-			var s string
-			if len(numbers) > 1 {
-				s = "s"
-			}
-			return gitprovider.StatusError, fmt.Sprintf(statusNotInPool, fmt.Sprintf(" Merging is blocked by issue%s %s.", s, strings.Join(numbers, ", ")))
+// If blockers apply to the PR, the status is an error listing them whether the PR
+// is in the pool or not.
+// If the PR is in the pool but the merge window of its branch is closed, the
+// status is pending until the window opens. Otherwise, if priority labels are
+// configured, the description gives its priority.
+func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]PullRequest, cc contextChecker, blocks blockers.Blockers, changedFiles config.ChangedFilesProvider, t *config.Tide, tracker *poolTracker, now time.Time) (string, string) {
+	// if the PR is blocked forget checking for a diff
+	if blockingIssues := prBlockers(blocks, pr, changedFiles); len(blockingIssues) > 0 {
+		desc := fmt.Sprintf(statusNotInPool, blockedDescription(blockingIssues, now))
+		if len(desc) > maxStatusDescriptionLength {
+			desc = desc[:maxStatusDescriptionLength-3] + "..."
 		}
+		return gitprovider.StatusError, desc
+	}
+	if _, ok := pool[prKey(pr)]; !ok {
 		minDiffCount := -1
 		var minDiff string
 		for _, q := range queryMap.ForRepo(string(pr.Repository.Owner.Login), string(pr.Repository.Name)) {
@@ -252,7 +294,10 @@ func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]
 		}
 		return gitprovider.StatusPending, fmt.Sprintf(statusNotInPool, minDiff)
 	}
-	return gitprovider.StatusSuccess, statusInPool
+	if desc := mergeWindowDescription(t, pr, now); desc != "" {
+		return gitprovider.StatusPending, desc
+	}
+	return gitprovider.StatusSuccess, inPoolDescription(t, pr, tracker, now)
 }
 
 // targetURL determines the URL used for more details in the status
@@ -277,11 +322,34 @@ func targetURL(c config.Getter, pr *PullRequest, log *logrus.Entry) string {
 	return link
 }
 
+// recordDryRunStatus records a status update in the history when running in
+// dry-run mode, unless the same update was already recorded for the PR.
+func (sc *statusController) recordDryRunStatus(pr *PullRequest, state, desc string) {
+	if sc.dryRunHistory == nil {
+		return
+	}
+	key := prKey(pr)
+	status := fmt.Sprintf("%s: %s", state, desc)
+	if sc.dryRunStatuses[key] == status {
+		return
+	}
+	sc.dryRunStatuses[key] = status
+	sc.dryRunHistory.Record(
+		poolKey(string(pr.Repository.Owner.Login), string(pr.Repository.Name), string(pr.BaseRef.Name)),
+		DryRunStatus,
+		"",
+		"",
+		prMeta(*pr),
+	)
+}
+
 func (sc *statusController) setStatuses(all []PullRequest, pool map[string]PullRequest, blocks blockers.Blockers) {
 	// queryMap caches which queries match a repo.
 	// Make a new one each sync loop as queries will change.
-	queryMap := sc.config().Tide.Queries.QueryMap()
+	tideConfig := &sc.config().Tide
+	queryMap := tideConfig.Queries.QueryMap()
 	processed := sets.NewString()
+	now := time.Now()
 
 	process := func(pr *PullRequest) {
 		processed.Insert(prKey(pr))
@@ -300,7 +368,11 @@ func (sc *statusController) setStatuses(all []PullRequest, pool map[string]PullR
 			return
 		}
 
-		wantState, wantDesc := expectedStatus(queryMap, pr, pool, cr, blocks)
+		var changedFiles config.ChangedFilesProvider
+		if sc.changedFiles != nil {
+			changedFiles = sc.changedFiles.prChanges(pr)
+		}
+		wantState, wantDesc := expectedStatus(queryMap, pr, pool, cr, blocks, changedFiles, tideConfig, sc.tracker, now)
 		var actualState githubql.StatusState
 		var actualDesc string
 		for _, ctx := range contexts {
@@ -310,6 +382,7 @@ func (sc *statusController) setStatuses(all []PullRequest, pool map[string]PullR
 			}
 		}
 		if wantState != strings.ToLower(string(actualState)) || wantDesc != actualDesc {
+			sc.recordDryRunStatus(pr, wantState, wantDesc)
 			if _, err := sc.ghc.CreateGraphQLStatus(
 				string(pr.Repository.Owner.Login),
 				string(pr.Repository.Name),
@@ -452,6 +525,9 @@ func (sc *statusController) sync(pool map[string]PullRequest, blocks blockers.Bl
 		sc.logger.WithField("duration", duration.String()).Info("Statuses synced.")
 		tideMetrics.statusUpdateDuration.Set(duration.Seconds())
 	}()
+	if sc.changedFiles != nil {
+		defer sc.changedFiles.prune()
+	}
 
 	sc.setStatuses(sc.search(), pool, blocks)
 }
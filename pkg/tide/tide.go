@@ -64,8 +64,12 @@ type githubClient interface {
 	GetCombinedStatus(org, repo, ref string) (*scm.CombinedStatus, error)
 	CreateStatus(org, repo, ref string, s *scm.StatusInput) (*scm.Status, error)
 	GetPullRequestChanges(org, repo string, number int) ([]*scm.Change, error)
+	ListPRCommits(org, repo string, number int) ([]scm.Commit, error)
 	GetRef(string, string, string) (string, error)
 	Merge(string, string, int, gitprovider.MergeDetails) error
+	UpdatePullRequestBranch(org, repo string, number int, expectedHeadSHA string) error
+	GetPullRequest(org, repo string, number int) (*scm.PullRequest, error)
+	CreatePullRequest(org, repo, title, body, head, base string) (int, error)
 	Query(context.Context, interface{}, map[string]interface{}) error
 }
 
@@ -86,9 +90,25 @@ type DefaultController struct {
 	mpClient      metapipeline.Client
 	tektonClient  tektonclient.Interface
 	ns            string
+	dryRun        bool
 
 	sc *statusController
 
+	// failures reports the PRs which fail to merge
+	failures *mergeFailureNotifier
+	// tracker observes how long PRs wait in their pool and the failed batches
+	tracker *poolTracker
+	// expiredBlockers comments on the blocker issues which expired
+	expiredBlockers *expiredBlockerNotifier
+	// behind caches which PRs contain the base SHA of their subpool
+	behind *behindCache
+	// trains caches which PRs merge with the merge train of their subpool
+	trains *trainCache
+
+	// syncLock serialises full syncs and pool syncs so that a pool is never
+	// synced twice at the same time.
+	syncLock sync.Mutex
+
 	m     sync.Mutex
 	pools []Pool
 
@@ -111,6 +131,8 @@ const (
 	Merge               = "MERGE"
 	MergeBatch          = "MERGE_BATCH"
 	PoolBlocked         = "BLOCKED"
+	// MergeWindowClosed means PRs could be merged but the merge window is closed
+	MergeWindowClosed = "MERGE_WINDOW_CLOSED"
 )
 
 // recordableActions is the subset of actions that we keep historical record of.
@@ -120,6 +142,9 @@ var recordableActions = map[Action]bool{
 	TriggerBatch: true,
 	Merge:        true,
 	MergeBatch:   true,
+	UpdateBranch: true,
+	TriggerTrain: true,
+	MergeTrain:   true,
 }
 
 // Pool represents information about a tide pool. There is one for every
@@ -135,6 +160,9 @@ type Pool struct {
 	SuccessPRs []PullRequest
 	PendingPRs []PullRequest
 	MissingPRs []PullRequest
+	// BlockedPRs are the PRs taken out of the pool by blockers scoped to
+	// their files or labels.
+	BlockedPRs []PullRequest
 
 	// Empty if there is no pending batch.
 	BatchPending []PullRequest
@@ -150,13 +178,19 @@ type Pool struct {
 var (
 	tideMetrics = struct {
 		// Per pool
-		pooledPRs  *prometheus.GaugeVec
-		updateTime *prometheus.GaugeVec
-		merges     *prometheus.HistogramVec
+		pooledPRs        *prometheus.GaugeVec
+		pooledPRsByState *prometheus.GaugeVec
+		updateTime       *prometheus.GaugeVec
+		merges           *prometheus.HistogramVec
+		timeToMerge      *prometheus.HistogramVec
+		retests          *prometheus.CounterVec
+		batches          *prometheus.CounterVec
 
 		// Singleton
 		syncDuration         prometheus.Gauge
 		statusUpdateDuration prometheus.Gauge
+		searchCost           prometheus.Counter
+		searchRemaining      prometheus.Gauge
 	}{
 		pooledPRs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "pooledprs",
@@ -166,6 +200,15 @@ var (
 			"repo",
 			"branch",
 		}),
+		pooledPRsByState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pooledprs_by_state",
+			Help: "Number of PRs in each Tide pool by state: success, pending, missing or blocked.",
+		}, []string{
+			"org",
+			"repo",
+			"branch",
+			"state",
+		}),
 		updateTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "updatetime",
 			Help: "The last time each subpool was synced. (Used to determine 'pooledprs' freshness.)",
@@ -185,6 +228,36 @@ var (
 			"branch",
 		}),
 
+		timeToMerge: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "timetomerge",
+			Help:    "Histogram of the seconds PRs spent in their Tide pool before they merged.",
+			Buckets: prometheus.ExponentialBuckets(60, 2, 14),
+		}, []string{
+			"org",
+			"repo",
+			"branch",
+		}),
+
+		retests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retests",
+			Help: "Number of times Tide triggered the tests of a single PR, a batch or a merge train.",
+		}, []string{
+			"org",
+			"repo",
+			"branch",
+			"type",
+		}),
+
+		batches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "batches",
+			Help: "Number of batches by outcome: triggered, test_failed, merged or merge_failed.",
+		}, []string{
+			"org",
+			"repo",
+			"branch",
+			"outcome",
+		}),
+
 		syncDuration: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "syncdur",
 			Help: "The duration of the last loop of the sync controller.",
@@ -194,51 +267,114 @@ var (
 			Name: "statusupdatedur",
 			Help: "The duration of the last loop of the status update controller.",
 		}),
+
+		searchCost: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "searchcost",
+			Help: "The GitHub GraphQL rate limit points spent by the searches of Tide.",
+		}),
+
+		searchRemaining: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "searchremaining",
+			Help: "The GitHub GraphQL rate limit points remaining after the last search of Tide.",
+		}),
 	}
 )
 
 func init() {
 	prometheus.MustRegister(tideMetrics.pooledPRs)
+	prometheus.MustRegister(tideMetrics.pooledPRsByState)
 	prometheus.MustRegister(tideMetrics.updateTime)
 	prometheus.MustRegister(tideMetrics.merges)
+	prometheus.MustRegister(tideMetrics.timeToMerge)
+	prometheus.MustRegister(tideMetrics.retests)
+	prometheus.MustRegister(tideMetrics.batches)
 	prometheus.MustRegister(tideMetrics.syncDuration)
 	prometheus.MustRegister(tideMetrics.statusUpdateDuration)
+	prometheus.MustRegister(tideMetrics.searchCost)
+	prometheus.MustRegister(tideMetrics.searchRemaining)
+}
+
+// State holds what a controller remembers about its pools across syncs. A controller
+// which is recreated, such as the controller of a GitHub App installation on every
+// sync, is given the state of the one it replaces so that nothing is forgotten.
+type State struct {
+	tracker  *poolTracker
+	failures *mergeFailureState
+	trains   *trainCache
+	behind   *behindCache
+}
+
+// NewState creates the state of a controller which has not synced yet
+func NewState() *State {
+	return &State{
+		tracker:  newPoolTracker(),
+		failures: newMergeFailureState(),
+		trains:   newTrainCache(),
+		behind:   newBehindCache(),
+	}
 }
 
-// NewController makes a DefaultController out of the given clients.
-func NewController(ghcSync, ghcStatus *gitprovider.Client, prowJobClient prowJobClient, mpClient metapipeline.Client, tektonClient tektonclient.Interface, ns string, cfg config.Getter, gc git.Client, maxRecordsPerPool int, opener io.Opener, historyURI, statusURI string, logger *logrus.Entry) (*DefaultController, error) {
+// NewController makes a DefaultController out of the given clients. A new state is
+// created if state is nil.
+func NewController(ghcSync, ghcStatus *gitprovider.Client, prowJobClient prowJobClient, mpClient metapipeline.Client, tektonClient tektonclient.Interface, ns string, cfg config.Getter, gc git.Client, maxRecordsPerPool int, opener io.Opener, historyURI, statusURI string, dryRun bool, state *State, logger *logrus.Entry) (*DefaultController, error) {
 	if logger == nil {
 		logger = logrus.NewEntry(logrus.StandardLogger())
 	}
+	if state == nil {
+		state = NewState()
+	}
 	hist, err := history.New(maxRecordsPerPool, opener, historyURI)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing history client from %q: %v", historyURI, err)
 	}
+	var syncClient, statusClient githubClient = ghcSync, ghcStatus
+	if dryRun {
+		logger.Warn("Running in dry-run mode: no PRs will be merged, no pipelines triggered and no statuses set.")
+		syncClient = newDryRunGitHubClient(ghcSync, logger)
+		statusClient = newDryRunGitHubClient(ghcStatus, logger)
+		prowJobClient = newDryRunProwJobClient(prowJobClient, logger)
+	}
+	tracker := state.tracker
 	sc := &statusController{
 		logger:         logger.WithField("controller", "status-update"),
-		ghc:            ghcStatus,
+		ghc:            statusClient,
 		config:         cfg,
 		newPoolPending: make(chan bool, 1),
 		shutDown:       make(chan bool),
 		opener:         opener,
 		path:           statusURI,
+		changedFiles: &changedFilesAgent{
+			ghc:             statusClient,
+			nextChangeCache: make(map[changeCacheKey][]string),
+		},
+		tracker: tracker,
+	}
+	if dryRun {
+		sc.dryRunHistory = hist
+		sc.dryRunStatuses = map[string]string{}
 	}
 	go sc.run()
 	return &DefaultController{
 		logger:        logger.WithField("controller", "sync"),
-		ghc:           ghcSync,
+		ghc:           syncClient,
 		prowJobClient: prowJobClient,
 		mpClient:      mpClient,
 		tektonClient:  tektonClient,
 		ns:            ns,
+		dryRun:        dryRun,
 		config:        cfg,
 		gc:            gc,
 		sc:            sc,
+		failures:      newMergeFailureNotifier(ghcSync, cfg, logger, dryRun, state.failures),
+		tracker:       tracker,
+		behind:        state.behind,
+		trains:        state.trains,
 		changedFiles: &changedFilesAgent{
-			ghc:             ghcSync,
+			ghc:             syncClient,
 			nextChangeCache: make(map[changeCacheKey][]string),
 		},
-		History: hist,
+		expiredBlockers: newExpiredBlockerNotifier(ghcSync, logger, dryRun),
+		History:         hist,
 	}, nil
 }
 
@@ -293,6 +429,9 @@ func contextsToStrings(contexts []Context) []string {
 
 // Sync runs one sync iteration.
 func (c *DefaultController) Sync() error {
+	c.syncLock.Lock()
+	defer c.syncLock.Unlock()
+
 	start := time.Now()
 	defer func() {
 		duration := time.Since(start)
@@ -325,13 +464,20 @@ func (c *DefaultController) Sync() error {
 	var err error
 	if len(prs) > 0 {
 		start := time.Now()
-		pjList, err := c.prowJobClient.List(metav1.ListOptions{})
-		if err != nil {
-			c.logger.WithField("duration", time.Since(start).String()).Debug("Failed to list ProwJobs from the cluster.")
-			return err
+		repos := sets.NewString()
+		for _, pr := range prs {
+			repos.Insert(string(pr.Repository.Owner.Login) + "/" + string(pr.Repository.Name))
+		}
+		for _, orgRepo := range repos.List() {
+			parts := strings.SplitN(orgRepo, "/", 2)
+			repoPJs, err := c.listRepoPipelines(parts[0], parts[1])
+			if err != nil {
+				c.logger.WithField("duration", time.Since(start).String()).Debug("Failed to list ProwJobs from the cluster.")
+				return err
+			}
+			pjs = append(pjs, repoPJs...)
 		}
 		c.logger.WithField("duration", time.Since(start).String()).Debug("Listed ProwJobs from the cluster.")
-		pjs = pjList.Items
 
 		if label := c.config().Tide.BlockerLabel; label != "" {
 			c.logger.Debugf("Searching for blocking issues (label %q).", label)
@@ -345,6 +491,7 @@ func (c *DefaultController) Sync() error {
 			if err != nil {
 				return err
 			}
+			c.expiredBlockers.notify(blocks.Expired)
 		}
 	}
 	// Partition PRs into subpools and filter out non-pool PRs.
@@ -384,15 +531,18 @@ func (c *DefaultController) Sync() error {
 		pools = append(pools, pool)
 	}
 	sortPools(pools)
+	c.tracker.prune(filteredPools)
 	c.m.Lock()
 	c.pools = pools
 	// While we're locked, rerun failed-but-rerunnable PipelineRuns.
-	c.logger.WithField("duration", time.Since(start).String()).Debug("Rerunning PipelineRuns failed due to race condition.")
-	err = rerunPipelineRunsWithRaceConditionFailure(c.tektonClient, c.ns, c.logger)
-	if err != nil {
-		c.logger.WithError(err).Error("Error rerunning PipelineRuns failed by Tekton race condition")
+	if !c.dryRun {
+		c.logger.WithField("duration", time.Since(start).String()).Debug("Rerunning PipelineRuns failed due to race condition.")
+		err = rerunPipelineRunsWithRaceConditionFailure(c.tektonClient, c.ns, c.logger)
+		if err != nil {
+			c.logger.WithError(err).Error("Error rerunning PipelineRuns failed by Tekton race condition")
+		}
+		c.logger.WithField("duration", time.Since(start).String()).Debug("Finished rerunning PipelineRuns failed due to race condition.")
 	}
-	c.logger.WithField("duration", time.Since(start).String()).Debug("Finished rerunning PipelineRuns failed due to race condition.")
 	c.m.Unlock()
 
 	c.History.Flush()
@@ -621,23 +771,21 @@ func unsuccessfulContexts(contexts []Context, cc contextChecker, log *logrus.Ent
 	return failed
 }
 
-func pickSmallestPassingNumber(log *logrus.Entry, ghc githubClient, prs []PullRequest, cc contextChecker) (bool, PullRequest) {
-	smallestNumber := -1
-	var smallestPR PullRequest
-	for _, pr := range prs {
-		if smallestNumber != -1 && int(pr.Number) >= smallestNumber {
-			continue
-		}
+// pickHighestPriorityPassing picks the passing PR to merge or test next, by
+// priority, then age.
+func pickHighestPriorityPassing(log *logrus.Entry, ghc githubClient, prs []PullRequest, cc contextChecker, t *config.Tide, tracker *poolTracker, now time.Time) (bool, PullRequest) {
+	sorted := append([]PullRequest(nil), prs...)
+	sortByPriority(t, sorted, tracker, now)
+	for _, pr := range sorted {
 		if len(pr.Commits.Nodes) < 1 {
 			continue
 		}
 		if !isPassingTests(log, ghc, pr, cc) {
 			continue
 		}
-		smallestNumber = int(pr.Number)
-		smallestPR = pr
+		return true, pr
 	}
-	return smallestNumber > -1, smallestPR
+	return false, PullRequest{}
 }
 
 // accumulateBatch returns a list of PRs that can be merged after passing batch
@@ -808,8 +956,8 @@ func (c *DefaultController) pickBatch(sp subpool, cc contextChecker) ([]PullRequ
 		sp.log.Debug("Batch merges disabled by configuration in this repo.")
 		return nil, nil
 	}
-	// we must choose the oldest PRs for the batch
-	sort.Slice(sp.prs, func(i, j int) bool { return sp.prs[i].Number < sp.prs[j].Number })
+	// we must choose the highest priority, then oldest, PRs for the batch
+	sortByPriority(&c.config().Tide, sp.prs, c.tracker, time.Now())
 
 	var candidates []PullRequest
 	for _, pr := range sp.prs {
@@ -824,23 +972,11 @@ func (c *DefaultController) pickBatch(sp subpool, cc contextChecker) ([]PullRequ
 	}
 	sp.log.Debugf("of %d possible PRs, %d are passing tests", len(sp.prs), len(candidates))
 
-	r, err := c.gc.Clone(sp.org + "/" + sp.repo)
+	r, err := c.cloneAt(sp)
 	if err != nil {
 		return nil, err
 	}
 	defer r.Clean()
-	if err := r.Config("user.name", "prow"); err != nil {
-		return nil, err
-	}
-	if err := r.Config("user.email", "prow@localhost"); err != nil {
-		return nil, err
-	}
-	if err := r.Config("commit.gpgsign", "false"); err != nil {
-		sp.log.Warningf("Cannot set gpgsign=false in gitconfig: %v", err)
-	}
-	if err := r.Checkout(sp.sha); err != nil {
-		return nil, err
-	}
 
 	var res []PullRequest
 	for _, pr := range candidates {
@@ -859,6 +995,31 @@ func (c *DefaultController) pickBatch(sp subpool, cc contextChecker) ([]PullRequ
 	return res, nil
 }
 
+// cloneAt clones the repo of the subpool and checks out its base SHA, ready to
+// merge PRs locally. The caller must clean up the clone.
+func (c *DefaultController) cloneAt(sp subpool) (*git.Repo, error) {
+	r, err := c.gc.Clone(sp.org + "/" + sp.repo)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Config("user.name", "prow"); err != nil {
+		r.Clean()
+		return nil, err
+	}
+	if err := r.Config("user.email", "prow@localhost"); err != nil {
+		r.Clean()
+		return nil, err
+	}
+	if err := r.Config("commit.gpgsign", "false"); err != nil {
+		sp.log.Warningf("Cannot set gpgsign=false in gitconfig: %v", err)
+	}
+	if err := r.Checkout(sp.sha); err != nil {
+		r.Clean()
+		return nil, err
+	}
+	return r, nil
+}
+
 func checkMergeLabels(pr PullRequest, squash, rebase, merge string, method gitprovider.PullRequestMergeType) (gitprovider.PullRequestMergeType, error) {
 	labelCount := 0
 	for _, prlabel := range pr.Labels.Nodes {
@@ -886,10 +1047,15 @@ func (c *DefaultController) prepareMergeDetails(commitTemplates config.TideMerge
 		MergeMethod: string(mergeMethod),
 	}
 
+	if commitTemplates.Title == nil && commitTemplates.Body == nil {
+		return ghMergeDetails
+	}
+	data := c.mergeCommitTemplateData(pr)
+
 	if commitTemplates.Title != nil {
 		var b bytes.Buffer
 
-		if err := commitTemplates.Title.Execute(&b, pr); err != nil {
+		if err := commitTemplates.Title.Execute(&b, data); err != nil {
 			c.logger.Errorf("error executing commit title template: %v", err)
 		} else {
 			ghMergeDetails.CommitTitle = b.String()
@@ -899,7 +1065,7 @@ func (c *DefaultController) prepareMergeDetails(commitTemplates config.TideMerge
 	if commitTemplates.Body != nil {
 		var b bytes.Buffer
 
-		if err := commitTemplates.Body.Execute(&b, pr); err != nil {
+		if err := commitTemplates.Body.Execute(&b, data); err != nil {
 			c.logger.Errorf("error executing commit body template: %v", err)
 		} else {
 			ghMergeDetails.CommitMessage = b.String()
@@ -910,6 +1076,16 @@ func (c *DefaultController) prepareMergeDetails(commitTemplates config.TideMerge
 }
 
 func (c *DefaultController) mergePRs(sp subpool, prs []PullRequest) error {
+	if len(prs) > 1 && !c.dryRun && c.config().Tide.AtomicBatch(sp.org, sp.repo) {
+		return c.mergeAtomicBatch(sp, prs)
+	}
+	_, err := c.mergeEach(sp, prs, false)
+	return err
+}
+
+// mergeEach merges the PRs one at a time and returns the numbers of the merged
+// PRs. If stopOnError is true no more PRs are merged once one fails.
+func (c *DefaultController) mergeEach(sp subpool, prs []PullRequest, stopOnError bool) ([]int, error) {
 	var merged, failed []int
 	defer func() {
 		if len(merged) == 0 {
@@ -922,35 +1098,37 @@ func (c *DefaultController) mergePRs(sp subpool, prs []PullRequest) error {
 	log := sp.log.WithField("merge-targets", prNumbers(prs))
 	for i, pr := range prs {
 		log := log.WithFields(pr.logFields())
-		mergeMethod := c.config().Tide.MergeMethod(sp.org, sp.repo)
-		commitTemplates := c.config().Tide.MergeCommitTemplate(sp.org, sp.repo)
-		squashLabel := c.config().Tide.SquashLabel
-		rebaseLabel := c.config().Tide.RebaseLabel
-		mergeLabel := c.config().Tide.MergeLabel
-		if squashLabel != "" || rebaseLabel != "" || mergeLabel != "" {
-			var err error
-			mergeMethod, err = checkMergeLabels(pr, squashLabel, rebaseLabel, mergeLabel, mergeMethod)
-			if err != nil {
-				log.WithError(err).Error("Merge failed.")
-				errs = append(errs, err)
-				failed = append(failed, int(pr.Number))
-				continue
+		commitTemplates := c.config().Tide.MergeCommitTemplate(sp.org, sp.repo, sp.branch)
+		mergeMethod, err := c.mergeMethod(sp, pr)
+		if err != nil {
+			log.WithError(err).Error("Merge failed.")
+			c.failures.mergeFailed(sp, pr, err)
+			errs = append(errs, err)
+			failed = append(failed, int(pr.Number))
+			if stopOnError {
+				break
 			}
+			continue
 		}
 
+		var mergeErr error
+		ghMergeDetails := c.prepareMergeDetails(commitTemplates, pr, mergeMethod)
 		keepTrying, err := tryMerge(func() error {
-			ghMergeDetails := c.prepareMergeDetails(commitTemplates, pr, mergeMethod)
-			return c.ghc.Merge(sp.org, sp.repo, int(pr.Number), ghMergeDetails)
+			mergeErr = c.ghc.Merge(sp.org, sp.repo, int(pr.Number), ghMergeDetails)
+			return mergeErr
 		})
 		if err != nil {
 			log.WithError(err).Error("Merge failed.")
+			c.failures.mergeFailed(sp, pr, mergeErr)
 			errs = append(errs, err)
 			failed = append(failed, int(pr.Number))
 		} else {
 			log.Info("Merged.")
+			c.failures.merged(sp, pr)
+			c.tracker.merged(sp, pr)
 			merged = append(merged, int(pr.Number))
 		}
-		if !keepTrying {
+		if !keepTrying || (stopOnError && err != nil) {
 			break
 		}
 		// If we successfully merged this PR and have more to merge, sleep to give
@@ -961,7 +1139,7 @@ func (c *DefaultController) mergePRs(sp subpool, prs []PullRequest) error {
 	}
 
 	if len(errs) == 0 {
-		return nil
+		return merged, nil
 	}
 
 	// Construct a more informative error.
@@ -972,7 +1150,7 @@ func (c *DefaultController) mergePRs(sp subpool, prs []PullRequest) error {
 			batch = fmt.Sprintf("%s, partial merge %v", batch, merged)
 		}
 	}
-	return fmt.Errorf("failed merging %v%s: %v", failed, batch, errorutil.NewAggregate(errs...))
+	return merged, fmt.Errorf("failed merging %v%s: %v", failed, batch, errorutil.NewAggregate(errs...))
 }
 
 // tryMerge attempts 1 merge and returns a bool indicating if we should try
@@ -986,13 +1164,10 @@ func tryMerge(mergeFunc func() error) (bool, error) {
 			// Successful merge!
 			return true, nil
 		}
-		// TODO: Add a config option to abort batches if a PR in the batch
-		// cannot be merged for any reason. This would skip merging
-		// not just the changed PR, but also the other PRs in the batch.
-		// This shouldn't be the default behavior as merging batches is high
-		// priority and this is unlikely to be problematic.
-		// Note: We would also need to be able to roll back any merges for the
-		// batch that were already successfully completed before the failure.
+		// Batches of repos configured with atomic_batch stop merging after
+		// the first failure and roll back the PRs already merged, see
+		// mergeAtomicBatch. This shouldn't be the default behavior as merging
+		// batches is high priority and this is unlikely to be problematic.
 		// Ref: https://github.com/kubernetes/test-infra/issues/10621
 		if _, ok := err.(gitprovider.ModifiedHeadError); ok {
 			// This is a possible source of incorrect behavior. If someone
@@ -1111,26 +1286,59 @@ func (c *DefaultController) trigger(sp subpool, presubmits map[int][]config.Pres
 }
 
 func (c *DefaultController) takeAction(sp subpool, batchPending, successes, pendings, missings, batchMerges []PullRequest, missingSerialTests map[int][]config.Presubmit) (Action, []PullRequest, error) {
+	tideConfig := &c.config().Tide
+	now := time.Now()
+	// Outside of the merge windows only exempt PRs may be merged, but we keep
+	// testing so the pool is ready when the window opens.
+	windows := tideConfig.MergeWindowsFor(sp.org, sp.repo, sp.branch)
+	windowOpen, _, _ := windows.Check(now)
+	wait := Wait
+	// Merge trains replace the batches and serial merges of their repos.
+	if depth := tideConfig.MergeTrainDepth(sp.org, sp.repo); depth > 0 && len(sp.presubmits) > 0 {
+		return c.takeTrainAction(sp, depth, func(prs []PullRequest) bool {
+			return windowOpen || len(exemptPRs(windows, prs)) == len(prs)
+		})
+	}
 	// Merge the batch!
 	if len(batchMerges) > 0 {
-		return MergeBatch, batchMerges, c.mergePRs(sp, batchMerges)
+		if windowOpen || len(exemptPRs(windows, batchMerges)) == len(batchMerges) {
+			return MergeBatch, batchMerges, c.mergePRs(sp, batchMerges)
+		}
+		wait = MergeWindowClosed
 	}
 	// Do not merge PRs while waiting for a batch to complete. We don't want to
 	// invalidate the old batch result.
 	if len(successes) > 0 && len(batchPending) == 0 {
-		if ok, pr := pickSmallestPassingNumber(sp.log, c.ghc, successes, sp.cc); ok {
+		candidates := successes
+		if !windowOpen {
+			candidates = exemptPRs(windows, successes)
+		}
+		// Branches requiring up to date PRs only accept PRs containing the base
+		// branch so PRs behind it are updated, one at a time as each merge puts
+		// the others behind again.
+		upToDate, behind, err := c.splitBehindBase(sp, candidates)
+		if err != nil {
+			return wait, nil, err
+		}
+		if ok, pr := pickHighestPriorityPassing(sp.log, c.ghc, upToDate, sp.cc, tideConfig, c.tracker, now); ok {
 			return Merge, []PullRequest{pr}, c.mergePRs(sp, []PullRequest{pr})
 		}
+		if ok, pr := pickHighestPriorityPassing(sp.log, c.ghc, behind, sp.cc, tideConfig, c.tracker, now); ok {
+			return UpdateBranch, []PullRequest{pr}, c.updateBranch(sp, pr)
+		}
+		if len(candidates) < len(successes) {
+			wait = MergeWindowClosed
+		}
 	}
 	// If no presubmits are configured, just wait.
 	if len(sp.presubmits) == 0 {
-		return Wait, nil, nil
+		return wait, nil, nil
 	}
 	// If we have no batch, trigger one.
 	if len(sp.prs) > 1 && len(batchPending) == 0 {
 		batch, err := c.pickBatch(sp, sp.cc)
 		if err != nil {
-			return Wait, nil, err
+			return wait, nil, err
 		}
 		if len(batch) > 1 {
 			return TriggerBatch, batch, c.trigger(sp, sp.presubmits, batch)
@@ -1138,11 +1346,11 @@ func (c *DefaultController) takeAction(sp subpool, batchPending, successes, pend
 	}
 	// If we have no serial jobs pending or successful, trigger one.
 	if len(missings) > 0 && len(pendings) == 0 && len(successes) == 0 {
-		if ok, pr := pickSmallestPassingNumber(sp.log, c.ghc, missings, sp.cc); ok {
+		if ok, pr := pickHighestPriorityPassing(sp.log, c.ghc, missings, sp.cc, tideConfig, c.tracker, now); ok {
 			return Trigger, []PullRequest{pr}, c.trigger(sp, missingSerialTests, []PullRequest{pr})
 		}
 	}
-	return Wait, nil, nil
+	return wait, nil, nil
 }
 
 // changedFilesAgent queries and caches the names of files changed by PRs.
@@ -1245,6 +1453,13 @@ func (c *DefaultController) presubmitsByPull(sp *subpool) (map[int][]config.Pres
 
 func (c *DefaultController) syncSubpool(sp subpool, blocks []blockers.Blocker) (Pool, error) {
 	sp.log.Infof("Syncing subpool: %d PRs, %d PJs.", len(sp.prs), len(sp.pjs))
+	// Blockers scoped to paths or labels only take the PRs they apply to out
+	// of the pool, the others block the whole pool.
+	poolBlocked := blocksPool(blocks)
+	var blocked []PullRequest
+	if !poolBlocked {
+		sp.prs, blocked = c.splitBlocked(sp, blocks)
+	}
 	successes, pendings, missings, missingSerialTests := accumulate(sp.presubmits, sp.prs, sp.pjs, sp.log)
 	batchMerge, batchPending := accumulateBatch(sp.presubmits, sp.prs, sp.pjs, sp.log)
 	sp.log.WithFields(logrus.Fields{
@@ -1254,22 +1469,32 @@ func (c *DefaultController) syncSubpool(sp subpool, blocks []blockers.Blocker) (
 		"batch-passing": prNumbers(batchMerge),
 		"batch-pending": prNumbers(batchPending),
 	}).Info("Subpool accumulated.")
+	if poolBlocked {
+		c.tracker.observe(sp, nil, nil, nil, sp.prs)
+	} else {
+		c.tracker.observe(sp, successes, pendings, missings, blocked)
+	}
 
 	var act Action
 	var targets []PullRequest
 	var err error
 	var errorString string
-	if len(blocks) > 0 {
+	if poolBlocked {
 		act = PoolBlocked
 	} else {
 		act, targets, err = c.takeAction(sp, batchPending, successes, pendings, missings, batchMerge, missingSerialTests)
 		if err != nil {
 			errorString = err.Error()
 		}
+		countAction(sp, act, err)
 		if recordableActions[act] {
+			action := string(act)
+			if c.dryRun {
+				action = dryRunPrefix + action
+			}
 			c.History.Record(
 				poolKey(sp.org, sp.repo, sp.branch),
-				string(act),
+				action,
 				sp.sha,
 				errorString,
 				prMeta(targets...),
@@ -1281,7 +1506,7 @@ func (c *DefaultController) syncSubpool(sp subpool, blocks []blockers.Blocker) (
 		"action":  string(act),
 		"targets": prNumbers(targets),
 	}).Info("Subpool synced.")
-	tideMetrics.pooledPRs.WithLabelValues(sp.org, sp.repo, sp.branch).Set(float64(len(sp.prs)))
+	tideMetrics.pooledPRs.WithLabelValues(sp.org, sp.repo, sp.branch).Set(float64(len(sp.prs) + len(blocked)))
 	tideMetrics.updateTime.WithLabelValues(sp.org, sp.repo, sp.branch).Set(float64(time.Now().Unix()))
 	return Pool{
 			Org:    sp.org,
@@ -1291,6 +1516,7 @@ func (c *DefaultController) syncSubpool(sp subpool, blocks []blockers.Blocker) (
 			SuccessPRs: successes,
 			PendingPRs: pendings,
 			MissingPRs: missings,
+			BlockedPRs: blocked,
 
 			BatchPending: batchPending,
 
@@ -1360,6 +1586,26 @@ func poolKey(org, repo, branch string) string {
 	return fmt.Sprintf("%s/%s:%s", org, repo, branch)
 }
 
+// listRepoPipelines lists the pipelines of a repository. They are selected by the labels of their
+// repository so that a cached plumber looks them up in its index.
+func (c *DefaultController) listRepoPipelines(org, repo string) ([]plumber.PipelineOptions, error) {
+	opts := metav1.ListOptions{}
+	if selector, err := plumber.RepoSelector(org, repo); err == nil {
+		opts.LabelSelector = selector
+	}
+	pjList, err := c.prowJobClient.List(opts)
+	if err != nil || pjList == nil {
+		return nil, err
+	}
+	var pjs []plumber.PipelineOptions
+	for _, pj := range pjList.Items {
+		if refs := pj.Spec.Refs; refs != nil && refs.Org == org && refs.Repo == repo {
+			pjs = append(pjs, pj)
+		}
+	}
+	return pjs, nil
+}
+
 // dividePool splits up the list of pull requests and prow jobs into a group
 // per repo and branch. It only keeps ProwJobs that match the latest branch.
 func (c *DefaultController) dividePool(pool map[string]PullRequest, pjs []plumber.PipelineOptions) (map[string]*subpool, error) {
@@ -1461,7 +1707,11 @@ type PullRequest struct {
 	}
 	Body      githubql.String
 	Title     githubql.String
+	CreatedAt githubql.DateTime
 	UpdatedAt githubql.DateTime
+
+	// IsCrossRepository is true if the head branch is in a fork
+	IsCrossRepository githubql.Boolean
 }
 
 // Commit holds graphql data about commits and which contexts they have
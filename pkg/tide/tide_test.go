@@ -482,9 +482,18 @@ type fgc struct {
 	merged    int
 	setStatus bool
 	mergeErrs map[int]error
+	updateErr error
+	updated   []int
+	commits   map[int][]scm.Commit
 
 	expectedSHA    string
 	combinedStatus map[string]string
+
+	// mergeFunc merges the PRs instead of just counting them if set
+	mergeFunc    func(number int) error
+	pullRequests map[int]*scm.PullRequest
+	// opened holds the head branches of the PRs opened with CreatePullRequest
+	opened []string
 }
 
 func (f *fgc) GetRef(o, r, ref string) (string, error) {
@@ -511,10 +520,39 @@ func (f *fgc) Merge(org, repo string, number int, details github.MergeDetails) e
 	if err, ok := f.mergeErrs[number]; ok {
 		return err
 	}
+	if f.mergeFunc != nil {
+		if err := f.mergeFunc(number); err != nil {
+			return err
+		}
+	}
 	f.merged++
 	return nil
 }
 
+func (f *fgc) GetPullRequest(org, repo string, number int) (*scm.PullRequest, error) {
+	if pr, ok := f.pullRequests[number]; ok {
+		return pr, nil
+	}
+	return nil, fmt.Errorf("no PR #%d", number)
+}
+
+func (f *fgc) CreatePullRequest(org, repo, title, body, head, base string) (int, error) {
+	f.opened = append(f.opened, head)
+	return 100 + len(f.opened), nil
+}
+
+func (f *fgc) ListPRCommits(org, repo string, number int) ([]scm.Commit, error) {
+	return f.commits[number], nil
+}
+
+func (f *fgc) UpdatePullRequestBranch(org, repo string, number int, expectedHeadSHA string) error {
+	if f.updateErr != nil {
+		return f.updateErr
+	}
+	f.updated = append(f.updated, number)
+	return nil
+}
+
 func (f *fgc) CreateGraphQLStatus(org, repo, ref string, s *github.Status) (*scm.Status, error) {
 	switch s.State {
 	case github.StatusSuccess, github.StatusError, github.StatusPending, github.StatusFailure:
@@ -710,6 +748,25 @@ func TestDividePool(t *testing.T) {
 	}
 }
 
+func TestListRepoPipelines(t *testing.T) {
+	fakePlumberClient := fake.NewPlumber()
+	for _, refs := range []plumber.Refs{{Org: "o", Repo: "r"}, {Org: "o", Repo: "other"}, {Org: "other", Repo: "r"}} {
+		refs := refs
+		fakePlumberClient.Pipelines = append(fakePlumberClient.Pipelines, &plumber.PipelineOptions{
+			Spec: plumber.PipelineOptionsSpec{Type: plumber.PresubmitJob, Refs: &refs},
+		})
+	}
+	c := &DefaultController{prowJobClient: fakePlumberClient}
+
+	pjs, err := c.listRepoPipelines("o", "r")
+	if err != nil {
+		t.Fatalf("Error listing the pipelines: %v", err)
+	}
+	if len(pjs) != 1 || pjs[0].Spec.Refs.Org != "o" || pjs[0].Spec.Refs.Repo != "r" {
+		t.Errorf("Expected the pipeline of o/r, got %+v.", pjs)
+	}
+}
+
 func TestPickBatch(t *testing.T) {
 	// TODO: Remove once #564 is fixed and batch builds can work again. (APB)
 	t.Skip("Skipping TestPickBatch until #564 is fixed and batch builds can work again")
@@ -931,6 +988,7 @@ func TestTakeAction(t *testing.T) {
 		batchMerges  []int
 		presubmits   map[int][]config.Presubmit
 		mergeErrs    map[int]error
+		mergeWindows map[string]config.TideMergeWindows
 
 		merged           int
 		triggered        int
@@ -1214,6 +1272,44 @@ func TestTakeAction(t *testing.T) {
 			action:      MergeBatch,
 			expectErr:   true,
 		},
+		{
+			name: "merge window closed, should not merge serial",
+
+			successes: []int{1, 3},
+			presubmits: map[int][]config.Presubmit{
+				100: {
+					{Reporter: config.Reporter{Context: "foo"}},
+				},
+			},
+			mergeWindows: map[string]config.TideMergeWindows{
+				"o/r": {Freezes: []config.TideMergeFreeze{{Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour), Reason: "release"}}},
+			},
+			merged:    0,
+			triggered: 0,
+			action:    MergeWindowClosed,
+		},
+		{
+			name: "merge window closed, should not merge batch",
+
+			batchMerges: []int{1, 2, 3},
+			mergeWindows: map[string]config.TideMergeWindows{
+				"o/r": {Freezes: []config.TideMergeFreeze{{Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour), Reason: "release"}}},
+			},
+			merged:    0,
+			triggered: 0,
+			action:    MergeWindowClosed,
+		},
+		{
+			name: "merge window of another branch closed, should merge",
+
+			successes: []int{1, 3},
+			mergeWindows: map[string]config.TideMergeWindows{
+				"o/r:release": {Freezes: []config.TideMergeFreeze{{Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)}}},
+			},
+			merged:    1,
+			triggered: 0,
+			action:    Merge,
+		},
 	}
 
 	for _, tc := range testcases {
@@ -1246,6 +1342,7 @@ func TestTakeAction(t *testing.T) {
 			); err != nil {
 				t.Fatalf("failed to set presubmits: %v", err)
 			}
+			cfg.Tide.MergeWindows = tc.mergeWindows
 			ca.Set(cfg)
 			if len(tc.presubmits) > 0 {
 				for i := 0; i <= 8; i++ {
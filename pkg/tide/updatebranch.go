@@ -0,0 +1,148 @@
+package tide
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/git"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+)
+
+// UpdateBranch means the base branch was merged into a passing PR which was behind it
+const UpdateBranch = "UPDATE_BRANCH"
+
+// strictBranch returns true if the branch protection of the subpool requires PRs
+// to be up to date with the base branch before they are merged.
+func (c *DefaultController) strictBranch(sp subpool) bool {
+	policy, err := c.config().GetBranchProtection(sp.org, sp.repo, sp.branch)
+	if err != nil {
+		sp.log.WithError(err).Warn("Cannot get the branch protection policy.")
+		return false
+	}
+	if policy == nil || policy.RequiredStatusChecks == nil || policy.RequiredStatusChecks.Strict == nil {
+		return false
+	}
+	return *policy.RequiredStatusChecks.Strict
+}
+
+// behindCache remembers which PR heads contain the base SHA of their subpool. As
+// both are commits the answer never changes, so the repository is only cloned for
+// the heads and base SHAs which were not seen yet. The heads of a subpool are
+// forgotten when its base SHA moves.
+type behindCache struct {
+	lock  sync.Mutex
+	pools map[string]behindEntry
+}
+
+type behindEntry struct {
+	baseSHA  string
+	upToDate map[string]bool
+}
+
+func newBehindCache() *behindCache {
+	return &behindCache{pools: make(map[string]behindEntry)}
+}
+
+// get returns whether the head contains the base SHA of the subpool, and false if
+// it is not known. It is a no-op on a nil cache.
+func (bc *behindCache) get(sp subpool, head string) (upToDate bool, known bool) {
+	if bc == nil {
+		return false, false
+	}
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	entry, ok := bc.pools[poolKey(sp.org, sp.repo, sp.branch)]
+	if !ok || entry.baseSHA != sp.sha {
+		return false, false
+	}
+	upToDate, known = entry.upToDate[head]
+	return upToDate, known
+}
+
+// set records whether the head contains the base SHA of the subpool. It is a no-op
+// on a nil cache.
+func (bc *behindCache) set(sp subpool, head string, upToDate bool) {
+	if bc == nil {
+		return
+	}
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+	key := poolKey(sp.org, sp.repo, sp.branch)
+	entry, ok := bc.pools[key]
+	if !ok || entry.baseSHA != sp.sha {
+		entry = behindEntry{baseSHA: sp.sha, upToDate: make(map[string]bool)}
+		bc.pools[key] = entry
+	}
+	entry.upToDate[head] = upToDate
+}
+
+// splitBehindBase splits the PRs into those which contain the base SHA of the
+// subpool and those which are behind it. All the PRs are returned as up to date
+// unless update_branch is enabled for the repo and its branch protection is strict.
+// The repository is only cloned if the ancestry of some PR heads is not cached.
+func (c *DefaultController) splitBehindBase(sp subpool, prs []PullRequest) ([]PullRequest, []PullRequest, error) {
+	if len(prs) == 0 || !c.config().Tide.UpdateBranch(sp.org, sp.repo) || !c.strictBranch(sp) {
+		return prs, nil, nil
+	}
+	var r *git.Repo
+	defer func() {
+		if r != nil {
+			r.Clean()
+		}
+	}()
+
+	var upToDate, behind []PullRequest
+	for _, pr := range prs {
+		head := string(pr.HeadRefOID)
+		ok, known := c.behind.get(sp, head)
+		if !known {
+			var err error
+			if r == nil {
+				if r, err = c.cloneAt(sp); err != nil {
+					return nil, nil, err
+				}
+			}
+			if ok, err = r.IsAncestor(sp.sha, head); err != nil {
+				return nil, nil, err
+			}
+			c.behind.set(sp, head, ok)
+		}
+		if ok {
+			upToDate = append(upToDate, pr)
+		} else {
+			behind = append(behind, pr)
+		}
+	}
+	return upToDate, behind, nil
+}
+
+// updateBranch merges the base branch into the PR so that it is retested. The git
+// provider API is used if it has one, otherwise the merge is done locally and pushed
+// to the PR branch, which requires the PR branch to be in the same repository.
+func (c *DefaultController) updateBranch(sp subpool, pr PullRequest) error {
+	err := c.ghc.UpdatePullRequestBranch(sp.org, sp.repo, int(pr.Number), string(pr.HeadRefOID))
+	if _, ok := err.(gitprovider.UpdateBranchUnsupportedError); !ok {
+		return err
+	}
+	if bool(pr.IsCrossRepository) {
+		return fmt.Errorf("cannot push to the branch of PR #%d from a fork: %v", int(pr.Number), err)
+	}
+
+	r, err := c.cloneAt(sp)
+	if err != nil {
+		return err
+	}
+	defer r.Clean()
+
+	if err := r.Checkout(string(pr.HeadRefOID)); err != nil {
+		return err
+	}
+	ok, err := r.Merge(sp.sha)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("PR #%d conflicts with %s", int(pr.Number), sp.branch)
+	}
+	return r.PushToBranch("HEAD", string(pr.HeadRefName))
+}
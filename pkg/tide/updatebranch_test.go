@@ -0,0 +1,208 @@
+package tide
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/lighthouse/pkg/prow/config"
+	"github.com/jenkins-x/lighthouse/pkg/prow/git/localgit"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBehindBase(t *testing.T) {
+	testCases := []struct {
+		name         string
+		updateBranch bool
+		strict       bool
+		updateErr    error
+		fork         bool
+
+		expectBehind []int
+		expectAPI    []int
+		expectPushed bool
+		expectErr    bool
+	}{
+		{
+			name:   "update_branch disabled",
+			strict: true,
+		},
+		{
+			name:         "branch protection not strict",
+			updateBranch: true,
+		},
+		{
+			name:         "updated with the API",
+			updateBranch: true,
+			strict:       true,
+			expectBehind: []int{2},
+			expectAPI:    []int{2},
+		},
+		{
+			name:         "updated locally",
+			updateBranch: true,
+			strict:       true,
+			updateErr:    gitprovider.UpdateBranchUnsupportedError("test error"),
+			expectBehind: []int{2},
+			expectPushed: true,
+		},
+		{
+			name:         "fork cannot be updated locally",
+			updateBranch: true,
+			strict:       true,
+			updateErr:    gitprovider.UpdateBranchUnsupportedError("test error"),
+			fork:         true,
+			expectBehind: []int{2},
+			expectErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lg, gc, err := localgit.New()
+			require.NoError(t, err)
+			defer gc.Clean()
+			defer lg.Clean()
+			require.NoError(t, lg.MakeFakeRepo("o", "r"))
+
+			// PR 1 contains the head of master while PR 2 is behind it
+			require.NoError(t, lg.CheckoutNewBranch("o", "r", "pr-2"))
+			require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{"2": []byte("WOW")}))
+			require.NoError(t, lg.Checkout("o", "r", "master"))
+			require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{"base": []byte("WOW")}))
+			require.NoError(t, lg.CheckoutNewBranch("o", "r", "pr-1"))
+			require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{"1": []byte("WOW")}))
+			require.NoError(t, lg.Checkout("o", "r", "master"))
+			baseSHA, err := lg.RevParse("o", "r", "master")
+			require.NoError(t, err)
+			pr2Before, err := lg.RevParse("o", "r", "pr-2")
+			require.NoError(t, err)
+
+			var prs []PullRequest
+			for i, branch := range []string{"pr-1", "pr-2"} {
+				sha, err := lg.RevParse("o", "r", branch)
+				require.NoError(t, err)
+				var pr PullRequest
+				pr.Number = githubql.Int(i + 1)
+				pr.HeadRefName = githubql.String(branch)
+				pr.HeadRefOID = githubql.String(sha)
+				pr.IsCrossRepository = githubql.Boolean(tc.fork)
+				prs = append(prs, pr)
+			}
+
+			strict := tc.strict
+			cfg := &config.Config{
+				ProwConfig: config.ProwConfig{
+					Tide: config.Tide{UpdateBranchMap: map[string]bool{"o/r": tc.updateBranch}},
+					BranchProtection: config.BranchProtection{
+						Orgs: map[string]config.Org{
+							"o": {Policy: config.Policy{RequiredStatusChecks: &config.ContextPolicy{Strict: &strict}}},
+						},
+					},
+				},
+			}
+			ghc := &fgc{updateErr: tc.updateErr}
+			c := &DefaultController{
+				logger: logrus.WithField("component", "tide"),
+				gc:     gc,
+				config: func() *config.Config { return cfg },
+				ghc:    ghc,
+			}
+			sp := subpool{
+				log:    logrus.WithField("component", "tide"),
+				org:    "o",
+				repo:   "r",
+				branch: "master",
+				sha:    baseSHA,
+			}
+
+			upToDate, behind, err := c.splitBehindBase(sp, prs)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectBehind, prNumbers(behind))
+			assert.Equal(t, len(prs)-len(tc.expectBehind), len(upToDate))
+			if len(behind) == 0 {
+				return
+			}
+
+			err = c.updateBranch(sp, behind[0])
+			if tc.expectErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectAPI, ghc.updated)
+
+			// the local remote has master checked out so pushes to pr-2 are accepted
+			pr2After, err := lg.RevParse("o", "r", "pr-2")
+			require.NoError(t, err)
+			if tc.expectPushed {
+				assert.NotEqual(t, pr2Before, pr2After)
+				_, err := lg.RevParse("o", "r", "pr-2:base")
+				assert.NoError(t, err, "the base commit should be merged into the PR branch")
+			} else {
+				assert.Equal(t, pr2Before, pr2After)
+			}
+		})
+	}
+}
+
+func TestSplitBehindBaseCache(t *testing.T) {
+	lg, gc, err := localgit.New()
+	require.NoError(t, err)
+	defer gc.Clean()
+	defer lg.Clean()
+	require.NoError(t, lg.MakeFakeRepo("o", "r"))
+	require.NoError(t, lg.CheckoutNewBranch("o", "r", "pr-1"))
+	require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{"1": []byte("WOW")}))
+	require.NoError(t, lg.Checkout("o", "r", "master"))
+	require.NoError(t, lg.AddCommit("o", "r", map[string][]byte{"base": []byte("WOW")}))
+	baseSHA, err := lg.RevParse("o", "r", "master")
+	require.NoError(t, err)
+	headSHA, err := lg.RevParse("o", "r", "pr-1")
+	require.NoError(t, err)
+
+	var pr PullRequest
+	pr.Number = githubql.Int(1)
+	pr.HeadRefOID = githubql.String(headSHA)
+	strict := true
+	cfg := &config.Config{
+		ProwConfig: config.ProwConfig{
+			Tide: config.Tide{UpdateBranchMap: map[string]bool{"o/r": true}},
+			BranchProtection: config.BranchProtection{
+				Orgs: map[string]config.Org{
+					"o": {Policy: config.Policy{RequiredStatusChecks: &config.ContextPolicy{Strict: &strict}}},
+				},
+			},
+		},
+	}
+	c := &DefaultController{
+		logger: logrus.WithField("component", "tide"),
+		gc:     gc,
+		config: func() *config.Config { return cfg },
+		behind: newBehindCache(),
+	}
+	sp := subpool{log: logrus.WithField("component", "tide"), org: "o", repo: "r", branch: "master", sha: baseSHA}
+
+	_, behind, err := c.splitBehindBase(sp, []PullRequest{pr})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, prNumbers(behind))
+
+	// the repository can no longer be cloned so the cached ancestry must be used
+	require.NoError(t, lg.Clean())
+	_, behind, err = c.splitBehindBase(sp, []PullRequest{pr})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, prNumbers(behind))
+
+	// the heads are forgotten once the base SHA moves
+	moved := sp
+	moved.sha = headSHA
+	_, known := c.behind.get(moved, headSHA)
+	assert.False(t, known)
+	c.behind.set(moved, headSHA, true)
+	_, known = c.behind.get(sp, headSHA)
+	assert.False(t, known)
+	upToDate, known := c.behind.get(moved, headSHA)
+	assert.True(t, known)
+	assert.True(t, upToDate)
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // #nosec GitHub signs its webhooks with HMAC-SHA1
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// githubEventHeader is the header of the kind of GitHub webhooks
+	githubEventHeader = "X-GitHub-Event"
+	// githubSignatureHeader is the header of the HMAC-SHA1 signature of GitHub webhooks
+	githubSignatureHeader = "X-Hub-Signature"
+
+	// maxStatusEventSize is the size above which status events are refused
+	maxStatusEventSize = 1024 * 1024
+)
+
+// statusEvent is the part of a GitHub status event tide is notified of
+type statusEvent struct {
+	SHA        string `json:"sha"`
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+// isStatusEvent returns true if the request is a GitHub commit status event
+func isStatusEvent(r *http.Request) bool {
+	return r.Header.Get(githubEventHeader) == "status"
+}
+
+// parseStatusEvent reads a GitHub status event, checking its signature if the secret
+// is not empty.
+func parseStatusEvent(r *http.Request, secret string) (*statusEvent, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxStatusEventSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the status event")
+	}
+	if secret != "" {
+		mac := hmac.New(sha1.New, []byte(secret))
+		_, _ = mac.Write(body)
+		expected := "sha1=" + hex.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(r.Header.Get(githubSignatureHeader)), []byte(expected)) {
+			return nil, errors.New("the signature of the status event is invalid")
+		}
+	}
+	event := &statusEvent{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the status event")
+	}
+	if event.SHA == "" || event.Repository.Owner.Login == "" || event.Repository.Name == "" {
+		return nil, errors.New("the status event has no commit or repository")
+	}
+	return event, nil
+}
+
+// handleStatusEvent notifies tide of a commit status. The go-scm webhooks do not carry
+// the commit of statuses so the event is parsed here. Statuses do not say which pull
+// requests they are for so all the pools of the repository are synced, the debounce
+// of tide absorbing the bursts of statuses of a commit.
+func (o *Options) handleStatusEvent(w http.ResponseWriter, r *http.Request) {
+	o.server.Metrics.CountWebhook(o.gitKind(), "status", "")
+	event, err := parseStatusEvent(r, os.Getenv("HMAC_TOKEN"))
+	if err != nil {
+		logrus.Warnf("failed to parse webhook: %s", err.Error())
+		responseHTTPError(w, http.StatusBadRequest, "400 Bad Request: "+err.Error())
+		return
+	}
+	if o.tideClient == nil {
+		_, _ = w.Write([]byte("ignored status hook"))
+		return
+	}
+	org, repo := event.Repository.Owner.Login, event.Repository.Name
+	l := logrus.WithFields(logrus.Fields{"Webhook": "status", "Namespace": org, "Name": repo, "Sha": event.SHA})
+	l.Info("notifying tide of status")
+	go func() {
+		if err := o.tideClient.Notify(org, repo, ""); err != nil {
+			l.WithError(err).Warn("failed to notify tide")
+		}
+	}()
+	_, _ = w.Write([]byte("processed status hook"))
+}
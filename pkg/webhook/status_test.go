@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatusEvent(t *testing.T) {
+	body := `{"sha":"abc123","state":"success","repository":{"name":"repo","owner":{"login":"org"}}}`
+	request := func(signature string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(body))
+		r.Header.Set(githubEventHeader, "status")
+		if signature != "" {
+			r.Header.Set(githubSignatureHeader, signature)
+		}
+		return r
+	}
+
+	r := request("sha1=a3b60f35803f1dd1cc113d30a4d9e38c6a3a8ce7")
+	assert.True(t, isStatusEvent(r))
+	event, err := parseStatusEvent(r, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", event.SHA)
+	assert.Equal(t, "org", event.Repository.Owner.Login)
+	assert.Equal(t, "repo", event.Repository.Name)
+
+	_, err = parseStatusEvent(request("sha1=0000"), "secret")
+	assert.Error(t, err, "events with an invalid signature should be refused")
+	_, err = parseStatusEvent(request(""), "secret")
+	assert.Error(t, err, "unsigned events should be refused")
+
+	_, err = parseStatusEvent(request(""), "")
+	assert.NoError(t, err, "events are not checked without secret")
+
+	r = httptest.NewRequest(http.MethodPost, "/hook", strings.NewReader(`{"state":"success"}`))
+	_, err = parseStatusEvent(r, "")
+	assert.Error(t, err)
+	assert.False(t, isStatusEvent(r))
+}
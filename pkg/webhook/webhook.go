@@ -6,21 +6,29 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/factory"
 	"github.com/jenkins-x/jx/pkg/jxfactory"
+	"github.com/jenkins-x/lighthouse/pkg/api"
 	"github.com/jenkins-x/lighthouse/pkg/cmd/helper"
 	"github.com/jenkins-x/lighthouse/pkg/plumber"
 	"github.com/jenkins-x/lighthouse/pkg/prow/config"
 	"github.com/jenkins-x/lighthouse/pkg/prow/git"
+	"github.com/jenkins-x/lighthouse/pkg/prow/gitprovider"
 	"github.com/jenkins-x/lighthouse/pkg/prow/hook"
 	"github.com/jenkins-x/lighthouse/pkg/prow/logrusutil"
 	"github.com/jenkins-x/lighthouse/pkg/prow/metrics"
+	helphook "github.com/jenkins-x/lighthouse/pkg/prow/pluginhelp/hook"
 	"github.com/jenkins-x/lighthouse/pkg/prow/plugins"
+	"github.com/jenkins-x/lighthouse/pkg/prow/plugins/trigger"
+	"github.com/jenkins-x/lighthouse/pkg/prow/slack"
+	"github.com/jenkins-x/lighthouse/pkg/tide"
 	"github.com/jenkins-x/lighthouse/pkg/version"
 	"github.com/jenkins-x/lighthouse/pkg/watcher"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
 	"github.com/spf13/cobra"
@@ -31,6 +39,8 @@ const (
 	HealthPath = "/health"
 	// ReadyPath URL path for the HTTP endpoint that returns ready status.
 	ReadyPath = "/ready"
+	// MetricsPath is the URL path for the HTTP endpoint that serves the Prometheus metrics.
+	MetricsPath = "/metrics"
 
 	// ProwConfigMapName name of the ConfgMap holding the config
 	ProwConfigMapName = "config"
@@ -53,10 +63,14 @@ type Options struct {
 	namespace        string
 	pluginFilename   string
 	configFilename   string
+	apiTokensFile    string
+	tideURL          string
+	tideClient       *tide.SyncClient
 	server           *hook.Server
 	botName          string
 	gitServerURL     string
 	configMapWatcher *watcher.ConfigMapWatcher
+	activityCache    *plumber.ActivityCache
 }
 
 // NewCmdWebhook creates the command
@@ -81,6 +95,8 @@ func NewCmdWebhook() *cobra.Command {
 	cmd.Flags().StringVar(&options.pluginFilename, "plugin-file", "", "Path to the plugins.yaml file. If not specified it is loaded from the 'plugins' ConfigMap")
 	cmd.Flags().StringVar(&options.configFilename, "config-file", "", "Path to the config.yaml file. If not specified it is loaded from the 'config' ConfigMap")
 	cmd.Flags().StringVar(&options.botName, "bot-name", "", "The name of the bot user to run as. Defaults to $GIT_USER if not specified.")
+	cmd.Flags().StringVar(&options.tideURL, "tide-url", "", "The URL of tide, e.g. http://tide, to notify of the events affecting its pools so that they are synced straight away. Tide only syncs periodically if not specified.")
+	cmd.Flags().StringVar(&options.apiTokensFile, "api-tokens-file", "", "Path to a YAML file mapping user names to the bearer tokens of the REST API. The API is disabled if not specified.")
 
 	return cmd
 }
@@ -99,11 +115,19 @@ func (o *Options) Run() error {
 		logrus.SetFormatter(logrusutil.CreateDefaultFormatter())
 	}
 
-	_, ns, err := o.GetFactory().CreateJXClient()
+	jxClient, ns, err := o.GetFactory().CreateJXClient()
 	if err != nil {
 		return errors.Wrapf(err, "failed to create JX Client")
 	}
 	o.namespace = ns
+	o.activityCache, err = plumber.NewActivityCache(jxClient, ns)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create the PipelineActivity cache")
+	}
+	err = o.activityCache.Start(make(chan struct{}))
+	if err != nil {
+		return errors.Wrapf(err, "failed to start the PipelineActivity cache")
+	}
 	o.server, err = o.createHookServer()
 	if err != nil {
 		return errors.Wrapf(err, "failed to create Hook Server")
@@ -113,10 +137,23 @@ func (o *Options) Run() error {
 	if err != nil {
 		return errors.Wrapf(err, "failed to create ScmClient")
 	}
+	if o.tideURL != "" {
+		o.tideClient = tide.NewSyncClient(o.tideURL, []byte(os.Getenv("HMAC_TOKEN")))
+	}
 
 	mux := http.NewServeMux()
 	mux.Handle(HealthPath, http.HandlerFunc(o.health))
 	mux.Handle(ReadyPath, http.HandlerFunc(o.ready))
+	mux.Handle(MetricsPath, promhttp.Handler())
+	mux.Handle(helphook.PluginHelpRoute, helphook.NewHelpAgent(o.server.Plugins))
+	if o.apiTokensFile != "" {
+		apiServer, err := o.createAPIServer()
+		if err != nil {
+			return errors.Wrapf(err, "failed to create the REST API server")
+		}
+		mux.Handle(api.Prefix, apiServer.Handler())
+		logrus.Infof("Lighthouse is serving the REST API on path %s", api.Prefix)
+	}
 
 	mux.Handle("/", http.HandlerFunc(o.defaultHandler))
 	mux.Handle(o.Path, http.HandlerFunc(o.handleWebHookRequests))
@@ -184,6 +221,17 @@ func (o *Options) handleWebHookRequests(w http.ResponseWriter, r *http.Request)
 	}
 	logrus.Debug("about to parse webhook")
 
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	w = recorder
+	defer func() {
+		o.server.Metrics.CountResponse(recorder.status)
+	}()
+
+	if isStatusEvent(r) {
+		o.handleStatusEvent(w, r)
+		return
+	}
+
 	scmClient, serverURL, token, err := o.createSCMClient()
 	if err != nil {
 		logrus.Errorf("failed to create SCM scmClient: %s", err.Error())
@@ -218,8 +266,13 @@ func (o *Options) handleWebHookRequests(w http.ResponseWriter, r *http.Request)
 		GitHubClient:     scmClient,
 		KubernetesClient: kubeClient,
 		GitClient:        gitClient,
+		SlackClient:      o.createSlackClient(),
 	}
+	eventType := string(webhook.Kind())
+	o.server.Metrics.CountWebhook(o.gitKind(), eventType, webhookAction(webhook))
+	start := time.Now()
 	l, output, err := o.ProcessWebHook(logrus.WithField("Webhook", webhook.Kind()), webhook)
+	o.server.Metrics.ObserveWebhookLatency(eventType, start)
 	if err != nil {
 		responseHTTPError(w, http.StatusInternalServerError, fmt.Sprintf("500 Internal Server Error: %s", err.Error()))
 	}
@@ -265,6 +318,9 @@ func (o *Options) ProcessWebHook(l *logrus.Entry, webhook scm.Webhook) (*logrus.
 		}
 
 		o.server.HandlePushEvent(l, pushHook)
+		if strings.HasPrefix(pushHook.Ref, "refs/heads/") {
+			o.notifyTide(l, pushHook.Repository(), strings.TrimPrefix(pushHook.Ref, "refs/heads/"))
+		}
 		return l, "processed push hook", nil
 	}
 	prHook, ok := webhook.(*scm.PullRequestHook)
@@ -286,6 +342,7 @@ func (o *Options) ProcessWebHook(l *logrus.Entry, webhook scm.Webhook) (*logrus.
 		}
 
 		o.server.HandlePullRequestEvent(l, prHook)
+		o.notifyTide(l, prHook.Repository(), pr.Base.Ref)
 		return l, "processed PR hook", nil
 	}
 	branchHook, ok := webhook.(*scm.BranchHook)
@@ -359,10 +416,36 @@ func (o *Options) ProcessWebHook(l *logrus.Entry, webhook scm.Webhook) (*logrus.
 		o.server.HandlePullRequestCommentEvent(l, *prCommentHook)
 		return l, "processed PR comment hook", nil
 	}
+	reviewHook, ok := webhook.(*scm.ReviewHook)
+	if ok {
+		action := reviewHook.Action
+		fields["Action"] = action.String()
+		fields["PR.Number"] = reviewHook.PullRequest.Number
+		fields["Review.State"] = reviewHook.Review.State
+
+		l.Info("notifying tide of review")
+
+		o.notifyTide(l, reviewHook.Repository(), reviewHook.PullRequest.Base.Ref)
+		return l, "processed review hook", nil
+	}
 	l.Debugf("unknown kind %s webhook %#v", webhook.Kind(), webhook)
 	return l, fmt.Sprintf("unknown hook %s", webhook.Kind()), nil
 }
 
+// notifyTide asks tide to sync the pools of the repository, or of the branch if not
+// empty, if a tide URL is configured. Failures are only logged as tide still syncs
+// all its pools periodically.
+func (o *Options) notifyTide(l *logrus.Entry, repository scm.Repository, branch string) {
+	if o.tideClient == nil {
+		return
+	}
+	go func() {
+		if err := o.tideClient.Notify(repository.Namespace, repository.Name, branch); err != nil {
+			l.WithError(err).Warn("failed to notify tide")
+		}
+	}()
+}
+
 // GetFactory lazily creates a Factory if its not already created
 func (o *Options) GetFactory() jxfactory.Factory {
 	if o.factory == nil {
@@ -384,6 +467,7 @@ func (o *Options) createSCMClient() (*scm.Client, string, string, error) {
 		return nil, serverURL, token, err
 	}
 	client, err := factory.NewClient(kind, serverURL, token)
+	gitprovider.InstrumentClient(client, kind)
 	return client, serverURL, token, err
 }
 
@@ -413,6 +497,16 @@ func (o *Options) createSCMToken(gitKind string) (string, error) {
 	return value, nil
 }
 
+// createSlackClient returns a Slack client using the $SLACK_TOKEN environment variable, or nil
+// if no token is available so that plugins skip Slack
+func (o *Options) createSlackClient() *slack.Client {
+	token := os.Getenv("SLACK_TOKEN")
+	if token == "" {
+		return nil
+	}
+	return slack.NewClient(token)
+}
+
 func (o *Options) createHookServer() (*hook.Server, error) {
 	configAgent := &config.Agent{}
 	pluginAgent := &plugins.ConfigAgent{}
@@ -441,6 +535,10 @@ func (o *Options) createHookServer() (*hook.Server, error) {
 		}
 	}
 
+	if os.Getenv("SLACK_TOKEN") == "" {
+		logrus.Info("$SLACK_TOKEN is not set so Slack messages are disabled")
+	}
+
 	clientFactory := o.GetFactory()
 	kubeClient, _, err := clientFactory.CreateKubeClient()
 	if err != nil {
@@ -507,6 +605,43 @@ func (o *Options) createHookServer() (*hook.Server, error) {
 	return server, nil
 }
 
+func (o *Options) createAPIServer() (*api.Server, error) {
+	tokens, err := api.LoadTokens(o.apiTokensFile)
+	if err != nil {
+		return nil, err
+	}
+	jxClient, _, err := o.GetFactory().CreateJXClient()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create JX client")
+	}
+	tektonClient, _, err := o.GetFactory().CreateTektonClient()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create Tekton client")
+	}
+	plumberClient, err := plumber.NewPlumberWithCache(jxClient, o.namespace, o.activityCache)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create Plumber client")
+	}
+	scmClient, _, _, err := o.createSCMClient()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create ScmClient")
+	}
+	logger := logrus.WithField("component", "api")
+	return &api.Server{
+		Tokens:       tokens,
+		ConfigGetter: o.server.ConfigAgent.Config,
+		Trigger: trigger.Client{
+			GitHubClient:       gitprovider.ToClient(scmClient, o.GetBotName()),
+			PlumberClient:      plumberClient,
+			Logger:             logger,
+			MetapipelineClient: o.server.MetapipelineClient,
+		},
+		Plumber: plumberClient,
+		Aborter: plumber.NewAborter(jxClient, tektonClient, o.namespace),
+		Logger:  logger,
+	}, nil
+}
+
 func (o *Options) updatePlumberClientAndReturnError(l *logrus.Entry, server *hook.Server, repository scm.Repository) error {
 	jxClient, _, err := o.GetFactory().CreateJXClient()
 	if err != nil {
@@ -514,7 +649,7 @@ func (o *Options) updatePlumberClientAndReturnError(l *logrus.Entry, server *hoo
 		l.Errorf("%s", err.Error())
 		return err
 	}
-	plumberClient, err := plumber.NewPlumber(jxClient, o.namespace)
+	plumberClient, err := plumber.NewPlumberWithCache(jxClient, o.namespace, o.activityCache)
 	if err != nil {
 		err = errors.Wrapf(err, "failed to create Plumber client")
 		l.Errorf("%s", err.Error())
@@ -524,6 +659,34 @@ func (o *Options) updatePlumberClientAndReturnError(l *logrus.Entry, server *hoo
 	return nil
 }
 
+// webhookAction returns the action of the webhook, if its kind has one
+func webhookAction(webhook scm.Webhook) string {
+	switch h := webhook.(type) {
+	case *scm.PullRequestHook:
+		return h.Action.String()
+	case *scm.BranchHook:
+		return h.Action.String()
+	case *scm.IssueCommentHook:
+		return h.Action.String()
+	case *scm.PullRequestCommentHook:
+		return h.Action.String()
+	default:
+		return ""
+	}
+}
+
+// statusRecorder remembers the status code written to the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before writing it
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
 func responseHTTPError(w http.ResponseWriter, statusCode int, response string) {
 	logrus.WithFields(logrus.Fields{
 		"response":    response,
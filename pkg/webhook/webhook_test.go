@@ -3,6 +3,8 @@ package webhook
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -56,6 +58,24 @@ func (suite *WebhookTestSuite) TestProcessWebhookPR() {
 	assert.NotNil(t, logrusEntry)
 }
 
+func (suite *WebhookTestSuite) TestWebhookAction() {
+	t := suite.T()
+
+	assert.Equal(t, "opened", webhookAction(&scm.PullRequestHook{Action: scm.ActionOpen}))
+	assert.Equal(t, "created", webhookAction(&scm.IssueCommentHook{Action: scm.ActionCreate}))
+	assert.Equal(t, "", webhookAction(&scm.PushHook{}))
+}
+
+func (suite *WebhookTestSuite) TestStatusRecorder() {
+	t := suite.T()
+
+	w := httptest.NewRecorder()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	responseHTTPError(recorder, http.StatusInternalServerError, "boom")
+	assert.Equal(t, http.StatusInternalServerError, recorder.status)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
 func (suite *WebhookTestSuite) SetupSuite() {
 	options := &Options{}
 	t := suite.T()
@@ -114,3 +134,14 @@ func TestWebhookTestSuite(t *testing.T) {
 	os.Setenv("GIT_TOKEN", "abc123")
 	suite.Run(t, new(WebhookTestSuite))
 }
+
+func TestCreateSlackClient(t *testing.T) {
+	defer os.Unsetenv("SLACK_TOKEN")
+	o := &Options{}
+
+	os.Unsetenv("SLACK_TOKEN")
+	assert.Nil(t, o.createSlackClient(), "plugins must skip Slack without a token")
+
+	os.Setenv("SLACK_TOKEN", "xoxb-token")
+	assert.NotNil(t, o.createSlackClient())
+}